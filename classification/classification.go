@@ -0,0 +1,326 @@
+// Package classification calculates UK honours degree classifications from
+// credit-weighted module marks, recording the working behind each result.
+package classification
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Classes of honours degree, from highest to lowest
+const (
+	First       = "First"
+	UpperSecond = "Upper Second (2:1)"
+	LowerSecond = "Lower Second (2:2)"
+	Third       = "Third"
+	Fail        = "Fail"
+)
+
+// boundaries are the lowest averages of each class, from highest to lowest
+var boundaries = []struct {
+	class string
+	mark  float64
+}{
+	{First, 70},
+	{UpperSecond, 60},
+	{LowerSecond, 50},
+	{Third, 40},
+}
+
+// ErrIncomplete is returned when a weighted level has no completed modules
+var ErrIncomplete = errors.New("not enough completed modules to classify")
+
+// Rules configures how a classification is calculated
+type Rules struct {
+	// LevelWeights is the relative weight of each level's average in the
+	// final average. Modules at other levels are ignored.
+	LevelWeights map[int]float64 `json:"level_weights"`
+	// DiscountCredits is how many of the lowest-marked credits are left out
+	// at each level
+	DiscountCredits map[int]int `json:"discount_credits"`
+	// BorderlineZone is how far below a boundary, in percentage points, an
+	// average is considered for promotion
+	BorderlineZone float64 `json:"borderline_zone"`
+	// BorderlineCreditShare is the share of final-level credits that must be
+	// marked at or above the higher class for a borderline average to be
+	// promoted
+	BorderlineCreditShare float64 `json:"borderline_credit_share"`
+}
+
+// DefaultRules weights FHEQ levels 5 and 6, the second and third years of
+// an honours degree, 25:75 and promotes averages within two points of a
+// boundary when at least half the final level's credits reach the higher
+// class
+func DefaultRules() Rules {
+	return Rules{
+		LevelWeights:          map[int]float64{5: 25, 6: 75},
+		DiscountCredits:       map[int]int{},
+		BorderlineZone:        2,
+		BorderlineCreditShare: 0.5,
+	}
+}
+
+// Validate reports the first problem with the rules
+func (r Rules) Validate() error {
+	if len(r.LevelWeights) == 0 {
+		return errors.New("at least one level must be weighted")
+	}
+	for level, weight := range r.LevelWeights {
+		if weight <= 0 {
+			return fmt.Errorf("weight for level %d must be positive", level)
+		}
+	}
+	for level, credits := range r.DiscountCredits {
+		if credits < 0 {
+			return fmt.Errorf("discounted credits for level %d must not be negative", level)
+		}
+	}
+	if r.BorderlineZone < 0 || r.BorderlineZone >= 10 {
+		return errors.New("borderline zone must be between 0 and 10")
+	}
+	if r.BorderlineCreditShare <= 0 || r.BorderlineCreditShare > 1 {
+		return errors.New("borderline credit share must be greater than 0 and at most 1")
+	}
+	return nil
+}
+
+// ParseLevelWeights parses weights written as "5:25,6:75"
+func ParseLevelWeights(s string) (map[int]float64, error) {
+	weights := make(map[int]float64)
+	err := parseLevelPairs(s, func(level int, value string) error {
+		weight, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		weights[level] = weight
+		return nil
+	})
+	return weights, err
+}
+
+// ParseDiscountCredits parses discounted credits written as "5:20,6:20"
+func ParseDiscountCredits(s string) (map[int]int, error) {
+	credits := make(map[int]int)
+	err := parseLevelPairs(s, func(level int, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		credits[level] = n
+		return nil
+	})
+	return credits, err
+}
+
+// parseLevelPairs calls fn for each level:value pair in a comma-separated list
+func parseLevelPairs(s string, fn func(level int, value string) error) error {
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		levelText, value, ok := strings.Cut(pair, ":")
+		if !ok {
+			return fmt.Errorf("invalid pair %q, expected level:value", pair)
+		}
+		level, err := strconv.Atoi(strings.TrimSpace(levelText))
+		if err != nil {
+			return fmt.Errorf("invalid level in %q", pair)
+		}
+		if err := fn(level, strings.TrimSpace(value)); err != nil {
+			return fmt.Errorf("invalid value in %q", pair)
+		}
+	}
+	return nil
+}
+
+// Module is a completed module result that counts towards a classification
+type Module struct {
+	Code    string  `json:"code"`
+	Title   string  `json:"title"`
+	Level   int     `json:"level"`
+	Credits int     `json:"credits"`
+	Mark    float64 `json:"mark"`
+}
+
+// ModuleWorking shows how much of a module counted towards its level average
+type ModuleWorking struct {
+	Module
+	CountedCredits    int `json:"counted_credits"`
+	DiscountedCredits int `json:"discounted_credits"`
+}
+
+// LevelWorking shows how a level's credit-weighted average was reached
+type LevelWorking struct {
+	Level             int             `json:"level"`
+	Weight            float64         `json:"weight"`
+	Modules           []ModuleWorking `json:"modules"`
+	TotalCredits      int             `json:"total_credits"`
+	DiscountedCredits int             `json:"discounted_credits"`
+	CountedCredits    int             `json:"counted_credits"`
+	Average           float64         `json:"average"`
+	// Contribution is the level's share of the final average
+	Contribution float64 `json:"contribution"`
+}
+
+// BorderlineWorking shows how a borderline average was considered for
+// promotion to the next class
+type BorderlineWorking struct {
+	NextClass         string  `json:"next_class"`
+	Boundary          float64 `json:"boundary"`
+	Level             int     `json:"level"`
+	CreditsAtOrAbove  int     `json:"credits_at_or_above"`
+	CreditsConsidered int     `json:"credits_considered"`
+	Share             float64 `json:"share"`
+	RequiredShare     float64 `json:"required_share"`
+	Promoted          bool    `json:"promoted"`
+}
+
+// Result is a classification with the working behind it
+type Result struct {
+	Classification string             `json:"classification"`
+	Average        float64            `json:"average"`
+	AverageClass   string             `json:"average_class"`
+	Levels         []LevelWorking     `json:"levels"`
+	Borderline     *BorderlineWorking `json:"borderline"`
+	Rules          Rules              `json:"rules"`
+}
+
+// round rounds v to two decimal places
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// classOf returns the class an average falls in and, unless it is a First,
+// the index in boundaries of the next class up
+func classOf(average float64) (string, int) {
+	for i, b := range boundaries {
+		if average >= b.mark {
+			return b.class, i - 1
+		}
+	}
+	return Fail, len(boundaries) - 1
+}
+
+// Classify calculates a classification from completed module results under
+// the given rules
+func Classify(rules Rules, modules []Module) (*Result, error) {
+	if err := rules.Validate(); err != nil {
+		return nil, err
+	}
+
+	levels := make([]int, 0, len(rules.LevelWeights))
+	var totalWeight float64
+	for level, weight := range rules.LevelWeights {
+		levels = append(levels, level)
+		totalWeight += weight
+	}
+	sort.Ints(levels)
+
+	result := &Result{Rules: rules, Levels: make([]LevelWorking, 0, len(levels))}
+	var average float64
+	for _, level := range levels {
+		working := levelWorking(level, rules, modules)
+		if working.CountedCredits == 0 {
+			return nil, fmt.Errorf("%w: no completed modules at level %d", ErrIncomplete, level)
+		}
+		working.Weight = rules.LevelWeights[level]
+		working.Contribution = working.Average * working.Weight / totalWeight
+		average += working.Contribution
+		working.Average = round(working.Average)
+		working.Contribution = round(working.Contribution)
+		result.Levels = append(result.Levels, working)
+	}
+
+	// Classes are decided on the unrounded average so that, for example,
+	// 69.996 is not mistaken for a First
+	result.Average = round(average)
+	class, next := classOf(average)
+	result.AverageClass = class
+	result.Classification = class
+
+	if next >= 0 {
+		boundary := boundaries[next]
+		if boundary.mark-average <= rules.BorderlineZone {
+			final := levels[len(levels)-1]
+			result.Borderline = borderline(boundary.class, boundary.mark, final, rules, result.Levels[len(result.Levels)-1])
+			if result.Borderline.Promoted {
+				result.Classification = boundary.class
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// levelWorking builds the working for one level, discounting its
+// lowest-marked credits. A module may be partly discounted.
+func levelWorking(level int, rules Rules, modules []Module) LevelWorking {
+	working := LevelWorking{Level: level, Modules: []ModuleWorking{}}
+	for _, m := range modules {
+		if m.Level == level && m.Credits > 0 {
+			working.Modules = append(working.Modules, ModuleWorking{Module: m, CountedCredits: m.Credits})
+			working.TotalCredits += m.Credits
+		}
+	}
+
+	// Discount from the lowest mark upwards, never discounting every credit
+	sort.SliceStable(working.Modules, func(i, j int) bool {
+		return working.Modules[i].Mark < working.Modules[j].Mark
+	})
+	remaining := rules.DiscountCredits[level]
+	if remaining >= working.TotalCredits {
+		remaining = 0
+	}
+	for i := range working.Modules {
+		if remaining == 0 {
+			break
+		}
+		m := &working.Modules[i]
+		discount := m.Credits
+		if discount > remaining {
+			discount = remaining
+		}
+		m.DiscountedCredits = discount
+		m.CountedCredits -= discount
+		working.DiscountedCredits += discount
+		remaining -= discount
+	}
+
+	var weighted float64
+	for _, m := range working.Modules {
+		weighted += m.Mark * float64(m.CountedCredits)
+		working.CountedCredits += m.CountedCredits
+	}
+	if working.CountedCredits > 0 {
+		working.Average = weighted / float64(working.CountedCredits)
+	}
+	return working
+}
+
+// borderline applies the preponderance rule: a borderline average is
+// promoted when enough of the final level's counted credits reach the
+// higher class
+func borderline(nextClass string, boundary float64, level int, rules Rules, working LevelWorking) *BorderlineWorking {
+	b := &BorderlineWorking{
+		NextClass:     nextClass,
+		Boundary:      boundary,
+		Level:         level,
+		RequiredShare: rules.BorderlineCreditShare,
+	}
+	for _, m := range working.Modules {
+		b.CreditsConsidered += m.CountedCredits
+		if m.Mark >= boundary {
+			b.CreditsAtOrAbove += m.CountedCredits
+		}
+	}
+	if b.CreditsConsidered > 0 {
+		b.Share = round(float64(b.CreditsAtOrAbove) / float64(b.CreditsConsidered))
+		b.Promoted = float64(b.CreditsAtOrAbove)/float64(b.CreditsConsidered) >= rules.BorderlineCreditShare
+	}
+	return b
+}
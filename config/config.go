@@ -2,7 +2,18 @@ package config
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bournemouth-uni-it-api-go/classification"
+	"github.com/bournemouth-uni-it-api-go/handlers"
+	"github.com/bournemouth-uni-it-api-go/logging"
+	"github.com/bournemouth-uni-it-api-go/metrics"
+	"github.com/bournemouth-uni-it-api-go/middleware"
+	"github.com/bournemouth-uni-it-api-go/ratelimit"
 )
 
 // Config holds all configuration for the application
@@ -14,6 +25,38 @@ type Config struct {
 	DBName     string
 	DBSSLMode  string
 	ServerPort string
+
+	// Classification holds the degree classification rules
+	Classification classification.Rules
+
+	// JWT holds the keys and claims used to validate bearer tokens
+	JWT middleware.JWTOptions
+
+	// OIDC configures sign-in to the web interface
+	OIDC handlers.OIDCOptions
+
+	// CORS configures which other sites may call the API from the browser
+	CORS middleware.CORSOptions
+	// Security configures the security headers sent with every response
+	Security middleware.SecurityOptions
+
+	// RateLimit holds how many requests each client may make
+	RateLimit middleware.RateLimitOptions
+	// TrustedProxies are the addresses or CIDR ranges of reverse proxies
+	// whose X-Forwarded-For header is believed. None are trusted by default.
+	TrustedProxies []string
+
+	// Log configures the format and level of log output
+	Log logging.Options
+
+	// Metrics configures the Prometheus metrics endpoint
+	Metrics metrics.Options
+
+	// StudentRetention is how long deleted students are kept before they are
+	// purged. Zero disables purging.
+	StudentRetention time.Duration
+	// StudentPurgeInterval is how often deleted students are checked for purging
+	StudentPurgeInterval time.Duration
 }
 
 // LoadConfig loads configuration from environment variables
@@ -26,7 +69,154 @@ func LoadConfig() *Config {
 		DBName:     getEnv("DB_NAME", "student_db"),
 		DBSSLMode:  getEnv("DB_SSL_MODE", "disable"),
 		ServerPort: getEnv("SERVER_PORT", "8080"),
+
+		Classification: loadClassificationRules(),
+
+		JWT: middleware.JWTOptions{
+			HMACSecret:   os.Getenv("JWT_HMAC_SECRET"),
+			RSAPublicKey: os.Getenv("JWT_RSA_PUBLIC_KEY"),
+			JWKSFile:     os.Getenv("JWT_JWKS_FILE"),
+			Issuer:       os.Getenv("JWT_ISSUER"),
+			Audience:     os.Getenv("JWT_AUDIENCE"),
+		},
+
+		OIDC: handlers.OIDCOptions{
+			IssuerURL:             os.Getenv("OIDC_ISSUER_URL"),
+			ClientID:              os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret:          os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:           getEnv("OIDC_REDIRECT_URL", "http://localhost:8080/auth/callback"),
+			Scopes:                strings.Fields(getEnv("OIDC_SCOPES", "openid profile email")),
+			PostLogoutRedirectURL: os.Getenv("OIDC_POST_LOGOUT_REDIRECT_URL"),
+			SessionTTL:            getEnvDuration("SESSION_TTL", 8*time.Hour),
+			CookieSecure:          getEnvBool("SESSION_COOKIE_SECURE", true),
+		},
+
+		CORS: middleware.CORSOptions{
+			AllowedOrigins:   getEnvList("CORS_ALLOWED_ORIGINS", ""),
+			AllowedMethods:   getEnvList("CORS_ALLOWED_METHODS", "GET,POST,PUT,PATCH,DELETE"),
+			AllowedHeaders:   getEnvList("CORS_ALLOWED_HEADERS", "Authorization,Content-Type,If-Match,If-None-Match,X-Request-ID"),
+			AllowCredentials: getEnvBool("CORS_ALLOW_CREDENTIALS", false),
+			MaxAge:           getEnvDuration("CORS_MAX_AGE", 10*time.Minute),
+		},
+		Security: middleware.SecurityOptions{
+			HSTSMaxAge: time.Duration(getEnvInt("HSTS_MAX_AGE", 365*24*60*60)) * time.Second,
+		},
+
+		RateLimit:      loadRateLimitOptions(),
+		TrustedProxies: getEnvList("TRUSTED_PROXIES", ""),
+
+		Log: loadLogOptions(),
+
+		Metrics: metrics.Options{
+			Enabled: getEnvBool("METRICS_ENABLED", true),
+			Token:   os.Getenv("METRICS_TOKEN"),
+		},
+
+		StudentRetention:     time.Duration(getEnvInt("STUDENT_RETENTION_DAYS", 30)) * 24 * time.Hour,
+		StudentPurgeInterval: getEnvDuration("STUDENT_PURGE_INTERVAL", time.Hour),
+	}
+}
+
+// loadClassificationRules reads the degree classification rules from the
+// environment. Invalid rules are reported and the defaults used instead.
+func loadClassificationRules() classification.Rules {
+	rules := classification.DefaultRules()
+	var err error
+
+	if value := os.Getenv("CLASSIFICATION_LEVEL_WEIGHTS"); value != "" && err == nil {
+		rules.LevelWeights, err = classification.ParseLevelWeights(value)
+	}
+	if value := os.Getenv("CLASSIFICATION_DISCOUNT_CREDITS"); value != "" && err == nil {
+		rules.DiscountCredits, err = classification.ParseDiscountCredits(value)
+	}
+	if value := os.Getenv("CLASSIFICATION_BORDERLINE_ZONE"); value != "" && err == nil {
+		rules.BorderlineZone, err = strconv.ParseFloat(value, 64)
+	}
+	if value := os.Getenv("CLASSIFICATION_BORDERLINE_CREDIT_SHARE"); value != "" && err == nil {
+		rules.BorderlineCreditShare, err = strconv.ParseFloat(value, 64)
+	}
+	if err == nil {
+		err = rules.Validate()
+	}
+
+	if err != nil {
+		slog.Warn("Invalid classification rules, using defaults", "error", err)
+		return classification.DefaultRules()
+	}
+	return rules
+}
+
+// defaultRateLimit applies to each client when RATE_LIMIT is not set
+var defaultRateLimit = ratelimit.Limit{Requests: 300, Period: time.Minute}
+
+// defaultIPRateLimit applies to each IP address when RATE_LIMIT_IP is not
+// set. It is higher than defaultRateLimit because clients behind one NAT
+// share an address.
+var defaultIPRateLimit = ratelimit.Limit{Requests: 600, Period: time.Minute}
+
+// loadRateLimitOptions reads the rate limits from the environment. Invalid
+// limits are reported and the defaults used instead.
+func loadRateLimitOptions() middleware.RateLimitOptions {
+	opts := middleware.RateLimitOptions{Default: defaultRateLimit, PerIP: defaultIPRateLimit}
+
+	switch store := getEnv("RATE_LIMIT_STORE", "memory"); store {
+	case "memory":
+	case "postgres":
+		opts.Shared = true
+	default:
+		slog.Warn("Invalid RATE_LIMIT_STORE, using memory", "value", store)
+	}
+
+	if value := os.Getenv("RATE_LIMIT"); value != "" {
+		limit, err := ratelimit.ParseLimit(value)
+		if err != nil {
+			slog.Warn("Invalid RATE_LIMIT, using the default", "error", err, "default", defaultRateLimit.String())
+		} else {
+			opts.Default = limit
+		}
+	}
+
+	if value := os.Getenv("RATE_LIMIT_IP"); value != "" {
+		limit, err := ratelimit.ParseLimit(value)
+		if err != nil {
+			slog.Warn("Invalid RATE_LIMIT_IP, using the default", "error", err, "default", defaultIPRateLimit.String())
+		} else {
+			opts.PerIP = limit
+		}
+	}
+
+	routes, err := ratelimit.ParseRouteLimits(os.Getenv("RATE_LIMIT_ROUTES"))
+	if err != nil {
+		slog.Warn("Invalid RATE_LIMIT_ROUTES, ignoring them", "error", err)
+	} else {
+		opts.Routes = routes
+	}
+	return opts
+}
+
+// loadLogOptions reads the log format and level from the environment. Invalid
+// values are reported and the defaults used instead.
+func loadLogOptions() logging.Options {
+	opts := logging.Options{Format: logging.FormatJSON, Level: slog.LevelInfo}
+
+	if value := os.Getenv("LOG_FORMAT"); value != "" {
+		format, err := logging.ParseFormat(value)
+		if err != nil {
+			slog.Warn("Invalid LOG_FORMAT, using json", "error", err)
+		} else {
+			opts.Format = format
+		}
+	}
+
+	if value := os.Getenv("LOG_LEVEL"); value != "" {
+		level, err := logging.ParseLevel(value)
+		if err != nil {
+			slog.Warn("Invalid LOG_LEVEL, using info", "error", err)
+		} else {
+			opts.Level = level
+		}
 	}
+	return opts
 }
 
 // GetDBConnectionString returns the database connection string
@@ -49,3 +239,60 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+// getEnvList gets a comma-separated list environment variable, or splits a
+// default value if it is unset. Empty items are dropped.
+func getEnvList(key, defaultValue string) []string {
+	var list []string
+	for _, item := range strings.Split(getEnv(key, defaultValue), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvInt gets a non-negative integer environment variable or returns a
+// default value if it is unset or invalid
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		slog.Warn("Invalid "+key+", using the default", "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+// getEnvBool gets a boolean environment variable, such as "true" or "0", or
+// returns a default value if it is unset or invalid
+func getEnvBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		slog.Warn("Invalid "+key+", using the default", "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return b
+}
+
+// getEnvDuration gets a positive duration environment variable, such as
+// "30m", or returns a default value if it is unset or invalid
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		slog.Warn("Invalid "+key+", using the default", "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return d
+}
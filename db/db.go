@@ -3,35 +3,43 @@ package db
 import (
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
 
 	"github.com/bournemouth-uni-it-api-go/config"
-	_ "github.com/lib/pq"
+	"github.com/bournemouth-uni-it-api-go/metrics"
+	"github.com/lib/pq"
 )
 
-// InitDB initializes the database connection
-func InitDB(cfg *config.Config) (*sql.DB, error) {
-	db, err := sql.Open("postgres", cfg.GetDBConnectionString())
+// InitDB initializes the database connection. If m is not nil, the duration
+// of every query is recorded in it.
+func InitDB(cfg *config.Config, m *metrics.Metrics) (*sql.DB, error) {
+	connector, err := pq.NewConnector(cfg.GetDBConnectionString())
 	if err != nil {
 		return nil, err
 	}
+	var db *sql.DB
+	if m != nil {
+		db = sql.OpenDB(m.Connector(connector))
+	} else {
+		db = sql.OpenDB(connector)
+	}
 
 	if err = db.Ping(); err != nil {
 		return nil, err
 	}
 
-	log.Println("Database connection established")
+	slog.Info("Database connection established")
 	return db, nil
 }
 
 // CreateDBIfNotExists creates the database if it doesn't exist
 func CreateDBIfNotExists(cfg *config.Config) error {
-	log.Printf("Connecting to PostgreSQL to create database '%s'...", cfg.DBName)
+	slog.Info("Connecting to PostgreSQL to create database", "database", cfg.DBName)
 
 	// Connect to PostgreSQL default database
 	connStr := cfg.GetDBConnectionStringWithoutDB()
-	log.Printf("Connection string (without password): host=%s port=%s user=%s dbname=postgres sslmode=%s",
-		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBSSLMode)
+	slog.Debug("Connecting without password",
+		"host", cfg.DBHost, "port", cfg.DBPort, "user", cfg.DBUser, "dbname", "postgres", "sslmode", cfg.DBSSLMode)
 
 	db, err := sql.Open("postgres", connStr)
 	if err != nil {
@@ -39,7 +47,7 @@ func CreateDBIfNotExists(cfg *config.Config) error {
 	}
 	defer func() {
 		if closeErr := db.Close(); closeErr != nil {
-			log.Printf("Error closing database: %v", closeErr)
+			slog.Error("Error closing database", "error", closeErr)
 		}
 	}()
 
@@ -62,9 +70,9 @@ func CreateDBIfNotExists(cfg *config.Config) error {
 		if err != nil {
 			return fmt.Errorf("failed to create database '%s': %w", cfg.DBName, err)
 		}
-		log.Printf("Database '%s' created successfully", cfg.DBName)
+		slog.Info("Database created", "database", cfg.DBName)
 	} else {
-		log.Printf("Database '%s' already exists", cfg.DBName)
+		slog.Info("Database already exists", "database", cfg.DBName)
 	}
 
 	return nil
@@ -80,7 +88,7 @@ func InsertSampleData(db *sql.DB) error {
 	}
 
 	if count > 0 {
-		log.Printf("Students table already has %d records", count)
+		slog.Info("Students table already has records", "count", count)
 		return nil
 	}
 
@@ -89,9 +97,9 @@ func InsertSampleData(db *sql.DB) error {
 		firstName, lastName, email, studentID, course string
 		yearOfStudy                                   int
 	}{
-		{"John", "Doe", "john.doe@bournemouth.ac.uk", "S12345678", "Information Technology", 2},
-		{"Jane", "Smith", "jane.smith@bournemouth.ac.uk", "S87654321", "Computer Science", 3},
-		{"Bob", "Johnson", "bob.johnson@bournemouth.ac.uk", "S11111111", "Software Engineering", 1},
+		{"John", "Doe", "john.doe@bournemouth.ac.uk", "S12345678", "BSC-IT", 2},
+		{"Jane", "Smith", "jane.smith@bournemouth.ac.uk", "S87654321", "BSC-CS", 3},
+		{"Bob", "Johnson", "bob.johnson@bournemouth.ac.uk", "S11111111", "BSC-SE", 1},
 	}
 
 	for _, student := range sampleData {
@@ -101,12 +109,12 @@ func InsertSampleData(db *sql.DB) error {
 		`, student.firstName, student.lastName, student.email, student.studentID, student.course, student.yearOfStudy)
 
 		if err != nil {
-			log.Printf("Warning: Failed to insert sample student %s %s: %v", student.firstName, student.lastName, err)
+			slog.Warn("Failed to insert sample student", "student_id", student.studentID, "error", err)
 		} else {
-			log.Printf("Inserted sample student: %s %s", student.firstName, student.lastName)
+			slog.Info("Inserted sample student", "student_id", student.studentID)
 		}
 	}
 
-	log.Println("Sample data insertion completed")
+	slog.Info("Sample data insertion completed")
 	return nil
 }
@@ -2,7 +2,7 @@ package db
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 
 	"github.com/bournemouth-uni-it-api-go/config"
 	"github.com/golang-migrate/migrate/v4"
@@ -19,13 +19,13 @@ func RunMigrations(cfg *config.Config) error {
 	}
 
 	// Connect to the database
-	db, err := InitDB(cfg)
+	db, err := InitDB(cfg, nil)
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
 	defer func() {
 		if closeErr := db.Close(); closeErr != nil {
-			log.Printf("Error closing database: %v", closeErr)
+			slog.Error("Error closing database", "error", closeErr)
 		}
 	}()
 
@@ -47,6 +47,6 @@ func RunMigrations(cfg *config.Config) error {
 		return fmt.Errorf("failed to run migrations: %w", err)
 	}
 
-	log.Println("Migrations completed successfully")
+	slog.Info("Migrations completed successfully")
 	return nil
 }
@@ -0,0 +1,320 @@
+package handlers
+
+import (
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/bournemouth-uni-it-api-go/policy"
+	"github.com/bournemouth-uni-it-api-go/problem"
+	"github.com/gin-gonic/gin"
+)
+
+// adminRole is the seeded role that manages access. It cannot be deleted or
+// lose roles:manage, so that access can always be administered.
+const adminRole = "admin"
+
+// roleNameRegex matches valid role names
+var roleNameRegex = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,49}$`)
+
+// AccessHandler handles HTTP requests for roles, role members and personal
+// tutor assignments
+type AccessHandler struct {
+	Repo models.AccessRepository
+}
+
+// NewAccessHandler creates a new AccessHandler
+func NewAccessHandler(db *sql.DB) *AccessHandler {
+	return &AccessHandler{
+		Repo: models.NewPostgresAccessRepository(db),
+	}
+}
+
+// roleRequest is the body of PUT /api/v1/admin/roles/:name
+type roleRequest struct {
+	Description string   `json:"description" binding:"max=500"`
+	Permissions []string `json:"permissions" binding:"required"`
+}
+
+// GetRoles handles GET requests to retrieve all roles with their permissions
+func (h *AccessHandler) GetRoles(c *gin.Context) {
+	roles, err := h.Repo.WithAudit(requestAudit(c)).ListRoles()
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error getting roles", "error", err)
+		problem.Internal(c, "Failed to retrieve roles")
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+// GetRole handles GET requests to retrieve a role by name
+func (h *AccessHandler) GetRole(c *gin.Context) {
+	role, ok := h.findRole(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+// PutRole handles PUT requests to create a role or replace its description
+// and permissions
+func (h *AccessHandler) PutRole(c *gin.Context) {
+	name, ok := roleName(c)
+	if !ok {
+		return
+	}
+
+	var req roleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err, &req)
+		return
+	}
+
+	permissions := make([]string, 0, len(req.Permissions))
+	for _, permission := range req.Permissions {
+		permission = strings.TrimSpace(permission)
+		if !policy.IsValidPermission(permission) {
+			problem.Abort(c, problem.New(http.StatusUnprocessableEntity, problem.RoleUnknownPermission, "Unknown permission "+strconv.Quote(permission)).
+				With("permissions", policy.Permissions))
+			return
+		}
+		permissions = append(permissions, permission)
+	}
+	sort.Strings(permissions)
+
+	if name == adminRole && !containsString(permissions, policy.RolesManage) {
+		problem.Write(c, http.StatusConflict, problem.RoleProtected, "The admin role must keep "+policy.RolesManage)
+		return
+	}
+
+	role := models.Role{Name: name, Description: strings.TrimSpace(req.Description), Permissions: permissions}
+	if err := h.Repo.WithAudit(requestAudit(c)).SaveRole(&role); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error saving role", "error", err)
+		problem.Internal(c, "Failed to save role")
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+// DeleteRole handles DELETE requests to remove a role and its memberships
+func (h *AccessHandler) DeleteRole(c *gin.Context) {
+	name, ok := roleName(c)
+	if !ok {
+		return
+	}
+
+	if name == adminRole {
+		problem.Write(c, http.StatusConflict, problem.RoleProtected, "The admin role cannot be deleted")
+		return
+	}
+
+	if err := h.Repo.WithAudit(requestAudit(c)).DeleteRole(name); err != nil {
+		if err == sql.ErrNoRows {
+			problem.Write(c, http.StatusNotFound, problem.RoleNotFound, "Role not found")
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "Error deleting role", "error", err)
+		problem.Internal(c, "Failed to delete role")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
+}
+
+// GetRoleMembers handles GET requests to retrieve the subjects that have been
+// made members of a role
+func (h *AccessHandler) GetRoleMembers(c *gin.Context) {
+	role, ok := h.findRole(c)
+	if !ok {
+		return
+	}
+
+	members, err := h.Repo.WithAudit(requestAudit(c)).ListMembers(role.Name)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error getting role members", "error", err)
+		problem.Internal(c, "Failed to retrieve role members")
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+// AddRoleMember handles PUT requests to grant a role to a subject
+func (h *AccessHandler) AddRoleMember(c *gin.Context) {
+	role, ok := h.findRole(c)
+	if !ok {
+		return
+	}
+	subject, ok := subjectParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Repo.WithAudit(requestAudit(c)).AddMember(role.Name, subject); err != nil {
+		if foreignKeyViolation(err) {
+			problem.Write(c, http.StatusNotFound, problem.RoleNotFound, "Role not found")
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "Error adding role member", "error", err)
+		problem.Internal(c, "Failed to add role member")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"role": role.Name, "subject": subject})
+}
+
+// RemoveRoleMember handles DELETE requests to revoke a role from a subject
+func (h *AccessHandler) RemoveRoleMember(c *gin.Context) {
+	name, ok := roleName(c)
+	if !ok {
+		return
+	}
+	subject, ok := subjectParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Repo.WithAudit(requestAudit(c)).RemoveMember(name, subject); err != nil {
+		if err == sql.ErrNoRows {
+			problem.Write(c, http.StatusNotFound, problem.RoleMemberNotFound, "Subject is not a member of the role")
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "Error removing role member", "error", err)
+		problem.Internal(c, "Failed to remove role member")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role member removed successfully"})
+}
+
+// GetTutees handles GET requests to retrieve the IDs of the students a
+// personal tutor is assigned to
+func (h *AccessHandler) GetTutees(c *gin.Context) {
+	tutor, ok := subjectParam(c)
+	if !ok {
+		return
+	}
+
+	ids, err := h.Repo.WithAudit(requestAudit(c)).ListTutees(tutor)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error getting tutees", "error", err)
+		problem.Internal(c, "Failed to retrieve tutees")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tutor": tutor, "student_ids": ids})
+}
+
+// AssignTutee handles PUT requests to make a subject the personal tutor of a
+// student
+func (h *AccessHandler) AssignTutee(c *gin.Context) {
+	tutor, ok := subjectParam(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, "Invalid student ID")
+		return
+	}
+
+	if err := h.Repo.WithAudit(requestAudit(c)).AssignTutee(tutor, id); err != nil {
+		if foreignKeyViolation(err) {
+			problem.Write(c, http.StatusNotFound, problem.StudentNotFound, "Student not found")
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "Error assigning tutee", "error", err)
+		problem.Internal(c, "Failed to assign tutee")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tutor": tutor, "student_id": id})
+}
+
+// UnassignTutee handles DELETE requests to remove a personal tutor assignment
+func (h *AccessHandler) UnassignTutee(c *gin.Context) {
+	tutor, ok := subjectParam(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, "Invalid student ID")
+		return
+	}
+
+	if err := h.Repo.WithAudit(requestAudit(c)).UnassignTutee(tutor, id); err != nil {
+		if err == sql.ErrNoRows {
+			problem.Write(c, http.StatusNotFound, problem.TuteeNotFound, "Student is not a tutee of the tutor")
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "Error unassigning tutee", "error", err)
+		problem.Internal(c, "Failed to unassign tutee")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tutee unassigned successfully"})
+}
+
+// findRole looks up the role named by the :name route parameter. It writes an
+// error response and returns false if there is no such role.
+func (h *AccessHandler) findRole(c *gin.Context) (*models.Role, bool) {
+	name, ok := roleName(c)
+	if !ok {
+		return nil, false
+	}
+
+	role, err := h.Repo.WithAudit(requestAudit(c)).GetRole(name)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error getting role", "error", err)
+		problem.Internal(c, "Failed to retrieve role")
+		return nil, false
+	}
+
+	if role == nil {
+		problem.Write(c, http.StatusNotFound, problem.RoleNotFound, "Role not found")
+		return nil, false
+	}
+
+	return role, true
+}
+
+// roleName reads the :name route parameter. It writes an error response and
+// returns false if it is not a valid role name.
+func roleName(c *gin.Context) (string, bool) {
+	name := strings.ToLower(strings.TrimSpace(c.Param("name")))
+	if !roleNameRegex.MatchString(name) {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, "Invalid role name, must be lowercase letters, digits, - or _")
+		return "", false
+	}
+	return name, true
+}
+
+// subjectParam reads the :subject route parameter. It writes an error
+// response and returns false if it is empty or too long.
+func subjectParam(c *gin.Context) (string, bool) {
+	subject := strings.TrimSpace(c.Param("subject"))
+	if subject == "" || len(subject) > 255 {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, "Invalid subject")
+		return "", false
+	}
+	return subject, true
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
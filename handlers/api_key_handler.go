@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/bournemouth-uni-it-api-go/policy"
+	"github.com/bournemouth-uni-it-api-go/problem"
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHandler handles HTTP requests for API keys
+type APIKeyHandler struct {
+	Repo models.APIKeyRepository
+	// Policy limits the scopes of issued keys to permissions the caller
+	// holds. Without one any scope may be issued.
+	Policy *policy.Policy
+}
+
+// NewAPIKeyHandler creates a new APIKeyHandler
+func NewAPIKeyHandler(db *sql.DB) *APIKeyHandler {
+	return &APIKeyHandler{
+		Repo:   models.NewPostgresAPIKeyRepository(db),
+		Policy: policy.New(models.NewPostgresAccessRepository(db)),
+	}
+}
+
+// apiKeyRequest is the body of POST /api/v1/admin/api-keys
+type apiKeyRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// issuedAPIKey is the response to issuing a key. Key is only ever returned here.
+type issuedAPIKey struct {
+	APIKey *models.APIKey `json:"api_key"`
+	Key    string         `json:"key"`
+}
+
+// GetAPIKeys handles GET requests to retrieve all API keys. Secrets are never
+// returned.
+func (h *APIKeyHandler) GetAPIKeys(c *gin.Context) {
+	keys, err := h.Repo.WithAudit(requestAudit(c)).List()
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error getting API keys", "error", err)
+		problem.Internal(c, "Failed to retrieve API keys")
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+// GetAPIKey handles GET requests to retrieve an API key by ID
+func (h *APIKeyHandler) GetAPIKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, "Invalid API key ID")
+		return
+	}
+
+	key, err := h.Repo.WithAudit(requestAudit(c)).GetByID(id)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error getting API key", "error", err)
+		problem.Internal(c, "Failed to retrieve API key")
+		return
+	}
+
+	if key == nil {
+		problem.Write(c, http.StatusNotFound, problem.APIKeyNotFound, "API key not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, key)
+}
+
+// CreateAPIKey handles POST requests to issue a new API key. The key is
+// returned once and cannot be retrieved again.
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	var req apiKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err, &req)
+		return
+	}
+
+	scopes := make([]string, 0, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		scope = strings.TrimSpace(scope)
+		if !policy.IsValidPermission(scope) {
+			problem.Abort(c, problem.New(http.StatusUnprocessableEntity, problem.APIKeyUnknownScope, "Unknown scope "+strconv.Quote(scope)).
+				With("scopes", policy.Permissions))
+			return
+		}
+		if !containsString(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	sort.Strings(scopes)
+
+	// A key cannot be given more than its issuer holds, or api-keys:manage
+	// would grant every permission
+	if h.Policy != nil {
+		missing, err := h.Policy.WithAudit(requestAudit(c)).Missing(policySubject(c), scopes)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Error evaluating access policy", "error", err)
+			problem.Internal(c, "Failed to check permissions")
+			return
+		}
+		if len(missing) > 0 {
+			problem.Abort(c, problem.New(http.StatusForbidden, problem.APIKeyScopeNotHeld, "Cannot issue scopes you do not hold: "+strings.Join(missing, ", ")).
+				With("scopes", missing))
+			return
+		}
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		problem.Write(c, http.StatusUnprocessableEntity, problem.APIKeyInvalidExpiration, "expires_at must be in the future")
+		return
+	}
+
+	key := models.APIKey{
+		Name:      strings.TrimSpace(req.Name),
+		Scopes:    scopes,
+		ExpiresAt: req.ExpiresAt,
+		CreatedBy: requestAudit(c).Actor,
+	}
+	plaintext, err := h.Repo.WithAudit(requestAudit(c)).Create(&key)
+	if err != nil {
+		if _, _, ok := uniqueViolationMessage(err); ok {
+			problem.Write(c, http.StatusConflict, problem.APIKeyNameTaken, "API key name already exists")
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "Error creating API key", "error", err)
+		problem.Internal(c, "Failed to create API key")
+		return
+	}
+
+	c.JSON(http.StatusCreated, issuedAPIKey{APIKey: &key, Key: plaintext})
+}
+
+// RotateAPIKey handles POST requests to replace the secret of an API key. The
+// old key stops working immediately.
+func (h *APIKeyHandler) RotateAPIKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, "Invalid API key ID")
+		return
+	}
+
+	key, plaintext, err := h.Repo.WithAudit(requestAudit(c)).Rotate(id)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error rotating API key", "error", err)
+		problem.Internal(c, "Failed to rotate API key")
+		return
+	}
+
+	if key == nil {
+		// Tell a revoked key apart from one that does not exist
+		existing, err := h.Repo.WithAudit(requestAudit(c)).GetByID(id)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Error getting API key", "error", err)
+			problem.Internal(c, "Failed to retrieve API key")
+			return
+		}
+		if existing != nil {
+			problem.Write(c, http.StatusConflict, problem.APIKeyAlreadyRevoked, "API key has been revoked")
+			return
+		}
+		problem.Write(c, http.StatusNotFound, problem.APIKeyNotFound, "API key not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, issuedAPIKey{APIKey: key, Key: plaintext})
+}
+
+// RevokeAPIKey handles DELETE requests to permanently disable an API key. The
+// key is kept so that its use remains traceable.
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, "Invalid API key ID")
+		return
+	}
+
+	key, err := h.Repo.WithAudit(requestAudit(c)).Revoke(id)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error revoking API key", "error", err)
+		problem.Internal(c, "Failed to revoke API key")
+		return
+	}
+
+	if key == nil {
+		problem.Write(c, http.StatusNotFound, problem.APIKeyNotFound, "API key not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, key)
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/bournemouth-uni-it-api-go/policy"
+	"github.com/bournemouth-uni-it-api-go/problem"
+	"github.com/gin-gonic/gin"
+)
+
+// AssessmentHandler handles HTTP requests for assessments and marks
+type AssessmentHandler struct {
+	Repo     models.AssessmentRepository
+	Students models.StudentRepository
+	// Policy decides who may read a student's marks. Without one every
+	// request is allowed.
+	Policy *policy.Policy
+}
+
+// NewAssessmentHandler creates a new AssessmentHandler
+func NewAssessmentHandler(db *sql.DB) *AssessmentHandler {
+	return &AssessmentHandler{
+		Repo:     models.NewPostgresAssessmentRepository(db),
+		Students: models.NewPostgresStudentRepository(db),
+		Policy:   policy.New(models.NewPostgresAccessRepository(db)),
+	}
+}
+
+// submitMarkRequest is the body of POST /api/v1/assessments/:id/marks
+type submitMarkRequest struct {
+	StudentID    int      `json:"student_id" binding:"required"`
+	AcademicYear string   `json:"academic_year" binding:"required"`
+	Mark         *float64 `json:"mark" binding:"required"`
+}
+
+// moderateMarkRequest is the body of POST /api/v1/marks/:id/moderate. A
+// missing mark confirms the submitted one.
+type moderateMarkRequest struct {
+	Mark *float64 `json:"mark"`
+	Note string   `json:"note"`
+}
+
+// markError writes the response for an error from a mark operation
+func markError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, models.ErrStudentNotFound):
+		problem.Write(c, http.StatusNotFound, problem.StudentNotFound, "Student not found")
+	case errors.Is(err, models.ErrModuleNotFound):
+		problem.Write(c, http.StatusNotFound, problem.ModuleNotFound, "Module not found")
+	case errors.Is(err, models.ErrAssessmentNotFound):
+		problem.Write(c, http.StatusNotFound, problem.AssessmentNotFound, "Assessment not found")
+	case errors.Is(err, models.ErrWeightingExceeded):
+		problem.Write(c, http.StatusUnprocessableEntity, problem.WeightingExceeded, "Assessment weightings for the module would exceed 100")
+	case errors.Is(err, models.ErrNotEnrolled):
+		problem.Write(c, http.StatusUnprocessableEntity, problem.NotEnrolled, "Student is not enrolled on the module for that academic year")
+	case errors.Is(err, models.ErrMarkOutOfRange):
+		problem.Write(c, http.StatusUnprocessableEntity, problem.MarkOutOfRange, "Mark must be between 0 and the assessment's maximum mark")
+	case errors.Is(err, models.ErrMarkPublished):
+		problem.Write(c, http.StatusConflict, problem.MarkAlreadyPublished, "Mark has already been published")
+	default:
+		slog.ErrorContext(c.Request.Context(), "Error "+action, "error", err)
+		problem.Internal(c, "Failed "+action)
+	}
+}
+
+// idParam parses the :id route parameter, writing a 400 response naming
+// what if it is invalid
+func idParam(c *gin.Context, what string) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, "Invalid "+what+" ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// GetModuleAssessments handles GET requests to list a module's assessments
+func (h *AssessmentHandler) GetModuleAssessments(c *gin.Context) {
+	assessments, err := h.Repo.WithAudit(requestAudit(c)).ListAssessments(strings.ToUpper(strings.TrimSpace(c.Param("code"))))
+	if err != nil {
+		markError(c, err, "to retrieve assessments")
+		return
+	}
+
+	c.JSON(http.StatusOK, assessments)
+}
+
+// CreateAssessment handles POST requests to add an assessment to a module.
+// The weightings of a module's assessments may not exceed 100.
+func (h *AssessmentHandler) CreateAssessment(c *gin.Context) {
+	var assessment models.Assessment
+	if err := c.ShouldBindJSON(&assessment); err != nil {
+		bindError(c, err, &assessment)
+		return
+	}
+	assessment.ModuleCode = strings.ToUpper(strings.TrimSpace(c.Param("code")))
+
+	if err := h.Repo.WithAudit(requestAudit(c)).CreateAssessment(&assessment); err != nil {
+		markError(c, err, "to create assessment")
+		return
+	}
+
+	c.JSON(http.StatusCreated, assessment)
+}
+
+// SubmitMark handles POST requests to record a student's mark for an
+// assessment. Submitting again replaces the mark until it is published.
+func (h *AssessmentHandler) SubmitMark(c *gin.Context) {
+	id, ok := idParam(c, "assessment")
+	if !ok {
+		return
+	}
+
+	var req submitMarkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err, &req)
+		return
+	}
+	if !models.IsValidAcademicYear(req.AcademicYear) {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, "Invalid academic_year, expected a year such as 2024/25")
+		return
+	}
+
+	mark, err := h.Repo.WithAudit(requestAudit(c)).SubmitMark(id, req.StudentID, req.AcademicYear, *req.Mark)
+	if err != nil {
+		markError(c, err, "to submit mark")
+		return
+	}
+
+	c.JSON(http.StatusOK, mark)
+}
+
+// ModerateMark handles POST requests to record the moderation of a mark
+func (h *AssessmentHandler) ModerateMark(c *gin.Context) {
+	id, ok := idParam(c, "mark")
+	if !ok {
+		return
+	}
+
+	var req moderateMarkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err, &req)
+		return
+	}
+
+	mark, err := h.Repo.WithAudit(requestAudit(c)).ModerateMark(id, req.Mark, strings.TrimSpace(req.Note))
+	if err != nil {
+		markError(c, err, "to moderate mark")
+		return
+	}
+	if mark == nil {
+		problem.Write(c, http.StatusNotFound, problem.MarkNotFound, "Mark not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, mark)
+}
+
+// PublishMark handles POST requests to release a single mark to the student
+func (h *AssessmentHandler) PublishMark(c *gin.Context) {
+	id, ok := idParam(c, "mark")
+	if !ok {
+		return
+	}
+
+	mark, err := h.Repo.WithAudit(requestAudit(c)).PublishMark(id)
+	if err != nil {
+		markError(c, err, "to publish mark")
+		return
+	}
+	if mark == nil {
+		problem.Write(c, http.StatusNotFound, problem.MarkNotFound, "Mark not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, mark)
+}
+
+// PublishAssessment handles POST requests to release every mark for an
+// assessment at once
+func (h *AssessmentHandler) PublishAssessment(c *gin.Context) {
+	id, ok := idParam(c, "assessment")
+	if !ok {
+		return
+	}
+
+	published, err := h.Repo.WithAudit(requestAudit(c)).PublishAssessment(id)
+	if err != nil {
+		markError(c, err, "to publish marks")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"published": published})
+}
+
+// GetStudentMarks handles GET requests to retrieve a student's published
+// marks and weighted module totals, optionally for one academic year. Staff,
+// the student and their tutors see the same published marks; unpublished
+// marks are never returned here.
+func (h *AssessmentHandler) GetStudentMarks(c *gin.Context) {
+	id, ok := idParam(c, "student")
+	if !ok {
+		return
+	}
+
+	student, err := h.Students.GetByID(id)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error getting student by ID", "error", err)
+		problem.Internal(c, "Failed to retrieve student")
+		return
+	}
+	if !canReadStudent(c, h.Policy, student) {
+		return
+	}
+	if student == nil {
+		problem.Write(c, http.StatusNotFound, problem.StudentNotFound, "Student not found")
+		return
+	}
+
+	year, err := academicYearParam(c, "")
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, err.Error())
+		return
+	}
+
+	results, err := h.Repo.WithAudit(requestAudit(c)).StudentResults(id, year)
+	if err != nil {
+		markError(c, err, "to retrieve marks")
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
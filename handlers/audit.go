@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"github.com/bournemouth-uni-it-api-go/middleware"
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/gin-gonic/gin"
+)
+
+// requestAudit identifies the caller and request behind a change. The actor
+// is the subject of the authenticated principal, or anonymous if there is none.
+func requestAudit(c *gin.Context) models.Audit {
+	actor := "anonymous"
+	if principal, ok := middleware.GetPrincipal(c); ok {
+		actor = principal.Subject
+	}
+	return models.Audit{Actor: actor, RequestID: c.GetString(middleware.RequestIDKey)}
+}
@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bournemouth-uni-it-api-go/middleware"
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/bournemouth-uni-it-api-go/problem"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
+)
+
+// loginCookie binds a pending login to the browser that started it
+const loginCookie = "bu_login"
+
+// loginTTL is how long a user has to sign in at the identity provider
+const loginTTL = 10 * time.Minute
+
+// OIDCOptions configures OpenID Connect sign-in for the web interface.
+// Sign-in is disabled unless IssuerURL is set.
+type OIDCOptions struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the absolute URL of /auth/callback registered with the
+	// identity provider
+	RedirectURL string
+	Scopes      []string
+	// PostLogoutRedirectURL is where the identity provider sends users after
+	// signing out, if it supports RP-initiated logout
+	PostLogoutRedirectURL string
+	SessionTTL            time.Duration
+	// CookieSecure restricts cookies to HTTPS. It should only be turned off
+	// for local development over plain HTTP.
+	CookieSecure bool
+}
+
+// Enabled reports whether sign-in is configured
+func (o OIDCOptions) Enabled() bool {
+	return o.IssuerURL != ""
+}
+
+// AuthHandler handles sign-in to the web interface with OpenID Connect using
+// the authorisation code flow with PKCE
+type AuthHandler struct {
+	Sessions models.SessionRepository
+	OAuth2   *oauth2.Config
+	Verifier *oidc.IDTokenVerifier
+	// EndSessionURL is the identity provider's logout endpoint, if any
+	EndSessionURL         string
+	PostLogoutRedirectURL string
+	SessionTTL            time.Duration
+	CookieSecure          bool
+}
+
+// NewAuthHandler discovers the identity provider at opts.IssuerURL and
+// creates an AuthHandler that keeps sessions in sessions
+func NewAuthHandler(ctx context.Context, sessions models.SessionRepository, opts OIDCOptions) (*AuthHandler, error) {
+	provider, err := oidc.NewProvider(ctx, opts.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover identity provider %s: %w", opts.IssuerURL, err)
+	}
+
+	var metadata struct {
+		EndSessionEndpoint string `json:"end_session_endpoint"`
+	}
+	if err := provider.Claims(&metadata); err != nil {
+		return nil, fmt.Errorf("invalid identity provider metadata: %w", err)
+	}
+
+	scopes := opts.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "profile", "email"}
+	}
+
+	return &AuthHandler{
+		Sessions: sessions,
+		OAuth2: &oauth2.Config{
+			ClientID:     opts.ClientID,
+			ClientSecret: opts.ClientSecret,
+			RedirectURL:  opts.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       scopes,
+		},
+		Verifier:              provider.Verifier(&oidc.Config{ClientID: opts.ClientID}),
+		EndSessionURL:         metadata.EndSessionEndpoint,
+		PostLogoutRedirectURL: opts.PostLogoutRedirectURL,
+		SessionTTL:            opts.SessionTTL,
+		CookieSecure:          opts.CookieSecure,
+	}, nil
+}
+
+// setCookie sets an HTTP-only cookie. A negative maxAge deletes it.
+func (h *AuthHandler) setCookie(c *gin.Context, name, value, path string, maxAge time.Duration) {
+	seconds := int(maxAge.Seconds())
+	if maxAge < 0 {
+		seconds = -1
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		MaxAge:   seconds,
+		HttpOnly: true,
+		Secure:   h.CookieSecure,
+		// Lax lets the cookies through on the redirect back from the identity
+		// provider but not on cross-site requests that change data
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// localPath returns raw if it is a path on this server, or / otherwise, so
+// that sign-in cannot be used to redirect to another site
+func localPath(raw string) string {
+	if !strings.HasPrefix(raw, "/") || strings.HasPrefix(raw, "//") || strings.Contains(raw, `\`) {
+		return "/"
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.IsAbs() || u.Host != "" {
+		return "/"
+	}
+	return raw
+}
+
+// Login handles GET requests to start signing in. The browser is redirected
+// to the identity provider and comes back to Callback. ?return_to= names the
+// local page to show afterwards.
+func (h *AuthHandler) Login(c *gin.Context) {
+	state, err := models.RandomToken(32)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error generating login state", "error", err)
+		problem.Internal(c, "Failed to start sign-in")
+		return
+	}
+	nonce, err := models.RandomToken(32)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error generating login nonce", "error", err)
+		problem.Internal(c, "Failed to start sign-in")
+		return
+	}
+
+	login := models.LoginAttempt{
+		State:        state,
+		CodeVerifier: oauth2.GenerateVerifier(),
+		Nonce:        nonce,
+		ReturnTo:     localPath(c.DefaultQuery("return_to", "/")),
+	}
+	if err := h.Sessions.CreateLogin(&login, loginTTL); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error saving login", "error", err)
+		problem.Internal(c, "Failed to start sign-in")
+		return
+	}
+
+	h.setCookie(c, loginCookie, state, "/auth", loginTTL)
+	c.Redirect(http.StatusFound, h.OAuth2.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(login.CodeVerifier)))
+}
+
+// Callback handles the redirect back from the identity provider. The
+// authorisation code is exchanged for an ID token, which starts a session.
+func (h *AuthHandler) Callback(c *gin.Context) {
+	if errCode := c.Query("error"); errCode != "" {
+		problem.Abort(c, problem.New(http.StatusUnauthorized, problem.SignInFailed, "Sign-in failed: "+errCode).
+			With("description", c.Query("error_description")))
+		return
+	}
+
+	// The state must match the cookie set by Login, so that a login started
+	// in one browser cannot be completed in another
+	state := c.Query("state")
+	cookieState, _ := c.Cookie(loginCookie)
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(cookieState)) != 1 {
+		problem.Write(c, http.StatusBadRequest, problem.SignInExpired, "Invalid sign-in state, please sign in again")
+		return
+	}
+	h.setCookie(c, loginCookie, "", "/auth", -1)
+
+	login, err := h.Sessions.TakeLogin(state)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error getting login", "error", err)
+		problem.Internal(c, "Failed to complete sign-in")
+		return
+	}
+	if login == nil {
+		problem.Write(c, http.StatusBadRequest, problem.SignInExpired, "Sign-in has expired, please sign in again")
+		return
+	}
+
+	ctx := c.Request.Context()
+	token, err := h.OAuth2.Exchange(ctx, c.Query("code"), oauth2.VerifierOption(login.CodeVerifier))
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error exchanging authorisation code", "error", err)
+		problem.Write(c, http.StatusUnauthorized, problem.SignInFailed, "Sign-in failed")
+		return
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		problem.Write(c, http.StatusUnauthorized, problem.SignInFailed, "Sign-in failed: no ID token")
+		return
+	}
+	idToken, err := h.Verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error verifying ID token", "error", err)
+		problem.Write(c, http.StatusUnauthorized, problem.SignInFailed, "Sign-in failed: invalid ID token")
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(login.Nonce)) != 1 {
+		problem.Write(c, http.StatusUnauthorized, problem.SignInFailed, "Sign-in failed: invalid ID token")
+		return
+	}
+
+	var claims struct {
+		Name  string   `json:"name"`
+		Email string   `json:"email"`
+		Roles []string `json:"roles"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error reading ID token claims", "error", err)
+		problem.Write(c, http.StatusUnauthorized, problem.SignInFailed, "Sign-in failed: invalid ID token")
+		return
+	}
+
+	session := models.Session{
+		Subject: idToken.Subject,
+		Name:    claims.Name,
+		Email:   claims.Email,
+		Roles:   claims.Roles,
+		IDToken: rawIDToken,
+	}
+	id, err := h.Sessions.Create(&session, h.SessionTTL)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error creating session", "error", err)
+		problem.Internal(c, "Failed to complete sign-in")
+		return
+	}
+
+	h.setCookie(c, middleware.SessionCookie, id, "/", h.SessionTTL)
+	c.Redirect(http.StatusFound, login.ReturnTo)
+}
+
+// Session handles GET requests for the signed-in user and the CSRF token to
+// send with requests that change data
+func (h *AuthHandler) Session(c *gin.Context) {
+	session, ok := h.currentSession(c)
+	if !ok {
+		return
+	}
+	if session == nil {
+		problem.Write(c, http.StatusUnauthorized, problem.NotSignedIn, "Not signed in")
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"subject":    session.Subject,
+		"name":       session.Name,
+		"email":      session.Email,
+		"roles":      session.Roles,
+		"csrf_token": session.CSRFToken,
+		"expires_at": session.ExpiresAt,
+	})
+}
+
+// Logout handles POST requests to end the session. If the identity provider
+// supports it, the browser is sent there to sign out as well.
+func (h *AuthHandler) Logout(c *gin.Context) {
+	session, ok := h.currentSession(c)
+	if !ok {
+		return
+	}
+
+	target := "/"
+	if session != nil {
+		id, _ := c.Cookie(middleware.SessionCookie)
+		if err := h.Sessions.Delete(id); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Error deleting session", "error", err)
+			problem.Internal(c, "Failed to sign out")
+			return
+		}
+		if h.EndSessionURL != "" {
+			target = h.endSessionURL(session.IDToken)
+		}
+	}
+
+	h.setCookie(c, middleware.SessionCookie, "", "/", -1)
+	c.Redirect(http.StatusSeeOther, target)
+}
+
+// endSessionURL returns the identity provider's logout URL for an ID token
+func (h *AuthHandler) endSessionURL(idToken string) string {
+	query := url.Values{"id_token_hint": {idToken}, "client_id": {h.OAuth2.ClientID}}
+	if h.PostLogoutRedirectURL != "" {
+		query.Set("post_logout_redirect_uri", h.PostLogoutRedirectURL)
+	}
+	separator := "?"
+	if strings.Contains(h.EndSessionURL, "?") {
+		separator = "&"
+	}
+	return h.EndSessionURL + separator + query.Encode()
+}
+
+// currentSession returns the session named by the session cookie, or nil if
+// there is none. It writes an error response and returns false if the session
+// cannot be looked up.
+func (h *AuthHandler) currentSession(c *gin.Context) (*models.Session, bool) {
+	id, err := c.Cookie(middleware.SessionCookie)
+	if err != nil || id == "" {
+		return nil, true
+	}
+
+	session, err := h.Sessions.Get(id)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error getting session", "error", err)
+		problem.Internal(c, "Failed to retrieve session")
+		return nil, false
+	}
+	return session, true
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/bournemouth-uni-it-api-go/middleware"
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/bournemouth-uni-it-api-go/policy"
+	"github.com/bournemouth-uni-it-api-go/problem"
+	"github.com/gin-gonic/gin"
+)
+
+// policySubject returns the authenticated caller as a policy subject. The
+// scopes of an API key are its permissions.
+func policySubject(c *gin.Context) policy.Subject {
+	principal, ok := middleware.GetPrincipal(c)
+	if !ok {
+		return policy.Subject{}
+	}
+	s := policy.Subject{ID: principal.Subject, Roles: principal.Roles}
+	if principal.Method == middleware.MethodAPIKey {
+		s.Permissions = principal.Scopes
+	}
+	return s
+}
+
+// authorize evaluates a decision of p for the caller. p is bound to the
+// request so that errors logged while deciding name it. On denial it writes a
+// 403 with the reason and returns false.
+func authorize(c *gin.Context, p *policy.Policy, decide func(*policy.Policy, policy.Subject) (policy.Decision, error)) bool {
+	decision, err := decide(p.WithAudit(requestAudit(c)), policySubject(c))
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error evaluating access policy", "error", err)
+		problem.Internal(c, "Failed to check permissions")
+		return false
+	}
+	if !decision.Allowed {
+		problem.Write(c, http.StatusForbidden, problem.Forbidden, decision.Reason)
+		return false
+	}
+	return true
+}
+
+// RequirePermission is a middleware that rejects callers without permission
+// with 403
+func RequirePermission(p *policy.Policy, permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authorize(c, p, func(p *policy.Policy, s policy.Subject) (policy.Decision, error) {
+			return p.Require(s, permission)
+		}) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// require checks that the caller holds permission. Without a policy every
+// request is allowed.
+func (h *StudentHandler) require(c *gin.Context, permission string) bool {
+	if h.Policy == nil {
+		return true
+	}
+	return authorize(c, h.Policy, func(p *policy.Policy, s policy.Subject) (policy.Decision, error) {
+		return p.Require(s, permission)
+	})
+}
+
+// requireAny checks that the caller holds at least one of permissions
+func (h *StudentHandler) requireAny(c *gin.Context, permissions ...string) bool {
+	if h.Policy == nil {
+		return true
+	}
+	return authorize(c, h.Policy, func(p *policy.Policy, s policy.Subject) (policy.Decision, error) {
+		return p.RequireAny(s, permissions...)
+	})
+}
+
+// canReadStudent checks that the caller may read student, which is nil if it
+// was not found
+func (h *StudentHandler) canReadStudent(c *gin.Context, student *models.Student) bool {
+	return canReadStudent(c, h.Policy, student)
+}
+
+// canReadStudent checks with p that the caller may read student, which is nil
+// if it was not found. Without a policy every request is allowed.
+func canReadStudent(c *gin.Context, p *policy.Policy, student *models.Student) bool {
+	if p == nil {
+		return true
+	}
+	return authorize(c, p, func(p *policy.Policy, s policy.Subject) (policy.Decision, error) {
+		return p.ReadStudent(s, student)
+	})
+}
+
+// canEditStudent checks that the caller may change the given columns of student
+func (h *StudentHandler) canEditStudent(c *gin.Context, student *models.Student, changed map[string]interface{}) bool {
+	if h.Policy == nil {
+		return true
+	}
+	columns := make([]string, 0, len(changed))
+	for column := range changed {
+		columns = append(columns, column)
+	}
+	return authorize(c, h.Policy, func(p *policy.Policy, s policy.Subject) (policy.Decision, error) {
+		return p.EditStudent(s, student, columns)
+	})
+}
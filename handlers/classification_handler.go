@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/bournemouth-uni-it-api-go/classification"
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/bournemouth-uni-it-api-go/policy"
+	"github.com/bournemouth-uni-it-api-go/problem"
+	"github.com/gin-gonic/gin"
+)
+
+// ClassificationHandler handles HTTP requests for degree classifications
+type ClassificationHandler struct {
+	Students models.StudentRepository
+	Results  models.AssessmentRepository
+	Rules    classification.Rules
+	// Policy decides who may read a student's classification. Without one
+	// every request is allowed.
+	Policy *policy.Policy
+}
+
+// NewClassificationHandler creates a new ClassificationHandler
+func NewClassificationHandler(db *sql.DB, rules classification.Rules) *ClassificationHandler {
+	return &ClassificationHandler{
+		Students: models.NewPostgresStudentRepository(db),
+		Results:  models.NewPostgresAssessmentRepository(db),
+		Rules:    rules,
+		Policy:   policy.New(models.NewPostgresAccessRepository(db)),
+	}
+}
+
+// classificationResponse is the body of GET /api/v1/students/:id/classification
+type classificationResponse struct {
+	StudentID int `json:"student_id"`
+	*classification.Result
+}
+
+// classificationModules converts a student's module results into the
+// completed modules to classify and the codes of modules still pending. A
+// module taken in more than one year counts its latest result.
+func classificationModules(results []models.ModuleResult, rules classification.Rules) ([]classification.Module, []string) {
+	latest := make(map[string]models.ModuleResult)
+	var order []string
+	for _, r := range results {
+		if _, seen := latest[r.ModuleCode]; !seen {
+			order = append(order, r.ModuleCode)
+		}
+		// Results are ordered by academic year, so later attempts win
+		latest[r.ModuleCode] = r
+	}
+
+	modules := []classification.Module{}
+	pending := []string{}
+	for _, code := range order {
+		r := latest[code]
+		if _, weighted := rules.LevelWeights[r.Level]; !weighted {
+			continue
+		}
+		if r.Total == nil {
+			pending = append(pending, code)
+			continue
+		}
+		modules = append(modules, classification.Module{
+			Code:    r.ModuleCode,
+			Title:   r.ModuleTitle,
+			Level:   r.Level,
+			Credits: r.Credits,
+			Mark:    *r.Total,
+		})
+	}
+	return modules, pending
+}
+
+// GetStudentClassification handles GET requests to calculate a student's
+// degree classification from their published module totals
+func (h *ClassificationHandler) GetStudentClassification(c *gin.Context) {
+	id, ok := idParam(c, "student")
+	if !ok {
+		return
+	}
+
+	student, err := h.Students.GetByID(id)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error getting student by ID", "error", err)
+		problem.Internal(c, "Failed to retrieve student")
+		return
+	}
+	if !canReadStudent(c, h.Policy, student) {
+		return
+	}
+	if student == nil {
+		problem.Write(c, http.StatusNotFound, problem.StudentNotFound, "Student not found")
+		return
+	}
+
+	results, err := h.Results.WithAudit(requestAudit(c)).StudentResults(id, "")
+	if err != nil {
+		markError(c, err, "to retrieve marks")
+		return
+	}
+
+	// Every module that counts needs a final total before the award can be
+	// calculated
+	modules, pending := classificationModules(results, h.Rules)
+	if len(pending) > 0 {
+		problem.Abort(c, problem.New(http.StatusUnprocessableEntity, problem.ClassificationIncomplete, "Modules are awaiting final marks").
+			With("pending_modules", pending))
+		return
+	}
+	result, err := classification.Classify(h.Rules, modules)
+	if err != nil {
+		if errors.Is(err, classification.ErrIncomplete) {
+			problem.Abort(c, problem.New(http.StatusUnprocessableEntity, problem.ClassificationIncomplete, err.Error()).
+				With("pending_modules", pending))
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "Error classifying student", "error", err)
+		problem.Internal(c, "Failed to classify student")
+		return
+	}
+
+	c.JSON(http.StatusOK, classificationResponse{StudentID: id, Result: result})
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/bournemouth-uni-it-api-go/problem"
+	"github.com/gin-gonic/gin"
+)
+
+// CourseHandler handles HTTP requests for courses
+type CourseHandler struct {
+	Repo models.CourseRepository
+}
+
+// NewCourseHandler creates a new CourseHandler
+func NewCourseHandler(db *sql.DB) *CourseHandler {
+	return &CourseHandler{
+		Repo: models.NewPostgresCourseRepository(db),
+	}
+}
+
+// GetAllCourses handles GET requests to retrieve all courses
+func (h *CourseHandler) GetAllCourses(c *gin.Context) {
+	courses, err := h.Repo.WithAudit(requestAudit(c)).List()
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error getting all courses", "error", err)
+		problem.Internal(c, "Failed to retrieve courses")
+		return
+	}
+
+	c.JSON(http.StatusOK, courses)
+}
+
+// GetCourseByCode handles GET requests to retrieve a course by its code
+func (h *CourseHandler) GetCourseByCode(c *gin.Context) {
+	course, ok := h.findCourse(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, course)
+}
+
+// CreateCourse handles POST requests to create a new course
+func (h *CourseHandler) CreateCourse(c *gin.Context) {
+	var course models.Course
+	if err := c.ShouldBindJSON(&course); err != nil {
+		bindError(c, err, &course)
+		return
+	}
+	course.Code = strings.ToUpper(strings.TrimSpace(course.Code))
+
+	if err := h.Repo.WithAudit(requestAudit(c)).Create(&course); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error creating course", "error", err)
+
+		if _, _, ok := uniqueViolationMessage(err); ok {
+			problem.Write(c, http.StatusConflict, problem.CourseCodeTaken, "Course code already exists")
+			return
+		}
+
+		problem.Internal(c, "Failed to create course")
+		return
+	}
+
+	c.JSON(http.StatusCreated, course)
+}
+
+// UpdateCourse handles PUT requests to update an existing course. A new code
+// is applied to every student registered on the course.
+func (h *CourseHandler) UpdateCourse(c *gin.Context) {
+	existingCourse, ok := h.findCourse(c)
+	if !ok {
+		return
+	}
+
+	var course models.Course
+	if err := c.ShouldBindJSON(&course); err != nil {
+		bindError(c, err, &course)
+		return
+	}
+	course.Code = strings.ToUpper(strings.TrimSpace(course.Code))
+	course.ID = existingCourse.ID
+
+	if err := h.Repo.WithAudit(requestAudit(c)).Update(&course); err != nil {
+		if err == sql.ErrNoRows {
+			problem.Write(c, http.StatusNotFound, problem.CourseNotFound, "Course not found")
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "Error updating course", "error", err)
+
+		if _, _, ok := uniqueViolationMessage(err); ok {
+			problem.Write(c, http.StatusConflict, problem.CourseCodeTaken, "Course code already exists")
+			return
+		}
+
+		problem.Internal(c, "Failed to update course")
+		return
+	}
+
+	c.JSON(http.StatusOK, course)
+}
+
+// DeleteCourse handles DELETE requests to remove a course. Courses that still
+// have students registered on them cannot be deleted.
+func (h *CourseHandler) DeleteCourse(c *gin.Context) {
+	existingCourse, ok := h.findCourse(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Repo.WithAudit(requestAudit(c)).Delete(existingCourse.ID); err != nil {
+		if err == sql.ErrNoRows {
+			problem.Write(c, http.StatusNotFound, problem.CourseNotFound, "Course not found")
+			return
+		}
+		if foreignKeyViolation(err) {
+			problem.Write(c, http.StatusConflict, problem.CourseInUse, "Course has students registered on it")
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "Error deleting course", "error", err)
+		problem.Internal(c, "Failed to delete course")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Course deleted successfully"})
+}
+
+// findCourse looks up the course named by the :code route parameter. It
+// writes an error response and returns false if there is no such course.
+func (h *CourseHandler) findCourse(c *gin.Context) (*models.Course, bool) {
+	code := strings.ToUpper(strings.TrimSpace(c.Param("code")))
+	if code == "" {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, "Invalid course code")
+		return nil, false
+	}
+
+	course, err := h.Repo.WithAudit(requestAudit(c)).GetByCode(code)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error getting course", "error", err)
+		problem.Internal(c, "Failed to retrieve course")
+		return nil, false
+	}
+
+	if course == nil {
+		problem.Write(c, http.StatusNotFound, problem.CourseNotFound, "Course not found")
+		return nil, false
+	}
+
+	return course, true
+}
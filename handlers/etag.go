@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/bournemouth-uni-it-api-go/problem"
+	"github.com/gin-gonic/gin"
+)
+
+// etagMatches reports whether etag appears in the comma-separated list of an
+// If-Match or If-None-Match header. Weak comparison ignores the W/ prefix, as
+// required for If-None-Match; strong comparison never matches a weak tag.
+func etagMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		} else if !strings.HasPrefix(candidate, "W/") && candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified sets the ETag header and, if the request's If-None-Match
+// matches it, writes a 304 response and reports true
+func notModified(c *gin.Context, etag string) bool {
+	c.Header("ETag", etag)
+	if header := c.GetHeader("If-None-Match"); header != "" && etagMatches(header, etag, true) {
+		c.Status(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// checkIfMatch enforces the If-Match precondition for a write to student. It
+// writes 428 if the header is missing or 412 if it does not match, and returns
+// false in either case.
+func checkIfMatch(c *gin.Context, student *models.Student) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		problem.Write(c, http.StatusPreconditionRequired, problem.PreconditionRequired, "If-Match header is required")
+		return false
+	}
+	if !etagMatches(header, student.ETag, false) {
+		c.Header("ETag", student.ETag)
+		problem.Write(c, http.StatusPreconditionFailed, problem.StudentModified, "Student has been modified by another request")
+		return false
+	}
+	return true
+}
+
+// jsonWithETag writes obj as JSON with a weak ETag derived from its content,
+// or 304 if the request's If-None-Match already matches it
+func jsonWithETag(c *gin.Context, obj interface{}) {
+	body, err := json.Marshal(obj)
+	if err != nil {
+		problem.Internal(c, "Failed to encode response")
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	if notModified(c, `W/"`+hex.EncodeToString(sum[:16])+`"`) {
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/bournemouth-uni-it-api-go/problem"
+	"github.com/gin-gonic/gin"
+)
+
+// ModuleHandler handles HTTP requests for modules and enrolments
+type ModuleHandler struct {
+	Repo models.ModuleRepository
+}
+
+// NewModuleHandler creates a new ModuleHandler
+func NewModuleHandler(db *sql.DB) *ModuleHandler {
+	return &ModuleHandler{
+		Repo: models.NewPostgresModuleRepository(db),
+	}
+}
+
+// enrolmentRequest is the body of POST /api/v1/students/:id/enrolments
+type enrolmentRequest struct {
+	ModuleCode   string `json:"module_code" binding:"required"`
+	AcademicYear string `json:"academic_year"`
+}
+
+// academicYearParam returns the academic_year query parameter, or def if it
+// is absent
+func academicYearParam(c *gin.Context, def string) (string, error) {
+	year := strings.TrimSpace(c.Query("academic_year"))
+	if year == "" {
+		return def, nil
+	}
+	if !models.IsValidAcademicYear(year) {
+		return "", errors.New("Invalid academic_year, expected a year such as 2024/25")
+	}
+	return year, nil
+}
+
+// enrolmentError writes the response for an error from an enrolment operation
+func enrolmentError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, models.ErrStudentNotFound):
+		problem.Write(c, http.StatusNotFound, problem.StudentNotFound, "Student not found")
+	case errors.Is(err, models.ErrModuleNotFound):
+		problem.Write(c, http.StatusNotFound, problem.ModuleNotFound, "Module not found")
+	case errors.Is(err, models.ErrAlreadyEnrolled):
+		problem.Write(c, http.StatusConflict, problem.AlreadyEnrolled, "Student is already enrolled on this module")
+	case errors.Is(err, models.ErrModuleFull):
+		problem.Write(c, http.StatusConflict, problem.ModuleFull, "Module has no places left")
+	case errors.Is(err, models.ErrEnrolmentHasMarks):
+		problem.Write(c, http.StatusConflict, problem.EnrolmentHasMarks, "Student has marks on this module and cannot be unenrolled; withdraw the student instead")
+	case errors.Is(err, models.ErrLevelMismatch):
+		problem.Write(c, http.StatusUnprocessableEntity, problem.LevelMismatch, "Student's year of study does not match the module level")
+	default:
+		slog.ErrorContext(c.Request.Context(), "Error "+action, "error", err)
+		problem.Internal(c, "Failed "+action)
+	}
+}
+
+// GetAllModules handles GET requests to retrieve all modules
+func (h *ModuleHandler) GetAllModules(c *gin.Context) {
+	modules, err := h.Repo.WithAudit(requestAudit(c)).List()
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error getting all modules", "error", err)
+		problem.Internal(c, "Failed to retrieve modules")
+		return
+	}
+
+	c.JSON(http.StatusOK, modules)
+}
+
+// GetModuleByCode handles GET requests to retrieve a module by its code
+func (h *ModuleHandler) GetModuleByCode(c *gin.Context) {
+	module, ok := h.findModule(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, module)
+}
+
+// CreateModule handles POST requests to create a new module
+func (h *ModuleHandler) CreateModule(c *gin.Context) {
+	var module models.Module
+	if err := c.ShouldBindJSON(&module); err != nil {
+		bindError(c, err, &module)
+		return
+	}
+	module.Code = strings.ToUpper(strings.TrimSpace(module.Code))
+
+	if err := h.Repo.WithAudit(requestAudit(c)).Create(&module); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error creating module", "error", err)
+
+		if _, _, ok := uniqueViolationMessage(err); ok {
+			problem.Write(c, http.StatusConflict, problem.ModuleCodeTaken, "Module code already exists")
+			return
+		}
+
+		problem.Internal(c, "Failed to create module")
+		return
+	}
+
+	c.JSON(http.StatusCreated, module)
+}
+
+// UpdateModule handles PUT requests to update an existing module
+func (h *ModuleHandler) UpdateModule(c *gin.Context) {
+	existingModule, ok := h.findModule(c)
+	if !ok {
+		return
+	}
+
+	var module models.Module
+	if err := c.ShouldBindJSON(&module); err != nil {
+		bindError(c, err, &module)
+		return
+	}
+	module.Code = strings.ToUpper(strings.TrimSpace(module.Code))
+	module.ID = existingModule.ID
+
+	if err := h.Repo.WithAudit(requestAudit(c)).Update(&module); err != nil {
+		if err == sql.ErrNoRows {
+			problem.Write(c, http.StatusNotFound, problem.ModuleNotFound, "Module not found")
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "Error updating module", "error", err)
+
+		if _, _, ok := uniqueViolationMessage(err); ok {
+			problem.Write(c, http.StatusConflict, problem.ModuleCodeTaken, "Module code already exists")
+			return
+		}
+
+		problem.Internal(c, "Failed to update module")
+		return
+	}
+
+	c.JSON(http.StatusOK, module)
+}
+
+// DeleteModule handles DELETE requests to remove a module. Modules with
+// enrolments cannot be deleted.
+func (h *ModuleHandler) DeleteModule(c *gin.Context) {
+	existingModule, ok := h.findModule(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Repo.WithAudit(requestAudit(c)).Delete(existingModule.ID); err != nil {
+		if err == sql.ErrNoRows {
+			problem.Write(c, http.StatusNotFound, problem.ModuleNotFound, "Module not found")
+			return
+		}
+		if foreignKeyViolation(err) {
+			problem.Write(c, http.StatusConflict, problem.ModuleInUse, "Module has students enrolled on it")
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "Error deleting module", "error", err)
+		problem.Internal(c, "Failed to delete module")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Module deleted successfully"})
+}
+
+// GetModuleStudents handles GET requests to list the students enrolled on a
+// module. It defaults to the current academic year.
+func (h *ModuleHandler) GetModuleStudents(c *gin.Context) {
+	code := strings.ToUpper(strings.TrimSpace(c.Param("code")))
+	year, err := academicYearParam(c, models.CurrentAcademicYear(time.Now()))
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, err.Error())
+		return
+	}
+
+	students, err := h.Repo.WithAudit(requestAudit(c)).ListStudents(code, year)
+	if err != nil {
+		enrolmentError(c, err, "to retrieve module students")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"module_code": code, "academic_year": year, "students": students})
+}
+
+// EnrolStudent handles POST requests to enrol a student on a module. The
+// academic year defaults to the current one.
+func (h *ModuleHandler) EnrolStudent(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, "Invalid student ID")
+		return
+	}
+
+	var req enrolmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err, &req)
+		return
+	}
+	if req.AcademicYear == "" {
+		req.AcademicYear = models.CurrentAcademicYear(time.Now())
+	} else if !models.IsValidAcademicYear(req.AcademicYear) {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, "Invalid academic_year, expected a year such as 2024/25")
+		return
+	}
+
+	enrolment, err := h.Repo.WithAudit(requestAudit(c)).Enrol(id, strings.ToUpper(strings.TrimSpace(req.ModuleCode)), req.AcademicYear)
+	if err != nil {
+		enrolmentError(c, err, "to enrol student")
+		return
+	}
+
+	c.JSON(http.StatusCreated, enrolment)
+}
+
+// GetStudentEnrolments handles GET requests to list a student's enrolments,
+// optionally restricted to one academic year
+func (h *ModuleHandler) GetStudentEnrolments(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, "Invalid student ID")
+		return
+	}
+
+	year, err := academicYearParam(c, "")
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, err.Error())
+		return
+	}
+
+	enrolments, err := h.Repo.WithAudit(requestAudit(c)).ListEnrolments(id, year)
+	if err != nil {
+		enrolmentError(c, err, "to retrieve enrolments")
+		return
+	}
+
+	c.JSON(http.StatusOK, enrolments)
+}
+
+// DeleteEnrolment handles DELETE requests to withdraw a student from a
+// module. It defaults to the current academic year.
+func (h *ModuleHandler) DeleteEnrolment(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, "Invalid student ID")
+		return
+	}
+
+	year, err := academicYearParam(c, models.CurrentAcademicYear(time.Now()))
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, err.Error())
+		return
+	}
+
+	err = h.Repo.WithAudit(requestAudit(c)).Unenrol(id, strings.ToUpper(strings.TrimSpace(c.Param("code"))), year)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			problem.Write(c, http.StatusNotFound, problem.EnrolmentNotFound, "Enrolment not found")
+			return
+		}
+		enrolmentError(c, err, "to delete enrolment")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Enrolment deleted successfully"})
+}
+
+// findModule looks up the module named by the :code route parameter. It
+// writes an error response and returns false if there is no such module.
+func (h *ModuleHandler) findModule(c *gin.Context) (*models.Module, bool) {
+	code := strings.ToUpper(strings.TrimSpace(c.Param("code")))
+	if code == "" {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, "Invalid module code")
+		return nil, false
+	}
+
+	module, err := h.Repo.WithAudit(requestAudit(c)).GetByCode(code)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error getting module", "error", err)
+		problem.Internal(c, "Failed to retrieve module")
+		return nil, false
+	}
+
+	if module == nil {
+		problem.Write(c, http.StatusNotFound, problem.ModuleNotFound, "Module not found")
+		return nil, false
+	}
+
+	return module, true
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/bournemouth-uni-it-api-go/problem"
+	"github.com/gin-gonic/gin"
+)
+
+// problemType describes a problem code at its type URI
+type problemType struct {
+	Type  string       `json:"type"`
+	Code  problem.Code `json:"code"`
+	Title string       `json:"title"`
+}
+
+func newProblemType(code problem.Code) problemType {
+	return problemType{Type: problem.TypeBase + string(code), Code: code, Title: code.Title(0)}
+}
+
+// GetProblemTypes handles GET requests to list every problem code the API
+// can return
+func GetProblemTypes(c *gin.Context) {
+	codes := problem.Codes()
+	types := make([]problemType, 0, len(codes))
+	for _, code := range codes {
+		types = append(types, newProblemType(code))
+	}
+	c.JSON(http.StatusOK, gin.H{"data": types})
+}
+
+// GetProblemType handles GET requests for the type URI of a problem code
+func GetProblemType(c *gin.Context) {
+	code := problem.Code(c.Param("code"))
+	if !code.Known() {
+		problem.Write(c, http.StatusNotFound, problem.RouteNotFound, "Unknown problem type")
+		return
+	}
+	c.JSON(http.StatusOK, newProblemType(code))
+}
+
+// NotFound handles requests that match no route
+func NotFound(c *gin.Context) {
+	problem.Write(c, http.StatusNotFound, problem.RouteNotFound, "No route for "+c.Request.Method+" "+c.Request.URL.Path)
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/bournemouth-uni-it-api-go/policy"
+	"github.com/bournemouth-uni-it-api-go/problem"
+	"github.com/gin-gonic/gin"
+)
+
+// MaxBatchOperations caps the number of operations accepted in one batch
+const MaxBatchOperations = 1000
+
+// errBatchItemFailed signals a failed operation so that its savepoint is rolled back
+var errBatchItemFailed = errors.New("batch operation failed")
+
+// batchOperation is a single create, update or delete within a batch request
+type batchOperation struct {
+	Op      string          `json:"op"`
+	ID      int             `json:"id"`
+	IfMatch string          `json:"if_match"`
+	Student json.RawMessage `json:"student"`
+}
+
+// batchRequest is the body of POST /api/v1/students:batch
+type batchRequest struct {
+	Operations []batchOperation `json:"operations" binding:"required"`
+}
+
+// batchResult reports the outcome of one operation
+type batchResult struct {
+	Index   int             `json:"index"`
+	Op      string          `json:"op"`
+	Status  int             `json:"status"`
+	Student *models.Student `json:"student,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	// Code is the problem code of a failed operation
+	Code problem.Code `json:"code,omitempty"`
+	// Errors lists the invalid fields of the student
+	Errors []problem.FieldError `json:"errors,omitempty"`
+}
+
+// failed reports whether the operation did not succeed
+func (r *batchResult) failed() bool {
+	return r.Status >= http.StatusBadRequest
+}
+
+func (r *batchResult) fail(status int, code problem.Code, msg string) *batchResult {
+	r.Status = status
+	r.Code = code
+	r.Error = msg
+	return r
+}
+
+func (r *batchResult) failWith(p *problem.Problem) *batchResult {
+	r.Errors = p.Errors
+	return r.fail(p.Status, p.Code, p.Detail)
+}
+
+// BatchStudents handles POST requests that create, update and delete several
+// students in one transaction. By default the batch is atomic: the first
+// failure rolls back every operation. With ?atomic=false each operation that
+// fails is rolled back on its own and the rest are committed.
+func (h *StudentHandler) BatchStudents(c *gin.Context) {
+	if !h.require(c, policy.StudentsWrite) {
+		return
+	}
+
+	atomic := true
+	if raw := c.Query("atomic"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, "Invalid atomic flag, must be true or false")
+			return
+		}
+		atomic = parsed
+	}
+
+	var req batchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err, &req)
+		return
+	}
+	if len(req.Operations) == 0 || len(req.Operations) > MaxBatchOperations {
+		problem.Write(c, http.StatusBadRequest, problem.BatchInvalid, "A batch must contain between 1 and "+strconv.Itoa(MaxBatchOperations)+" operations")
+		return
+	}
+	for _, op := range req.Operations {
+		if op.Op == "delete" {
+			if !h.require(c, policy.StudentsDelete) {
+				return
+			}
+			break
+		}
+	}
+
+	tx, err := h.Repo.WithAudit(requestAudit(c)).Begin()
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error starting batch transaction", "error", err)
+		problem.Internal(c, "Failed to process batch")
+		return
+	}
+	defer func() {
+		// Rolling back after a successful commit is a no-op
+		_ = tx.Rollback()
+	}()
+
+	results := make([]*batchResult, len(req.Operations))
+	failures := 0
+	for i, op := range req.Operations {
+		if atomic && failures > 0 {
+			results[i] = &batchResult{Index: i, Op: op.Op, Status: http.StatusFailedDependency, Code: problem.BatchNotAttempted, Error: "Not attempted because an earlier operation failed"}
+			continue
+		}
+
+		var result *batchResult
+		err := tx.Try(func() error {
+			result = applyBatchOperation(c.Request.Context(), tx, i, op)
+			if result.failed() {
+				return errBatchItemFailed
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errBatchItemFailed) {
+			slog.ErrorContext(c.Request.Context(), "Error processing batch operation", "index", i, "error", err)
+			problem.Internal(c, "Failed to process batch")
+			return
+		}
+		if result.failed() {
+			failures++
+		}
+		results[i] = result
+	}
+
+	if atomic && failures > 0 {
+		if err := tx.Rollback(); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Error rolling back batch", "error", err)
+		}
+		for _, result := range results {
+			if !result.failed() {
+				result.Status = http.StatusFailedDependency
+				result.Student = nil
+				result.Code = problem.BatchRolledBack
+				result.Error = "Rolled back because another operation failed"
+			}
+		}
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"atomic": atomic, "committed": false, "results": results})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error committing batch", "error", err)
+		problem.Internal(c, "Failed to process batch")
+		return
+	}
+
+	status := http.StatusOK
+	if failures > 0 {
+		status = http.StatusMultiStatus
+	}
+	c.JSON(status, gin.H{"atomic": atomic, "committed": true, "results": results})
+}
+
+// applyBatchOperation validates and performs a single operation against repo
+func applyBatchOperation(ctx context.Context, repo models.StudentRepository, index int, op batchOperation) *batchResult {
+	result := &batchResult{Index: index, Op: op.Op}
+
+	var student models.Student
+	switch op.Op {
+	case "create", "update":
+		if len(op.Student) == 0 {
+			return result.fail(http.StatusBadRequest, problem.ValidationFailed, "student is required")
+		}
+		if err := json.Unmarshal(op.Student, &student); err != nil {
+			return result.fail(http.StatusBadRequest, problem.InvalidBody, "student must be a JSON object with fields of the right types")
+		}
+		if err := validateStudent(&student); err != nil {
+			return result.failWith(invalidStudent(http.StatusBadRequest, err, &student))
+		}
+	case "delete":
+	default:
+		return result.fail(http.StatusBadRequest, problem.BatchInvalid, "op must be create, update or delete")
+	}
+
+	if op.Op == "create" {
+		if !models.IsValidInitialStatus(student.Status) {
+			return result.fail(http.StatusBadRequest, problem.StudentInvalidStatus, errInvalidInitialStatus.Error())
+		}
+		if err := repo.Create(&student); err != nil {
+			return batchWriteError(ctx, result, err)
+		}
+		result.Status = http.StatusCreated
+		result.Student = &student
+		return result
+	}
+
+	if op.ID <= 0 {
+		return result.fail(http.StatusBadRequest, problem.InvalidParameter, "Invalid student ID")
+	}
+	existing, err := repo.GetByID(op.ID)
+	if err != nil {
+		return batchWriteError(ctx, result, err)
+	}
+	if existing == nil {
+		return result.fail(http.StatusNotFound, problem.StudentNotFound, "Student not found")
+	}
+	if op.IfMatch == "" {
+		return result.fail(http.StatusPreconditionRequired, problem.PreconditionRequired, "if_match is required")
+	}
+	if !etagMatches(op.IfMatch, existing.ETag, false) {
+		return result.fail(http.StatusPreconditionFailed, problem.StudentModified, "Student has been modified by another request")
+	}
+
+	if op.Op == "delete" {
+		if err := repo.Delete(op.ID, existing.Version); err != nil {
+			return batchWriteError(ctx, result, err)
+		}
+		result.Status = http.StatusOK
+		return result
+	}
+
+	student.ID = op.ID
+	student.Version = existing.Version
+	if err := repo.Update(&student); err != nil {
+		return batchWriteError(ctx, result, err)
+	}
+	result.Status = http.StatusOK
+	result.Student = &student
+	return result
+}
+
+// batchWriteError maps a repository error to an operation result
+func batchWriteError(ctx context.Context, result *batchResult, err error) *batchResult {
+	if errors.Is(err, models.ErrVersionConflict) {
+		return result.fail(http.StatusPreconditionFailed, problem.StudentModified, "Student has been modified by another request")
+	}
+	if p, ok := studentConstraintError(err); ok {
+		return result.failWith(p)
+	}
+	slog.ErrorContext(ctx, "Error in batch operation", "op", result.Op, "index", result.Index, "error", err)
+	return result.fail(http.StatusInternalServerError, problem.InternalError, "Failed to "+result.Op+" student")
+}
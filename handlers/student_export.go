@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/bournemouth-uni-it-api-go/policy"
+	"github.com/bournemouth-uni-it-api-go/problem"
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+)
+
+// exportFlushInterval is how many rows are written between flushes of the
+// response, so that clients start receiving data straight away
+const exportFlushInterval = 500
+
+// exportHeader is the column order of CSV and XLSX exports. The XLSX row in
+// xlsxExportWriter.write must follow it too.
+var exportHeader = []string{"id", "student_id", "first_name", "last_name", "email", "course", "year_of_study", "status", "created_at", "updated_at"}
+
+// exportRecord returns a student's values in exportHeader order
+func exportRecord(s *models.Student) []string {
+	return []string{
+		strconv.Itoa(s.ID),
+		s.StudentID,
+		s.FirstName,
+		s.LastName,
+		s.Email,
+		s.Course,
+		strconv.Itoa(s.YearOfStudy),
+		s.Status,
+		s.CreatedAt.UTC().Format(time.RFC3339),
+		s.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// csvSafe stops spreadsheet applications from evaluating a cell as a formula
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// exportWriter writes students to the response in one export format
+type exportWriter interface {
+	// begin is called once, before the first student
+	begin() error
+	write(s *models.Student) error
+	// end is called once after the last student
+	end() error
+}
+
+type csvExportWriter struct {
+	w *csv.Writer
+}
+
+func (e *csvExportWriter) begin() error {
+	return e.w.Write(exportHeader)
+}
+
+func (e *csvExportWriter) write(s *models.Student) error {
+	record := exportRecord(s)
+	for i := range record {
+		record[i] = csvSafe(record[i])
+	}
+	return e.w.Write(record)
+}
+
+func (e *csvExportWriter) end() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type ndjsonExportWriter struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonExportWriter) begin() error {
+	return nil
+}
+
+func (e *ndjsonExportWriter) write(s *models.Student) error {
+	return e.enc.Encode(s)
+}
+
+func (e *ndjsonExportWriter) end() error {
+	return nil
+}
+
+type xlsxExportWriter struct {
+	c    *gin.Context
+	file *excelize.File
+	sw   *excelize.StreamWriter
+	row  int
+}
+
+func (e *xlsxExportWriter) begin() error {
+	e.file = excelize.NewFile()
+	if err := e.file.SetSheetName("Sheet1", "Students"); err != nil {
+		return err
+	}
+	sw, err := e.file.NewStreamWriter("Students")
+	if err != nil {
+		return err
+	}
+	e.sw = sw
+	header := make([]interface{}, len(exportHeader))
+	for i, h := range exportHeader {
+		header[i] = h
+	}
+	e.row = 1
+	return e.sw.SetRow("A1", header)
+}
+
+func (e *xlsxExportWriter) write(s *models.Student) error {
+	e.row++
+	cell, err := excelize.CoordinatesToCellName(1, e.row)
+	if err != nil {
+		return err
+	}
+	return e.sw.SetRow(cell, []interface{}{
+		s.ID, s.StudentID, s.FirstName, s.LastName, s.Email, s.Course, s.YearOfStudy, s.Status,
+		s.CreatedAt.UTC().Format(time.RFC3339), s.UpdatedAt.UTC().Format(time.RFC3339),
+	})
+}
+
+func (e *xlsxExportWriter) end() error {
+	defer func() {
+		if err := e.file.Close(); err != nil {
+			slog.ErrorContext(e.c.Request.Context(), "Error closing export workbook", "error", err)
+		}
+	}()
+	if err := e.sw.Flush(); err != nil {
+		return err
+	}
+	// The stream writer spills rows to a temporary file, so the workbook is
+	// only assembled here
+	return e.file.Write(e.c.Writer)
+}
+
+// ExportStudents handles GET requests to download students as CSV, NDJSON or
+// XLSX. It accepts the same filters as GetAllStudents and streams rows from
+// the database to the client without buffering the whole result.
+func (h *StudentHandler) ExportStudents(c *gin.Context) {
+	if !h.require(c, policy.StudentsRead) {
+		return
+	}
+
+	filter, err := parseStudentFilter(c)
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, err.Error())
+		return
+	}
+	if filter.IncludeDeleted && !h.require(c, policy.StudentsDelete) {
+		return
+	}
+
+	format := strings.ToLower(c.DefaultQuery("format", "csv"))
+	var (
+		contentType string
+		writer      exportWriter
+	)
+	switch format {
+	case "csv":
+		contentType = "text/csv; charset=utf-8"
+		writer = &csvExportWriter{w: csv.NewWriter(c.Writer)}
+	case "ndjson":
+		contentType = "application/x-ndjson"
+		writer = &ndjsonExportWriter{enc: json.NewEncoder(c.Writer)}
+	case "xlsx":
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		writer = &xlsxExportWriter{c: c}
+	default:
+		problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, "Invalid format, must be csv, ndjson or xlsx")
+		return
+	}
+
+	// Headers are sent with the first row so that a failed query can still
+	// be reported as an error response
+	started := false
+	start := func() error {
+		started = true
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", `attachment; filename="students-`+time.Now().UTC().Format("20060102")+`.`+format+`"`)
+		c.Status(http.StatusOK)
+		return writer.begin()
+	}
+
+	count := 0
+	err = h.Repo.Stream(filter, func(s *models.Student) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		if err := writer.write(s); err != nil {
+			return err
+		}
+		count++
+		if count%exportFlushInterval == 0 {
+			if cw, ok := writer.(*csvExportWriter); ok {
+				cw.w.Flush()
+			}
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err == nil && !started {
+		err = start()
+	}
+	if err == nil {
+		err = writer.end()
+	}
+
+	if err != nil {
+		if !started {
+			slog.ErrorContext(c.Request.Context(), "Error exporting students", "error", err)
+			problem.Internal(c, "Failed to export students")
+			return
+		}
+		// The response is already underway; the truncated download is the
+		// only signal left to the client
+		slog.ErrorContext(c.Request.Context(), "Error exporting students", "rows", count, "error", err)
+		_ = c.Error(err)
+		c.Abort()
+	}
+}
@@ -2,13 +2,15 @@ package handlers
 
 import (
 	"database/sql"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
-	"regexp"
 	"strconv"
 	"strings"
 
 	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/bournemouth-uni-it-api-go/policy"
+	"github.com/bournemouth-uni-it-api-go/problem"
 	"github.com/gin-gonic/gin"
 	"github.com/lib/pq"
 )
@@ -16,189 +18,393 @@ import (
 // StudentHandler handles HTTP requests for students
 type StudentHandler struct {
 	Repo models.StudentRepository
+	// Policy decides what the caller may do. Without one every request is
+	// allowed.
+	Policy *policy.Policy
 }
 
 // NewStudentHandler creates a new StudentHandler
 func NewStudentHandler(db *sql.DB) *StudentHandler {
 	return &StudentHandler{
-		Repo: models.NewPostgresStudentRepository(db),
+		Repo:   models.NewPostgresStudentRepository(db),
+		Policy: policy.New(models.NewPostgresAccessRepository(db)),
 	}
 }
 
-// GetAllStudents handles GET requests to retrieve all students
+// GetAllStudents handles GET requests to retrieve a page of students
 func (h *StudentHandler) GetAllStudents(c *gin.Context) {
-	students, err := h.Repo.GetAll()
+	if !h.require(c, policy.StudentsRead) {
+		return
+	}
+
+	opts, err := parseStudentListOptions(c)
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, err.Error())
+		return
+	}
+	// Deleted students are only shown to those who can delete and restore them
+	if opts.Filter.IncludeDeleted && !h.require(c, policy.StudentsDelete) {
+		return
+	}
+
+	page, err := h.Repo.List(opts)
 	if err != nil {
-		log.Printf("Error getting all students: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve students"})
+		if errors.Is(err, models.ErrInvalidCursor) {
+			problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, "Invalid pagination cursor")
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "Error getting all students", "error", err)
+		problem.Internal(c, "Failed to retrieve students")
 		return
 	}
 
-	c.JSON(http.StatusOK, students)
+	jsonWithETag(c, gin.H{
+		"data": page.Students,
+		"meta": gin.H{
+			"total":       page.Total,
+			"limit":       opts.Limit,
+			"next_cursor": page.NextCursor,
+		},
+	})
 }
 
-// GetStudentByID handles GET requests to retrieve a student by ID
+// GetStudentByID handles GET requests to retrieve a student by ID. With
+// ?as_of= the student is returned as it was at that time.
 func (h *StudentHandler) GetStudentByID(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid student ID"})
+		problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, "Invalid student ID")
+		return
+	}
+
+	if raw := c.Query("as_of"); raw != "" {
+		h.getStudentAsOf(c, id, raw)
 		return
 	}
 
 	student, err := h.Repo.GetByID(id)
+	h.respondWithStudent(c, student, err)
+}
+
+// GetStudentByStudentID handles GET requests to retrieve a student by university student number
+func (h *StudentHandler) GetStudentByStudentID(c *gin.Context) {
+	studentID := strings.TrimSpace(c.Param("student_id"))
+	if studentID == "" {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, "Invalid student ID")
+		return
+	}
+
+	student, err := h.Repo.GetByStudentID(studentID)
+	h.respondWithStudent(c, student, err)
+}
+
+// GetStudentByEmail handles GET requests to retrieve a student by email address
+func (h *StudentHandler) GetStudentByEmail(c *gin.Context) {
+	email := strings.TrimSpace(c.Param("email"))
+	if !emailRegex.MatchString(email) {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, "Invalid email format")
+		return
+	}
+
+	student, err := h.Repo.GetByEmail(email)
+	h.respondWithStudent(c, student, err)
+}
+
+// respondWithStudent writes the result of a single-student lookup, if the
+// caller may read the student
+func (h *StudentHandler) respondWithStudent(c *gin.Context, student *models.Student, err error) {
 	if err != nil {
-		log.Printf("Error getting student by ID: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve student"})
+		slog.ErrorContext(c.Request.Context(), "Error getting student", "error", err)
+		problem.Internal(c, "Failed to retrieve student")
 		return
 	}
 
 	if student == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Student not found"})
+		problem.Write(c, http.StatusNotFound, problem.StudentNotFound, "Student not found")
+		return
+	}
+
+	if !h.canReadStudent(c, student) {
+		return
+	}
+
+	if notModified(c, student.ETag) {
 		return
 	}
 
 	c.JSON(http.StatusOK, student)
 }
 
+// SearchStudents handles GET requests to search students by name, email or student ID
+func (h *StudentHandler) SearchStudents(c *gin.Context) {
+	if !h.require(c, policy.StudentsRead) {
+		return
+	}
+
+	query := strings.TrimSpace(c.Query("q"))
+	if len(models.SearchTerms(query)) == 0 {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, "Search query q is required")
+		return
+	}
+
+	limit := models.DefaultStudentSearchLimit
+	if raw := c.Query("limit"); raw != "" {
+		l, err := strconv.Atoi(raw)
+		if err != nil || l < 1 || l > models.MaxStudentSearchLimit {
+			problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, "Invalid limit, must be between 1 and "+strconv.Itoa(models.MaxStudentSearchLimit))
+			return
+		}
+		limit = l
+	}
+
+	results, err := h.Repo.Search(query, limit)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error searching students", "error", err)
+		problem.Internal(c, "Failed to search students")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": results,
+		"meta": gin.H{
+			"query": query,
+			"count": len(results),
+		},
+	})
+}
+
 // CreateStudent handles POST requests to create a new student
 func (h *StudentHandler) CreateStudent(c *gin.Context) {
+	if !h.require(c, policy.StudentsWrite) {
+		return
+	}
+
 	var student models.Student
 	if err := c.ShouldBindJSON(&student); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		bindError(c, err, &student)
 		return
 	}
 
 	// Basic email validation
-	emailRegex := regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
 	if !emailRegex.MatchString(student.Email) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email format"})
+		problem.Abort(c, invalidStudent(http.StatusBadRequest, errInvalidEmail, &student))
+		return
+	}
+
+	if !models.IsValidInitialStatus(student.Status) {
+		problem.Write(c, http.StatusBadRequest, problem.StudentInvalidStatus, errInvalidInitialStatus.Error())
 		return
 	}
 
-	if err := h.Repo.Create(&student); err != nil {
-		log.Printf("Error creating student: %v", err)
+	if err := h.Repo.WithAudit(requestAudit(c)).Create(&student); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error creating student", "error", err)
 
 		// Handle PostgreSQL constraint violations
-		if pqErr, ok := err.(*pq.Error); ok {
-			switch pqErr.Code {
-			case "23505": // unique_violation
-				if strings.Contains(pqErr.Message, "email") {
-					c.JSON(http.StatusConflict, gin.H{"error": "Email already exists"})
-				} else if strings.Contains(pqErr.Message, "student_id") {
-					c.JSON(http.StatusConflict, gin.H{"error": "Student ID already exists"})
-				} else {
-					c.JSON(http.StatusConflict, gin.H{"error": "Duplicate entry"})
-				}
-				return
-			}
+		if p, ok := studentConstraintError(err); ok {
+			problem.Abort(c, p)
+			return
 		}
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create student"})
+		problem.Internal(c, "Failed to create student")
 		return
 	}
 
+	c.Header("ETag", student.ETag)
 	c.JSON(http.StatusCreated, student)
 }
 
 // UpdateStudent handles PUT requests to update an existing student
 func (h *StudentHandler) UpdateStudent(c *gin.Context) {
+	if !h.require(c, policy.StudentsWrite) {
+		return
+	}
+
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid student ID"})
+		problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, "Invalid student ID")
 		return
 	}
 
 	// Check if student exists
 	existingStudent, err := h.Repo.GetByID(id)
 	if err != nil {
-		log.Printf("Error checking student existence: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve student"})
+		slog.ErrorContext(c.Request.Context(), "Error checking student existence", "error", err)
+		problem.Internal(c, "Failed to retrieve student")
 		return
 	}
 
 	if existingStudent == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Student not found"})
+		problem.Write(c, http.StatusNotFound, problem.StudentNotFound, "Student not found")
+		return
+	}
+
+	if !checkIfMatch(c, existingStudent) {
 		return
 	}
 
 	// Bind request body to student model
 	var student models.Student
 	if err := c.ShouldBindJSON(&student); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		bindError(c, err, &student)
 		return
 	}
 
 	// Basic email validation
-	emailRegex := regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
 	if !emailRegex.MatchString(student.Email) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email format"})
+		problem.Abort(c, invalidStudent(http.StatusBadRequest, errInvalidEmail, &student))
 		return
 	}
 
-	// Set the ID from the URL parameter
+	// Set the ID from the URL parameter and the version the client last saw
 	student.ID = id
+	student.Version = existingStudent.Version
 
 	// Update the student
-	if err := h.Repo.Update(&student); err != nil {
-		log.Printf("Error updating student: %v", err)
+	if err := h.Repo.WithAudit(requestAudit(c)).Update(&student); err != nil {
+		if errors.Is(err, models.ErrVersionConflict) {
+			problem.Write(c, http.StatusPreconditionFailed, problem.StudentModified, "Student has been modified by another request")
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "Error updating student", "error", err)
 
 		// Handle PostgreSQL constraint violations
-		if pqErr, ok := err.(*pq.Error); ok {
-			switch pqErr.Code {
-			case "23505": // unique_violation
-				if strings.Contains(pqErr.Message, "email") {
-					c.JSON(http.StatusConflict, gin.H{"error": "Email already exists"})
-				} else if strings.Contains(pqErr.Message, "student_id") {
-					c.JSON(http.StatusConflict, gin.H{"error": "Student ID already exists"})
-				} else {
-					c.JSON(http.StatusConflict, gin.H{"error": "Duplicate entry"})
-				}
-				return
-			}
+		if p, ok := studentConstraintError(err); ok {
+			problem.Abort(c, p)
+			return
 		}
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update student"})
+		problem.Internal(c, "Failed to update student")
 		return
 	}
 
+	c.Header("ETag", student.ETag)
 	c.JSON(http.StatusOK, student)
 }
 
-// DeleteStudent handles DELETE requests to remove a student
+// DeleteStudent handles DELETE requests to remove a student. The student is
+// kept as deleted until purged and can be restored in the meantime.
 func (h *StudentHandler) DeleteStudent(c *gin.Context) {
+	if !h.require(c, policy.StudentsDelete) {
+		return
+	}
+
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid student ID"})
+		problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, "Invalid student ID")
 		return
 	}
 
 	// Check if student exists
 	existingStudent, err := h.Repo.GetByID(id)
 	if err != nil {
-		log.Printf("Error checking student existence: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve student"})
+		slog.ErrorContext(c.Request.Context(), "Error checking student existence", "error", err)
+		problem.Internal(c, "Failed to retrieve student")
 		return
 	}
 
 	if existingStudent == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Student not found"})
+		problem.Write(c, http.StatusNotFound, problem.StudentNotFound, "Student not found")
+		return
+	}
+
+	if !checkIfMatch(c, existingStudent) {
 		return
 	}
 
 	// Delete the student
-	if err := h.Repo.Delete(id); err != nil {
+	if err := h.Repo.WithAudit(requestAudit(c)).Delete(id, existingStudent.Version); err != nil {
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Student not found"})
+			problem.Write(c, http.StatusNotFound, problem.StudentNotFound, "Student not found")
 			return
 		}
-		log.Printf("Error deleting student: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete student"})
+		if errors.Is(err, models.ErrVersionConflict) {
+			problem.Write(c, http.StatusPreconditionFailed, problem.StudentModified, "Student has been modified by another request")
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "Error deleting student", "error", err)
+		problem.Internal(c, "Failed to delete student")
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Student deleted successfully"})
 }
 
+// RestoreStudent handles POST requests to undo the deletion of a student
+func (h *StudentHandler) RestoreStudent(c *gin.Context) {
+	if !h.require(c, policy.StudentsDelete) {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, "Invalid student ID")
+		return
+	}
+
+	student, err := h.Repo.WithAudit(requestAudit(c)).Restore(id)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error restoring student", "error", err)
+		problem.Internal(c, "Failed to restore student")
+		return
+	}
+
+	if student == nil {
+		// Tell a student that was never deleted apart from one that does not exist
+		existing, err := h.Repo.GetByID(id)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Error checking student existence", "error", err)
+			problem.Internal(c, "Failed to retrieve student")
+			return
+		}
+		if existing != nil {
+			problem.Write(c, http.StatusConflict, problem.StudentNotDeleted, "Student is not deleted")
+			return
+		}
+		problem.Write(c, http.StatusNotFound, problem.StudentNotFound, "Student not found")
+		return
+	}
+
+	c.Header("ETag", student.ETag)
+	c.JSON(http.StatusOK, student)
+}
+
+// uniqueViolationMessage maps a PostgreSQL unique_violation to a client-facing
+// message and code. It reports false for any other error.
+func uniqueViolationMessage(err error) (problem.Code, string, bool) {
+	pqErr, ok := err.(*pq.Error)
+	if !ok || pqErr.Code != "23505" { // unique_violation
+		return "", "", false
+	}
+	if strings.Contains(pqErr.Message, "email") {
+		return problem.StudentEmailTaken, "Email already exists", true
+	}
+	if strings.Contains(pqErr.Message, "student_id") {
+		return problem.StudentIDTaken, "Student ID already exists", true
+	}
+	return problem.DuplicateEntry, "Duplicate entry", true
+}
+
+// foreignKeyViolation reports whether err is a PostgreSQL foreign_key_violation
+func foreignKeyViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23503" // foreign_key_violation
+}
+
+// studentConstraintError maps a constraint violation from a student write to
+// a client-facing problem. It reports false for any other error.
+func studentConstraintError(err error) (*problem.Problem, bool) {
+	if code, msg, ok := uniqueViolationMessage(err); ok {
+		return problem.New(http.StatusConflict, code, msg), true
+	}
+	if foreignKeyViolation(err) {
+		return problem.New(http.StatusUnprocessableEntity, problem.StudentUnknownCourse, "Course does not exist"), true
+	}
+	if errors.Is(err, models.ErrStudentDeleted) {
+		return problem.New(http.StatusConflict, problem.StudentDeleted, "Student ID belongs to a deleted student, restore it instead"), true
+	}
+	return nil, false
+}
+
 // HealthCheck handles GET requests to check API health
 func (h *StudentHandler) HealthCheck(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
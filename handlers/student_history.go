@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/bournemouth-uni-it-api-go/problem"
+	"github.com/gin-gonic/gin"
+)
+
+// GetStudentHistory handles GET requests to retrieve every recorded change to
+// a student, oldest first
+func (h *StudentHandler) GetStudentHistory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, "Invalid student ID")
+		return
+	}
+
+	if h.Policy != nil {
+		// Deleted students are not found and can only be read with
+		// students:read
+		student, err := h.Repo.GetByID(id)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Error checking student existence", "error", err)
+			problem.Internal(c, "Failed to retrieve student")
+			return
+		}
+		if !h.canReadStudent(c, student) {
+			return
+		}
+	}
+
+	entries, err := h.Repo.History(id)
+	if err != nil {
+		if errors.Is(err, models.ErrStudentNotFound) {
+			problem.Write(c, http.StatusNotFound, problem.StudentNotFound, "Student not found")
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "Error getting student history", "error", err)
+		problem.Internal(c, "Failed to retrieve student history")
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// getStudentAsOf writes a student as it was at the time given by the as_of
+// query parameter
+func (h *StudentHandler) getStudentAsOf(c *gin.Context, id int, raw string) {
+	at, err := parseQueryTime(raw)
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, "Invalid as_of, expected RFC 3339 timestamp or YYYY-MM-DD")
+		return
+	}
+
+	student, err := h.Repo.GetAsOf(id, at)
+	h.respondWithStudent(c, student, err)
+}
@@ -0,0 +1,384 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/bournemouth-uni-it-api-go/policy"
+	"github.com/bournemouth-uni-it-api-go/problem"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+const (
+	// MaxImportFileSize caps the size of an uploaded CSV file
+	MaxImportFileSize = 10 << 20
+	// importFormOverhead allows for the multipart headers and the mapping
+	// field on top of the file itself
+	importFormOverhead = 1 << 20
+	// MaxImportRows caps the number of data rows in one import
+	MaxImportRows = 10000
+)
+
+// importColumnAliases maps normalised CSV header names to student fields
+var importColumnAliases = map[string]string{
+	"first_name":     "first_name",
+	"firstname":      "first_name",
+	"forename":       "first_name",
+	"given_name":     "first_name",
+	"last_name":      "last_name",
+	"lastname":       "last_name",
+	"surname":        "last_name",
+	"family_name":    "last_name",
+	"email":          "email",
+	"email_address":  "email",
+	"student_id":     "student_id",
+	"studentid":      "student_id",
+	"student_no":     "student_id",
+	"student_number": "student_id",
+	"course":         "course",
+	"programme":      "course",
+	"program":        "course",
+	"year_of_study":  "year_of_study",
+	"year":           "year_of_study",
+	"study_year":     "year_of_study",
+}
+
+// importRow is the validation report for one data row of an import
+type importRow struct {
+	Row       int      `json:"row"`
+	StudentID string   `json:"student_id,omitempty"`
+	Action    string   `json:"action,omitempty"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+// normaliseHeader lower-cases a header and joins its words with underscores
+func normaliseHeader(h string) string {
+	h = strings.TrimPrefix(h, "\ufeff")
+	return strings.Join(strings.FieldsFunc(strings.ToLower(h), func(r rune) bool {
+		return r == ' ' || r == '_' || r == '-' || r == '.'
+	}), "_")
+}
+
+// importColumns resolves the position of each student field in the header
+// row. Explicit mappings from the request take precedence over aliases.
+func importColumns(header []string, mapping map[string]string) (map[string]int, error) {
+	columns := make(map[string]int)
+	for i, h := range header {
+		field, ok := mapping[strings.TrimSpace(h)]
+		if !ok {
+			field, ok = importColumnAliases[normaliseHeader(h)]
+		}
+		if !ok {
+			continue
+		}
+		if _, dup := columns[field]; dup {
+			return nil, errors.New("More than one column maps to " + field)
+		}
+		columns[field] = i
+	}
+
+	var missing []string
+	for _, field := range []string{"first_name", "last_name", "email", "student_id", "course", "year_of_study"} {
+		if _, ok := columns[field]; !ok {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, errors.New("CSV header is missing columns for: " + strings.Join(missing, ", "))
+	}
+	return columns, nil
+}
+
+// parseImportRecord builds a student from a CSV record and validates it with
+// the same rules as CreateStudent
+func parseImportRecord(record []string, columns map[string]int) (models.Student, []string) {
+	value := func(field string) string {
+		if i := columns[field]; i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	student := models.Student{
+		FirstName: value("first_name"),
+		LastName:  value("last_name"),
+		Email:     value("email"),
+		StudentID: value("student_id"),
+		Course:    value("course"),
+	}
+
+	var problems []string
+	if raw := value("year_of_study"); raw != "" {
+		year, err := strconv.Atoi(raw)
+		if err != nil {
+			problems = append(problems, "year_of_study must be a whole number")
+		} else {
+			student.YearOfStudy = year
+		}
+	}
+
+	// Report every problem with the row rather than stopping at the first
+	if err := binding.Validator.ValidateStruct(&student); err != nil {
+		for _, msg := range validationMessages(err, &student) {
+			// A malformed year is already reported above
+			if msg == "year_of_study is required" && len(problems) > 0 {
+				continue
+			}
+			problems = append(problems, msg)
+		}
+	}
+	if student.Email != "" && !emailRegex.MatchString(student.Email) {
+		problems = append(problems, errInvalidEmail.Error())
+	}
+	return student, problems
+}
+
+// ImportStudents handles multipart POST requests that upsert students from a
+// CSV file, keyed on student_id. With ?dry_run=true the file is only
+// validated. If any row is invalid nothing is written.
+func (h *StudentHandler) ImportStudents(c *gin.Context) {
+	if !h.require(c, policy.StudentsWrite) {
+		return
+	}
+
+	dryRun := false
+	if raw := c.Query("dry_run"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, "Invalid dry_run flag, must be true or false")
+			return
+		}
+		dryRun = parsed
+	}
+
+	// Cap the body before the form is parsed, as parsing reads the whole
+	// upload and spills large files to disk
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MaxImportFileSize+importFormOverhead)
+	if _, err := c.MultipartForm(); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			problem.Write(c, http.StatusRequestEntityTooLarge, problem.TooLarge, "CSV file is too large")
+			return
+		}
+		problem.Write(c, http.StatusBadRequest, problem.ImportInvalidFile, "A CSV file must be uploaded in the file field")
+		return
+	}
+
+	var mapping map[string]string
+	if raw := c.PostForm("mapping"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+			problem.Write(c, http.StatusBadRequest, problem.ImportInvalidFile, "Invalid mapping, expected a JSON object of CSV header to student field")
+			return
+		}
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.ImportInvalidFile, "A CSV file must be uploaded in the file field")
+		return
+	}
+	if fileHeader.Size > MaxImportFileSize {
+		problem.Write(c, http.StatusRequestEntityTooLarge, problem.TooLarge, "CSV file is too large")
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.ImportInvalidFile, "Failed to read uploaded file")
+		return
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			slog.ErrorContext(c.Request.Context(), "Error closing uploaded file", "error", closeErr)
+		}
+	}()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.ImportInvalidFile, "Failed to read uploaded file")
+		return
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.ImportInvalidFile, "CSV file must start with a header row")
+		return
+	}
+	columns, err := importColumns(header, mapping)
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, err.Error())
+		return
+	}
+
+	var students []models.Student
+	var rows []*importRow
+	seen := make(map[string]int)
+	seenEmails := make(map[string]int)
+	invalid := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			problem.Write(c, http.StatusBadRequest, problem.ImportInvalidFile, "Malformed CSV: "+err.Error())
+			return
+		}
+		line, _ := reader.FieldPos(0)
+		if len(rows) == MaxImportRows {
+			problem.Write(c, http.StatusRequestEntityTooLarge, problem.TooLarge, "CSV file has more than "+strconv.Itoa(MaxImportRows)+" rows")
+			return
+		}
+
+		student, problems := parseImportRecord(record, columns)
+		row := &importRow{Row: line, StudentID: student.StudentID}
+		if student.StudentID != "" {
+			if first, dup := seen[student.StudentID]; dup {
+				problems = append(problems, "student_id duplicates row "+strconv.Itoa(first))
+			} else {
+				seen[student.StudentID] = line
+			}
+		}
+		if student.Email != "" {
+			if first, dup := seenEmails[student.Email]; dup {
+				problems = append(problems, "email duplicates row "+strconv.Itoa(first))
+			} else {
+				seenEmails[student.Email] = line
+			}
+		}
+		if len(problems) > 0 {
+			row.Errors = problems
+			invalid++
+		}
+		students = append(students, student)
+		rows = append(rows, row)
+	}
+
+	if len(rows) == 0 {
+		problem.Write(c, http.StatusBadRequest, problem.ImportInvalidFile, "CSV file has no data rows")
+		return
+	}
+
+	tx, err := h.Repo.WithAudit(requestAudit(c)).Begin()
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error starting import transaction", "error", err)
+		problem.Internal(c, "Failed to import students")
+		return
+	}
+	defer func() {
+		// Rolling back after a successful commit is a no-op
+		_ = tx.Rollback()
+	}()
+
+	if dryRun || invalid > 0 {
+		// Upsert each valid row in a transaction that is never committed, so
+		// the report matches what a real import would do: unknown courses,
+		// emails held by other students and student_ids of deleted students
+		// are all rejected by the database exactly as they would be then
+		for i, row := range rows {
+			if len(row.Errors) > 0 {
+				continue
+			}
+			var created bool
+			err := tx.Try(func() error {
+				var err error
+				created, err = tx.Upsert(&students[i])
+				return err
+			})
+			if err != nil {
+				p, ok := studentConstraintError(err)
+				if !ok {
+					slog.ErrorContext(c.Request.Context(), "Error checking imported student", "row", row.Row, "error", err)
+					problem.Internal(c, "Failed to validate import")
+					return
+				}
+				row.Errors = []string{p.Detail}
+				invalid++
+				continue
+			}
+			row.Action = "update"
+			if created {
+				row.Action = "create"
+			}
+		}
+		if err := tx.Rollback(); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Error rolling back import validation", "error", err)
+		}
+
+		status := http.StatusOK
+		if invalid > 0 {
+			status = http.StatusUnprocessableEntity
+		}
+		c.JSON(status, importSummary(dryRun, false, rows, invalid))
+		return
+	}
+
+	for i, row := range rows {
+		created, err := tx.Upsert(&students[i])
+		if err != nil {
+			row.Errors = []string{"Failed to save student"}
+			status := http.StatusInternalServerError
+			if p, ok := studentConstraintError(err); ok {
+				row.Errors = []string{p.Detail}
+				status = p.Status
+			} else {
+				slog.ErrorContext(c.Request.Context(), "Error importing student", "row", row.Row, "error", err)
+			}
+			// Nothing was written, so no row was created or updated
+			for _, r := range rows {
+				r.Action = ""
+			}
+			c.JSON(status, importSummary(false, false, rows, 1))
+			return
+		}
+		row.Action = "update"
+		if created {
+			row.Action = "create"
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error committing import", "error", err)
+		problem.Internal(c, "Failed to import students")
+		return
+	}
+
+	c.JSON(http.StatusOK, importSummary(false, true, rows, 0))
+}
+
+// importSummary builds the response body for an import
+func importSummary(dryRun, committed bool, rows []*importRow, invalid int) gin.H {
+	created, updated := 0, 0
+	for _, row := range rows {
+		if len(row.Errors) > 0 {
+			continue
+		}
+		switch row.Action {
+		case "create":
+			created++
+		case "update":
+			updated++
+		}
+	}
+	return gin.H{
+		"dry_run":      dryRun,
+		"committed":    committed,
+		"total_rows":   len(rows),
+		"invalid_rows": invalid,
+		"created":      created,
+		"updated":      updated,
+		"rows":         rows,
+	}
+}
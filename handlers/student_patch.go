@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/bournemouth-uni-it-api-go/policy"
+	"github.com/bournemouth-uni-it-api-go/problem"
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// mergePatchContentType is the media type of an RFC 7396 JSON Merge Patch
+	mergePatchContentType = "application/merge-patch+json"
+	// jsonPatchContentType is the media type of an RFC 6902 JSON Patch
+	jsonPatchContentType = "application/json-patch+json"
+)
+
+// studentPatchDocument is the JSON document that patches are applied to. It
+// holds only the fields a client may change.
+type studentPatchDocument struct {
+	FirstName   string `json:"first_name"`
+	LastName    string `json:"last_name"`
+	Email       string `json:"email"`
+	StudentID   string `json:"student_id"`
+	Course      string `json:"course"`
+	YearOfStudy int    `json:"year_of_study"`
+}
+
+func newStudentPatchDocument(s *models.Student) studentPatchDocument {
+	return studentPatchDocument{
+		FirstName:   s.FirstName,
+		LastName:    s.LastName,
+		Email:       s.Email,
+		StudentID:   s.StudentID,
+		Course:      s.Course,
+		YearOfStudy: s.YearOfStudy,
+	}
+}
+
+// changedColumns returns the columns whose values differ between d and other
+func (d studentPatchDocument) changedColumns(other studentPatchDocument) map[string]interface{} {
+	changed := make(map[string]interface{})
+	if d.FirstName != other.FirstName {
+		changed["first_name"] = other.FirstName
+	}
+	if d.LastName != other.LastName {
+		changed["last_name"] = other.LastName
+	}
+	if d.Email != other.Email {
+		changed["email"] = other.Email
+	}
+	if d.StudentID != other.StudentID {
+		changed["student_id"] = other.StudentID
+	}
+	if d.Course != other.Course {
+		changed["course"] = other.Course
+	}
+	if d.YearOfStudy != other.YearOfStudy {
+		changed["year_of_study"] = other.YearOfStudy
+	}
+	return changed
+}
+
+// applyStudentPatch applies a merge patch or JSON patch body to the student
+// and returns the resulting document, or the problem with the patch
+func applyStudentPatch(contentType string, original studentPatchDocument, body []byte) (studentPatchDocument, *problem.Problem) {
+	var patched studentPatchDocument
+
+	originalJSON, err := json.Marshal(original)
+	if err != nil {
+		return patched, problem.New(http.StatusInternalServerError, problem.InternalError, "Failed to prepare student for patching")
+	}
+
+	var patchedJSON []byte
+	switch contentType {
+	case mergePatchContentType:
+		patchedJSON, err = jsonpatch.MergePatch(originalJSON, body)
+		if err != nil {
+			return patched, problem.New(http.StatusBadRequest, problem.InvalidBody, "Invalid merge patch document")
+		}
+	case jsonPatchContentType:
+		patch, err := jsonpatch.DecodePatch(body)
+		if err != nil {
+			return patched, problem.New(http.StatusBadRequest, problem.InvalidBody, "Invalid JSON patch document")
+		}
+		patchedJSON, err = patch.Apply(originalJSON)
+		if err != nil {
+			// A failed "test" operation or a missing path leaves the request
+			// well-formed but not applicable to the current resource
+			return patched, problem.New(http.StatusUnprocessableEntity, problem.PatchNotApplicable, "JSON patch could not be applied: "+err.Error())
+		}
+	default:
+		return patched, problem.New(http.StatusUnsupportedMediaType, problem.UnsupportedMediaType, "Content-Type must be "+mergePatchContentType+" or "+jsonPatchContentType)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(patchedJSON))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patched); err != nil {
+		return patched, problem.New(http.StatusUnprocessableEntity, problem.ValidationFailed, "Patched student is invalid: "+err.Error())
+	}
+
+	return patched, nil
+}
+
+// PatchStudent handles PATCH requests to partially update an existing student
+func (h *StudentHandler) PatchStudent(c *gin.Context) {
+	if !h.requireAny(c, policy.StudentsWrite, policy.StudentsWriteOwn) {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidParameter, "Invalid student ID")
+		return
+	}
+
+	contentType := c.ContentType()
+	if contentType != mergePatchContentType && contentType != jsonPatchContentType {
+		c.Header("Accept-Patch", mergePatchContentType+", "+jsonPatchContentType)
+		problem.Write(c, http.StatusUnsupportedMediaType, problem.UnsupportedMediaType, "Content-Type must be "+mergePatchContentType+" or "+jsonPatchContentType)
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidBody, "Failed to read request body")
+		return
+	}
+
+	// Check if student exists
+	existingStudent, err := h.Repo.GetByID(id)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error checking student existence", "error", err)
+		problem.Internal(c, "Failed to retrieve student")
+		return
+	}
+
+	// Check the caller may edit the student before revealing its version or
+	// evaluating the patch against it. A student who was not found can only
+	// be edited with students:write.
+	if !h.canEditStudent(c, existingStudent, nil) {
+		return
+	}
+
+	if existingStudent == nil {
+		problem.Write(c, http.StatusNotFound, problem.StudentNotFound, "Student not found")
+		return
+	}
+
+	if !checkIfMatch(c, existingStudent) {
+		return
+	}
+
+	original := newStudentPatchDocument(existingStudent)
+	patched, p := applyStudentPatch(contentType, original, body)
+	if p != nil {
+		problem.Abort(c, p)
+		return
+	}
+
+	// Validate the patched student with the same rules as a full update
+	candidate := *existingStudent
+	candidate.FirstName = patched.FirstName
+	candidate.LastName = patched.LastName
+	candidate.Email = patched.Email
+	candidate.StudentID = patched.StudentID
+	candidate.Course = patched.Course
+	candidate.YearOfStudy = patched.YearOfStudy
+	if err := validateStudent(&candidate); err != nil {
+		problem.Abort(c, invalidStudent(http.StatusUnprocessableEntity, err, &candidate))
+		return
+	}
+
+	changed := original.changedColumns(patched)
+	if !h.canEditStudent(c, existingStudent, changed) {
+		return
+	}
+	if len(changed) == 0 {
+		c.Header("ETag", existingStudent.ETag)
+		c.JSON(http.StatusOK, existingStudent)
+		return
+	}
+
+	student, err := h.Repo.WithAudit(requestAudit(c)).UpdateFields(id, existingStudent.Version, changed)
+	if err != nil {
+		if errors.Is(err, models.ErrVersionConflict) {
+			problem.Write(c, http.StatusPreconditionFailed, problem.StudentModified, "Student has been modified by another request")
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "Error patching student", "error", err)
+
+		// Handle PostgreSQL constraint violations
+		if p, ok := studentConstraintError(err); ok {
+			problem.Abort(c, p)
+			return
+		}
+
+		problem.Internal(c, "Failed to update student")
+		return
+	}
+
+	if student == nil {
+		problem.Write(c, http.StatusNotFound, problem.StudentNotFound, "Student not found")
+		return
+	}
+
+	c.Header("ETag", student.ETag)
+	c.JSON(http.StatusOK, student)
+}
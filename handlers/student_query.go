@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/gin-gonic/gin"
+)
+
+// parseStudentFilter reads the list filters from the query string
+func parseStudentFilter(c *gin.Context) (models.StudentFilter, error) {
+	filter := models.StudentFilter{
+		Course: strings.TrimSpace(c.Query("course")),
+	}
+
+	if raw := c.Query("year_of_study"); raw != "" {
+		year, err := strconv.Atoi(raw)
+		if err != nil || year < 1 {
+			return filter, errors.New("Invalid year_of_study")
+		}
+		filter.YearOfStudy = year
+	}
+
+	if raw := c.Query("status"); raw != "" {
+		statuses, err := parseStatusFilter(raw)
+		if err != nil {
+			return filter, err
+		}
+		filter.Statuses = statuses
+	}
+
+	if raw := c.Query("include_deleted"); raw != "" {
+		includeDeleted, err := strconv.ParseBool(raw)
+		if err != nil {
+			return filter, errors.New("Invalid include_deleted, must be true or false")
+		}
+		filter.IncludeDeleted = includeDeleted
+	}
+
+	if raw := c.Query("created_after"); raw != "" {
+		t, err := parseQueryTime(raw)
+		if err != nil {
+			return filter, errors.New("Invalid created_after, expected RFC 3339 timestamp or YYYY-MM-DD")
+		}
+		filter.CreatedAfter = &t
+	}
+
+	if raw := c.Query("created_before"); raw != "" {
+		t, err := parseQueryTime(raw)
+		if err != nil {
+			return filter, errors.New("Invalid created_before, expected RFC 3339 timestamp or YYYY-MM-DD")
+		}
+		filter.CreatedBefore = &t
+	}
+
+	return filter, nil
+}
+
+// parseStatusFilter reads a comma separated list of lifecycle states. "all"
+// selects every state, including withdrawn and graduated students.
+func parseStatusFilter(raw string) ([]string, error) {
+	if strings.EqualFold(strings.TrimSpace(raw), "all") {
+		return models.StudentStatuses, nil
+	}
+
+	var statuses []string
+	for _, part := range strings.Split(raw, ",") {
+		status := strings.ToLower(strings.TrimSpace(part))
+		if !models.IsValidStudentStatus(status) {
+			return nil, errors.New("Invalid status, must be all or a comma separated list of " + strings.Join(models.StudentStatuses, ", "))
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// parseStudentListOptions reads filters, ordering and pagination from the query string
+func parseStudentListOptions(c *gin.Context) (models.StudentListOptions, error) {
+	filter, err := parseStudentFilter(c)
+	if err != nil {
+		return models.StudentListOptions{}, err
+	}
+
+	opts := models.StudentListOptions{
+		Filter: filter,
+		Limit:  models.DefaultStudentPageSize,
+		Cursor: c.Query("cursor"),
+		Sort:   c.DefaultQuery("sort", "id"),
+	}
+
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > models.MaxStudentPageSize {
+			return opts, errors.New("Invalid limit, must be between 1 and " + strconv.Itoa(models.MaxStudentPageSize))
+		}
+		opts.Limit = limit
+	}
+
+	if !models.IsValidStudentSort(opts.Sort) {
+		return opts, errors.New("Invalid sort field")
+	}
+
+	switch strings.ToLower(c.DefaultQuery("order", "asc")) {
+	case "asc":
+	case "desc":
+		opts.Desc = true
+	default:
+		return opts, errors.New("Invalid order, must be asc or desc")
+	}
+
+	return opts, nil
+}
+
+// parseQueryTime accepts either a full RFC 3339 timestamp or a plain date
+func parseQueryTime(raw string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", raw)
+}
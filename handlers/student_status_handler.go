@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/bournemouth-uni-it-api-go/problem"
+	"github.com/gin-gonic/gin"
+)
+
+// StudentStatusHandler handles HTTP requests for the student lifecycle
+type StudentStatusHandler struct {
+	Repo models.StudentStatusRepository
+}
+
+// NewStudentStatusHandler creates a new StudentStatusHandler
+func NewStudentStatusHandler(db *sql.DB) *StudentStatusHandler {
+	return &StudentStatusHandler{
+		Repo: models.NewPostgresStudentStatusRepository(db),
+	}
+}
+
+// transitionRequest is the body of POST /api/v1/students/:id/transitions
+type transitionRequest struct {
+	To            string `json:"to" binding:"required"`
+	Reason        string `json:"reason" binding:"required"`
+	Note          string `json:"note"`
+	EffectiveDate string `json:"effective_date" binding:"omitempty,datetime=2006-01-02"`
+}
+
+// GetStudentTransitions handles GET requests to retrieve a student's status history
+func (h *StudentStatusHandler) GetStudentTransitions(c *gin.Context) {
+	id, ok := idParam(c, "student")
+	if !ok {
+		return
+	}
+
+	transitions, err := h.Repo.ListTransitions(id)
+	if err != nil {
+		if errors.Is(err, models.ErrStudentNotFound) {
+			problem.Write(c, http.StatusNotFound, problem.StudentNotFound, "Student not found")
+			return
+		}
+		slog.ErrorContext(c.Request.Context(), "Error getting student transitions", "error", err)
+		problem.Internal(c, "Failed to retrieve status history")
+		return
+	}
+
+	c.JSON(http.StatusOK, transitions)
+}
+
+// TransitionStudent handles POST requests to move a student to a new
+// lifecycle status. The effective date defaults to today and may not be in
+// the future.
+func (h *StudentStatusHandler) TransitionStudent(c *gin.Context) {
+	id, ok := idParam(c, "student")
+	if !ok {
+		return
+	}
+
+	var req transitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err, &req)
+		return
+	}
+
+	req.To = strings.ToLower(strings.TrimSpace(req.To))
+	if !models.IsValidStudentStatus(req.To) {
+		problem.Abort(c, problem.New(http.StatusBadRequest, problem.ValidationFailed, "Request body has invalid fields").
+			WithErrors(problem.FieldError{Field: "to", Code: "oneof", Detail: "Invalid status, must be one of " + strings.Join(models.StudentStatuses, ", ")}))
+		return
+	}
+
+	today := time.Now().Format("2006-01-02")
+	if req.EffectiveDate == "" {
+		req.EffectiveDate = today
+	}
+	if req.EffectiveDate > today {
+		problem.Write(c, http.StatusUnprocessableEntity, problem.InvalidEffectiveDate, "effective_date cannot be in the future")
+		return
+	}
+
+	t := models.StudentTransition{
+		StudentID:     id,
+		ToStatus:      req.To,
+		Reason:        strings.TrimSpace(req.Reason),
+		Note:          strings.TrimSpace(req.Note),
+		EffectiveDate: req.EffectiveDate,
+	}
+	student, err := h.Repo.WithAudit(requestAudit(c)).Transition(&t)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrStudentNotFound):
+			problem.Write(c, http.StatusNotFound, problem.StudentNotFound, "Student not found")
+		case errors.Is(err, models.ErrIllegalTransition):
+			// The student's status is current_status, as status is the
+			// problem's HTTP status
+			problem.Abort(c, problem.New(http.StatusConflict, problem.TransitionNotAllowed, "Cannot move a student from "+t.FromStatus+" to "+t.ToStatus).
+				With("current_status", t.FromStatus).
+				With("allowed", models.AllowedTransitions(t.FromStatus)))
+		case errors.Is(err, models.ErrInvalidReason):
+			problem.Abort(c, problem.New(http.StatusUnprocessableEntity, problem.InvalidTransitionReason, "Invalid reason for moving a student from "+t.FromStatus+" to "+t.ToStatus).
+				With("reasons", models.TransitionReasons(t.FromStatus, t.ToStatus)))
+		case errors.Is(err, models.ErrEffectiveDateOrder):
+			problem.Write(c, http.StatusUnprocessableEntity, problem.InvalidEffectiveDate, "effective_date cannot be before the student's previous transition")
+		default:
+			slog.ErrorContext(c.Request.Context(), "Error transitioning student", "error", err)
+			problem.Internal(c, "Failed to change student status")
+		}
+		return
+	}
+
+	c.Header("ETag", student.ETag)
+	c.JSON(http.StatusCreated, gin.H{"transition": t, "student": student})
+}
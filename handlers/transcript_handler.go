@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/bournemouth-uni-it-api-go/classification"
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/bournemouth-uni-it-api-go/policy"
+	"github.com/bournemouth-uni-it-api-go/problem"
+	"github.com/bournemouth-uni-it-api-go/transcript"
+	"github.com/gin-gonic/gin"
+)
+
+// TranscriptHandler handles HTTP requests for academic transcripts
+type TranscriptHandler struct {
+	Students    models.StudentRepository
+	Results     models.AssessmentRepository
+	Transcripts models.TranscriptRepository
+	Rules       classification.Rules
+	// Policy decides who may be issued a student's transcript. Without one
+	// every request is allowed.
+	Policy *policy.Policy
+}
+
+// NewTranscriptHandler creates a new TranscriptHandler
+func NewTranscriptHandler(db *sql.DB, rules classification.Rules) *TranscriptHandler {
+	return &TranscriptHandler{
+		Students:    models.NewPostgresStudentRepository(db),
+		Results:     models.NewPostgresAssessmentRepository(db),
+		Transcripts: models.NewPostgresTranscriptRepository(db),
+		Rules:       rules,
+		Policy:      policy.New(models.NewPostgresAccessRepository(db)),
+	}
+}
+
+// GetStudentTranscript handles GET requests to issue a student's transcript
+// as a PDF. Every transcript issued is recorded under a new verification code.
+func (h *TranscriptHandler) GetStudentTranscript(c *gin.Context) {
+	id, ok := idParam(c, "student")
+	if !ok {
+		return
+	}
+
+	student, err := h.Students.GetByID(id)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error getting student by ID", "error", err)
+		problem.Internal(c, "Failed to retrieve student")
+		return
+	}
+	if !canReadStudent(c, h.Policy, student) {
+		return
+	}
+	if student == nil {
+		problem.Write(c, http.StatusNotFound, problem.StudentNotFound, "Student not found")
+		return
+	}
+
+	results, err := h.Results.WithAudit(requestAudit(c)).StudentResults(id, "")
+	if err != nil {
+		markError(c, err, "to retrieve marks")
+		return
+	}
+
+	// A student who cannot be classified yet still gets a transcript, which
+	// lists the modules the classification is waiting for instead
+	modules, pending := classificationModules(results, h.Rules)
+	var result *classification.Result
+	if len(pending) == 0 {
+		result, err = classification.Classify(h.Rules, modules)
+		if err != nil && !errors.Is(err, classification.ErrIncomplete) {
+			slog.ErrorContext(c.Request.Context(), "Error classifying student", "error", err)
+			problem.Internal(c, "Failed to generate transcript")
+			return
+		}
+	}
+
+	code, err := transcript.NewCode()
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error generating transcript code", "error", err)
+		problem.Internal(c, "Failed to generate transcript")
+		return
+	}
+
+	data := transcript.Data{
+		Code:           code,
+		IssuedAt:       time.Now().UTC(),
+		Student:        student,
+		Results:        results,
+		Classification: result,
+		PendingModules: pending,
+	}
+	var buf bytes.Buffer
+	pages, err := transcript.Render(&buf, data)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error rendering transcript", "error", err)
+		problem.Internal(c, "Failed to generate transcript")
+		return
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	record := &models.Transcript{
+		Code:          code,
+		StudentID:     student.ID,
+		StudentNumber: student.StudentID,
+		StudentName:   student.FirstName + " " + student.LastName,
+		Course:        student.Course,
+		Pages:         pages,
+		SHA256:        hex.EncodeToString(sum[:]),
+	}
+	if result != nil {
+		record.Classification = result.Classification
+	}
+	if err := h.Transcripts.Create(record); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error recording transcript", "error", err)
+		problem.Internal(c, "Failed to generate transcript")
+		return
+	}
+
+	// The student number is stored data, so the header is built by mime to
+	// quote or encode it rather than by concatenation
+	c.Header("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": "transcript-" + student.StudentID + ".pdf"}))
+	c.Header("X-Transcript-Code", code)
+	c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
+
+// VerifyTranscript handles GET requests to check a transcript verification
+// code. Both the transcript code and the code printed on each page are accepted.
+func (h *TranscriptHandler) VerifyTranscript(c *gin.Context) {
+	code, page := transcript.ParsePageCode(c.Param("code"))
+
+	record, err := h.Transcripts.GetByCode(code)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error verifying transcript", "error", err)
+		problem.Internal(c, "Failed to verify transcript")
+		return
+	}
+	if record == nil || page > record.Pages {
+		problem.Abort(c, problem.New(http.StatusNotFound, problem.TranscriptUnknownCode, "Unknown verification code").
+			With("valid", false))
+		return
+	}
+
+	response := gin.H{"valid": true, "transcript": record}
+	if page > 0 {
+		response["page"] = page
+	}
+	c.JSON(http.StatusOK, response)
+}
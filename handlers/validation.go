@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/bournemouth-uni-it-api-go/problem"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// emailRegex performs basic email format validation
+var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
+
+// errInvalidEmail is returned by validateStudent for a malformed email address
+var errInvalidEmail = errors.New("Invalid email format")
+
+// errInvalidInitialStatus is returned when a student is created in a status
+// that can only be reached through a transition
+var errInvalidInitialStatus = errors.New("New students must be applicant or enrolled")
+
+// validateStudent applies the binding rules of models.Student and the email
+// format check to a student that was not bound from a request body
+func validateStudent(student *models.Student) error {
+	if err := binding.Validator.ValidateStruct(student); err != nil {
+		return err
+	}
+	if !emailRegex.MatchString(student.Email) {
+		return errInvalidEmail
+	}
+	return nil
+}
+
+// validationMessages turns a validation error into one readable message per
+// field, using JSON field names
+func validationMessages(err error, obj interface{}) []string {
+	fields := fieldErrors(err, obj)
+	messages := make([]string, 0, len(fields))
+	for _, field := range fields {
+		messages = append(messages, field.Detail)
+	}
+	return messages
+}
+
+// fieldErrors turns a validation error into one problem.FieldError per field,
+// using JSON field names and the failed rule as the code
+func fieldErrors(err error, obj interface{}) []problem.FieldError {
+	if errors.Is(err, errInvalidEmail) {
+		return []problem.FieldError{{Field: "email", Code: "email", Detail: errInvalidEmail.Error()}}
+	}
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return []problem.FieldError{{Field: "", Code: "invalid", Detail: err.Error()}}
+	}
+
+	t := reflect.TypeOf(obj)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	fields := make([]problem.FieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		name := fe.Field()
+		if f, ok := t.FieldByName(fe.StructField()); ok {
+			if tag := strings.Split(f.Tag.Get("json"), ",")[0]; tag != "" {
+				name = tag
+			}
+		}
+		field := problem.FieldError{Field: name, Code: fe.Tag(), Detail: name + " failed the " + fe.Tag() + " check"}
+		if fe.Tag() == "required" {
+			field.Detail = name + " is required"
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// invalidStudent returns the problem for a student that failed validateStudent
+func invalidStudent(status int, err error, student *models.Student) *problem.Problem {
+	return problem.New(status, problem.ValidationFailed, "Student is invalid").WithErrors(fieldErrors(err, student)...)
+}
+
+// bindError writes the problem for a request body that could not be bound to
+// obj. Invalid fields are listed; the binding error itself is not revealed.
+func bindError(c *gin.Context, err error, obj interface{}) {
+	var (
+		verrs     validator.ValidationErrors
+		typeErr   *json.UnmarshalTypeError
+		syntaxErr *json.SyntaxError
+	)
+	switch {
+	case errors.As(err, &verrs):
+		problem.Abort(c, problem.New(http.StatusBadRequest, problem.ValidationFailed, "Request body has invalid fields").
+			WithErrors(fieldErrors(err, obj)...))
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			problem.Write(c, http.StatusBadRequest, problem.InvalidBody, "Request body must be a JSON object")
+			return
+		}
+		problem.Abort(c, problem.New(http.StatusBadRequest, problem.ValidationFailed, "Request body has invalid fields").
+			WithErrors(problem.FieldError{Field: typeErr.Field, Code: "type", Detail: typeErr.Field + " has the wrong type"}))
+	case errors.As(err, &syntaxErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		problem.Write(c, http.StatusBadRequest, problem.InvalidBody, "Request body must be valid JSON")
+	default:
+		problem.Write(c, http.StatusBadRequest, problem.InvalidBody, "Request body could not be read")
+	}
+}
@@ -123,7 +123,7 @@ echo '    "first_name": "John",'
 echo '    "last_name": "Doe",'
 echo '    "email": "john.doe@bournemouth.ac.uk",'
 echo '    "student_id": "S12345678",'
-echo '    "course": "Information Technology",'
+echo '    "course": "BSC-IT",'
 echo '    "year_of_study": 2'
 echo '  }'"'"
 
@@ -0,0 +1,51 @@
+package logging
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces values that must not be logged
+const Redacted = "[REDACTED]"
+
+// redactedKeys are attributes whose values are personal data or secrets
+var redactedKeys = map[string]bool{
+	"email":         true,
+	"first_name":    true,
+	"last_name":     true,
+	"password":      true,
+	"token":         true,
+	"authorization": true,
+	"cookie":        true,
+	"api_key":       true,
+}
+
+// emailPattern matches email addresses within text, such as the key of a
+// unique violation in a database error
+var emailPattern = regexp.MustCompile(`[a-zA-Z0-9._%+-]+@([a-zA-Z0-9.-]+\.[a-zA-Z]{2,})`)
+
+// RedactEmails replaces the local part of every email address in s, keeping
+// the domain
+func RedactEmails(s string) string {
+	if !strings.Contains(s, "@") {
+		return s
+	}
+	return emailPattern.ReplaceAllString(s, "***@$1")
+}
+
+// redact is the ReplaceAttr function of the handlers returned by New
+func redact(_ []string, a slog.Attr) slog.Attr {
+	if redactedKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, Redacted)
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(RedactEmails(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			a.Value = slog.StringValue(RedactEmails(err.Error()))
+		}
+	}
+	return a
+}
@@ -1,10 +1,15 @@
 package main
 
 import (
-	"log"
+	"context"
+	"log/slog"
+	"os"
 
 	"github.com/bournemouth-uni-it-api-go/config"
 	"github.com/bournemouth-uni-it-api-go/db"
+	"github.com/bournemouth-uni-it-api-go/logging"
+	"github.com/bournemouth-uni-it-api-go/metrics"
+	"github.com/bournemouth-uni-it-api-go/models"
 	"github.com/bournemouth-uni-it-api-go/router"
 	"github.com/joho/godotenv"
 )
@@ -12,39 +17,78 @@ import (
 func main() {
 	// Load environment variables
 	if err := godotenv.Load(); err != nil {
-		log.Println("Warning: .env file not found")
+		slog.Warn(".env file not found")
 	}
 
 	// Initialize configuration
 	cfg := config.LoadConfig()
 
+	// Log structured records from here on, including those written with the
+	// log package
+	slog.SetDefault(logging.New(os.Stdout, cfg.Log))
+
 	// Run migrations (this will create the database if it doesn't exist)
 	if err := db.RunMigrations(cfg); err != nil {
-		log.Fatalf("Failed to run migrations: %v", err)
+		slog.Error("Failed to run migrations", "error", err)
+		os.Exit(1)
+	}
+
+	// Collect metrics for Prometheus, including the duration of every query
+	var m *metrics.Metrics
+	if cfg.Metrics.Enabled {
+		m = metrics.New()
 	}
 
 	// Initialize database connection
-	database, err := db.InitDB(cfg)
+	database, err := db.InitDB(cfg, m)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		slog.Error("Failed to connect to database", "error", err)
+		os.Exit(1)
 	}
 	defer func() {
 		if closeErr := database.Close(); closeErr != nil {
-			log.Printf("Error closing database: %v", closeErr)
+			slog.Error("Error closing database", "error", closeErr)
 		}
 	}()
 
+	if m != nil {
+		if err := m.RegisterDB(database, cfg.DBName); err != nil {
+			slog.Error("Failed to register database metrics", "error", err)
+			os.Exit(1)
+		}
+		statuses := models.NewPostgresStudentStatusRepository(database)
+		counter := metrics.StudentCounterFunc(func(ctx context.Context) (map[string]int, error) {
+			return statuses.WithAudit(models.Audit{RequestID: logging.RequestID(ctx)}).CountByStatus()
+		})
+		if err := m.RegisterStudents(counter); err != nil {
+			slog.Error("Failed to register student metrics", "error", err)
+			os.Exit(1)
+		}
+	}
+
 	// Insert sample data if table is empty
 	if err := db.InsertSampleData(database); err != nil {
-		log.Printf("Warning: Failed to insert sample data: %v", err)
+		slog.Warn("Failed to insert sample data", "error", err)
+	}
+
+	// Purge deleted students once their retention period has passed
+	if cfg.StudentRetention > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go models.RunStudentPurge(ctx, models.NewPostgresStudentRepository(database), cfg.StudentRetention, cfg.StudentPurgeInterval)
 	}
 
 	// Setup router
-	r := router.SetupRouter(database)
+	r, err := router.SetupRouter(database, cfg, m)
+	if err != nil {
+		slog.Error("Failed to set up router", "error", err)
+		os.Exit(1)
+	}
 
 	// Start server
-	log.Printf("Server starting on port %s", cfg.ServerPort)
+	slog.Info("Server starting", "port", cfg.ServerPort)
 	if err := r.Run(":" + cfg.ServerPort); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+		slog.Error("Failed to start server", "error", err)
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,131 @@
+// Package metrics exposes Prometheus metrics for the API: HTTP requests by
+// route, database queries by operation, the connection pool, and the number
+// of students in each lifecycle state. Each replica serves its own metrics,
+// which Prometheus scrapes and aggregates.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes the name of every metric defined here
+const namespace = "studentapi"
+
+// Options configures the metrics endpoint
+type Options struct {
+	// Enabled serves /metrics and records metrics
+	Enabled bool
+	// Token, if set, must be sent as a bearer token to read /metrics
+	Token string
+}
+
+// Metrics holds the collectors of one API process
+type Metrics struct {
+	// Registry is the registry every collector is registered with
+	Registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	inFlight        prometheus.Gauge
+	queryDuration   *prometheus.HistogramVec
+	// students counts students on each scrape, if registered
+	students StudentCounter
+}
+
+// New creates the collectors and registers them, with the Go runtime and
+// process collectors, in a new registry
+func New() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "HTTP requests handled, by method, route and status code.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Time taken to handle HTTP requests, by method and route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_in_flight",
+			Help:      "HTTP requests being handled.",
+		}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "db",
+			Name:      "query_duration_seconds",
+			Help:      "Time taken by database statements, by operation, table and outcome.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"operation", "table", "outcome"}),
+	}
+	m.Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests, m.requestDuration, m.inFlight, m.queryDuration,
+	)
+	return m
+}
+
+// RequestStarted records that a request is being handled
+func (m *Metrics) RequestStarted() {
+	m.inFlight.Inc()
+}
+
+// RequestFinished records a handled request. route is the route pattern,
+// such as /api/v1/students/:id, so that the number of series stays bounded.
+func (m *Metrics) RequestFinished(method, route string, status int, duration time.Duration) {
+	m.inFlight.Dec()
+	m.requests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	m.requestDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// ObserveQuery records how long a database statement took
+func (m *Metrics) ObserveQuery(operation, table string, duration time.Duration, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	m.queryDuration.WithLabelValues(operation, table, outcome).Observe(duration.Seconds())
+}
+
+// RegisterDB exports the connection pool statistics of db, from
+// sql.DB.Stats, labelled with name
+func (m *Metrics) RegisterDB(db *sql.DB, name string) error {
+	return m.Registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler serves the metrics in the Prometheus text format. A collector that
+// fails is left out rather than failing the whole scrape.
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		promhttp.HandlerFor(m.gatherer(r.Context()), promhttp.HandlerOpts{
+			ErrorHandling: promhttp.ContinueOnError,
+			Registry:      m.Registry,
+		}).ServeHTTP(w, r)
+	})
+}
+
+// gatherer returns the registry together with the collectors that need the
+// context of the scrape
+func (m *Metrics) gatherer(ctx context.Context) prometheus.Gatherer {
+	if m.students == nil {
+		return m.Registry
+	}
+	scrape := prometheus.NewRegistry()
+	scrape.MustRegister(&studentsCollector{ctx: ctx, counter: m.students, desc: studentsDesc})
+	return prometheus.Gatherers{m.Registry, scrape}
+}
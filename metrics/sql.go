@@ -0,0 +1,149 @@
+package metrics
+
+import (
+	"context"
+	"database/sql/driver"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Connector wraps c so that every query and statement run on its
+// connections is timed by operation and table. Statements that are prepared
+// explicitly are not timed; the repositories run queries directly.
+func (m *Metrics) Connector(c driver.Connector) driver.Connector {
+	return &connector{Connector: c, m: m}
+}
+
+type connector struct {
+	driver.Connector
+	m *Metrics
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	cn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: cn, m: c.m}, nil
+}
+
+// conn times the queries of a driver connection. The optional interfaces of
+// the connection are passed through, so database/sql uses it as it would
+// the connection itself.
+type conn struct {
+	driver.Conn
+	m *Metrics
+}
+
+func (c *conn) observe(query string, start time.Time, err error) {
+	if err == driver.ErrSkip {
+		return
+	}
+	operation, table := statement(query)
+	c.m.ObserveQuery(operation, table, time.Since(start), err)
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := q.QueryContext(ctx, query, args)
+	c.observe(query, start, err)
+	return rows, err
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	result, err := e.ExecContext(ctx, query, args)
+	c.observe(query, start, err)
+	return result, err
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return p.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	// Drivers without BeginTx only support the default options
+	return c.Conn.Begin()
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *conn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+// operations are the statement verbs given their own label. Anything else,
+// such as the DDL run by migrations, is counted as other.
+var operations = map[string]bool{
+	"select": true, "insert": true, "update": true, "delete": true, "with": true,
+}
+
+// sqlWord matches identifiers and keywords, with any schema and quotes
+var sqlWord = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_."]*`)
+
+// statement returns the operation of a SQL statement and the first table it
+// reads or writes, e.g. select and students. The table is empty if there is
+// none, as in SELECT set_config(...).
+func statement(query string) (operation, table string) {
+	words := sqlWord.FindAllString(query, 64)
+	if len(words) == 0 {
+		return "other", ""
+	}
+	operation = strings.ToLower(words[0])
+	if !operations[operation] {
+		return "other", ""
+	}
+
+	after := "from"
+	switch operation {
+	case "insert":
+		after = "into"
+	case "update":
+		if len(words) > 1 {
+			return operation, tableName(words[1])
+		}
+		return operation, ""
+	}
+	for i := 1; i < len(words)-1; i++ {
+		if strings.EqualFold(words[i], after) {
+			return operation, tableName(words[i+1])
+		}
+	}
+	return operation, ""
+}
+
+// tableName normalises a table name, dropping quotes and the public schema
+func tableName(word string) string {
+	word = strings.ToLower(strings.ReplaceAll(word, `"`, ""))
+	return strings.TrimPrefix(word, "public.")
+}
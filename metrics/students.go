@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// StudentCounter counts students in each lifecycle state. ctx belongs to the
+// scrape, so that errors logged while counting name its request.
+type StudentCounter interface {
+	CountByStatus(ctx context.Context) (map[string]int, error)
+}
+
+// StudentCounterFunc adapts a function to a StudentCounter
+type StudentCounterFunc func(ctx context.Context) (map[string]int, error)
+
+// CountByStatus calls f(ctx)
+func (f StudentCounterFunc) CountByStatus(ctx context.Context) (map[string]int, error) {
+	return f(ctx)
+}
+
+// studentsCollector counts students when metrics are scraped, so that every
+// replica reports the same numbers from the database
+type studentsCollector struct {
+	ctx     context.Context
+	counter StudentCounter
+	desc    *prometheus.Desc
+}
+
+// studentsDesc describes the number of students in each lifecycle state
+var studentsDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "", "students"),
+	"Students in each lifecycle state.",
+	[]string{"status"}, nil,
+)
+
+// RegisterStudents exports the number of students in each lifecycle state,
+// counted by counter on every scrape. The collector is created for each
+// scrape, with the scrape's context.
+func (m *Metrics) RegisterStudents(counter StudentCounter) error {
+	if m.students != nil {
+		return errors.New("student metrics are already registered")
+	}
+	m.students = counter
+	return nil
+}
+
+func (c *studentsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *studentsCollector) Collect(ch chan<- prometheus.Metric) {
+	counts, err := c.counter.CountByStatus(c.ctx)
+	if err != nil {
+		slog.ErrorContext(c.ctx, "Error counting students for metrics", "error", err)
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+	for status, count := range counts {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(count), status)
+	}
+}
@@ -0,0 +1,357 @@
+package middleware
+
+import (
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/bournemouth-uni-it-api-go/problem"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// PrincipalKey is the gin context key holding the authenticated *Principal
+const PrincipalKey = "principal"
+
+// Methods by which a principal can be authenticated
+const (
+	MethodBearer  = "bearer"
+	MethodAPIKey  = "api_key"
+	MethodSession = "session"
+)
+
+// SessionCookie holds the session ID of the web interface
+const SessionCookie = "bu_session"
+
+// CSRFHeader carries the CSRF token of a session on requests that change data
+const CSRFHeader = "X-CSRF-Token"
+
+// tokenLeeway allows for clock skew between the token issuer and the API
+const tokenLeeway = 30 * time.Second
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	// Subject uniquely identifies the caller, e.g. a staff or student number
+	Subject string
+	Name    string
+	Email   string
+	Roles   []string
+	Scopes  []string
+	// Method is how the caller authenticated: MethodBearer, MethodAPIKey or
+	// MethodSession
+	Method string
+}
+
+// GetPrincipal returns the principal put into the context by Authenticate
+func GetPrincipal(c *gin.Context) (*Principal, bool) {
+	value, ok := c.Get(PrincipalKey)
+	if !ok {
+		return nil, false
+	}
+	p, ok := value.(*Principal)
+	return p, ok
+}
+
+// JWTOptions configures how bearer tokens are validated. At least one key
+// must be given. Issuer and Audience are only checked when set.
+type JWTOptions struct {
+	// HMACSecret verifies HS256 tokens
+	HMACSecret string
+	// RSAPublicKey is a PEM encoded public key that verifies RS256 tokens
+	RSAPublicKey string
+	// JWKSFile is the path of a JSON Web Key Set whose RSA keys verify RS256
+	// tokens, selected by the token's kid header
+	JWKSFile string
+	Issuer   string
+	Audience string
+}
+
+// JWTAuthenticator validates HS256 and RS256 bearer tokens
+type JWTAuthenticator struct {
+	hmacSecret []byte
+	// rsaKey is used for RS256 tokens without a kid
+	rsaKey   *rsa.PublicKey
+	rsaKeys  map[string]*rsa.PublicKey
+	issuer   string
+	audience string
+}
+
+// NewJWTAuthenticator loads the keys described by opts
+func NewJWTAuthenticator(opts JWTOptions) (*JWTAuthenticator, error) {
+	a := &JWTAuthenticator{
+		hmacSecret: []byte(opts.HMACSecret),
+		rsaKeys:    make(map[string]*rsa.PublicKey),
+		issuer:     opts.Issuer,
+		audience:   opts.Audience,
+	}
+
+	if opts.RSAPublicKey != "" {
+		key, err := jwt.ParseRSAPublicKeyFromPEM([]byte(opts.RSAPublicKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA public key: %w", err)
+		}
+		a.rsaKey = key
+	}
+
+	if opts.JWKSFile != "" {
+		keys, err := loadJWKS(opts.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWKS file %s: %w", opts.JWKSFile, err)
+		}
+		for kid, key := range keys {
+			a.rsaKeys[kid] = key
+		}
+		// A lone key also verifies tokens that do not name one
+		if len(keys) == 1 && a.rsaKey == nil {
+			for _, key := range keys {
+				a.rsaKey = key
+			}
+		}
+	}
+
+	if len(a.hmacSecret) == 0 && a.rsaKey == nil && len(a.rsaKeys) == 0 {
+		return nil, errors.New("no JWT verification key configured")
+	}
+	return a, nil
+}
+
+// jwk is an entry of a JSON Web Key Set
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// loadJWKS reads the RSA signing keys of a JSON Web Key Set, keyed by kid.
+// Keys of other types or for encryption are skipped.
+func loadJWKS(path string) (map[string]*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: invalid modulus: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("key %q: invalid exponent", k.Kid)
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no RSA signing keys")
+	}
+	return keys, nil
+}
+
+// key selects the verification key for a token from its algorithm and kid
+func (a *JWTAuthenticator) key(token *jwt.Token) (interface{}, error) {
+	switch token.Method.Alg() {
+	case jwt.SigningMethodHS256.Alg():
+		if len(a.hmacSecret) == 0 {
+			return nil, errors.New("HS256 tokens are not accepted")
+		}
+		return a.hmacSecret, nil
+	case jwt.SigningMethodRS256.Alg():
+		if kid, ok := token.Header["kid"].(string); ok && kid != "" {
+			if key, ok := a.rsaKeys[kid]; ok {
+				return key, nil
+			}
+			return nil, fmt.Errorf("unknown key %q", kid)
+		}
+		if a.rsaKey == nil {
+			return nil, errors.New("RS256 tokens must name their key")
+		}
+		return a.rsaKey, nil
+	}
+	return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+}
+
+// principalClaims are the claims read into a Principal
+type principalClaims struct {
+	jwt.RegisteredClaims
+	Name  string   `json:"name"`
+	Email string   `json:"email"`
+	Roles []string `json:"roles"`
+	Scope string   `json:"scope"`
+}
+
+// Authenticate validates a bearer token and returns its principal. The token
+// must be signed, unexpired and have a subject.
+func (a *JWTAuthenticator) Authenticate(tokenString string) (*Principal, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodRS256.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(tokenLeeway),
+	}
+	if a.issuer != "" {
+		opts = append(opts, jwt.WithIssuer(a.issuer))
+	}
+	if a.audience != "" {
+		opts = append(opts, jwt.WithAudience(a.audience))
+	}
+
+	var claims principalClaims
+	if _, err := jwt.ParseWithClaims(tokenString, &claims, a.key, opts...); err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+
+	return &Principal{
+		Subject: claims.Subject,
+		Name:    claims.Name,
+		Email:   claims.Email,
+		Roles:   claims.Roles,
+		Scopes:  strings.Fields(claims.Scope),
+		Method:  MethodBearer,
+	}, nil
+}
+
+// APIKeyVerifier checks API keys presented with the ApiKey scheme
+type APIKeyVerifier interface {
+	Verify(key string) (*models.APIKey, error)
+}
+
+// apiKeyPrincipal returns the principal of a verified API key, whose scopes
+// are the permissions it was issued with
+func apiKeyPrincipal(k *models.APIKey) *Principal {
+	return &Principal{
+		Subject: k.Subject(),
+		Name:    k.Name,
+		Scopes:  k.Scopes,
+		Method:  MethodAPIKey,
+	}
+}
+
+// SessionVerifier looks up the sessions of the web interface
+type SessionVerifier interface {
+	Get(id string) (*models.Session, error)
+}
+
+// SessionPrincipal returns the principal of a signed-in session
+func SessionPrincipal(s *models.Session) *Principal {
+	return &Principal{
+		Subject: s.Subject,
+		Name:    s.Name,
+		Email:   s.Email,
+		Roles:   s.Roles,
+		Method:  MethodSession,
+	}
+}
+
+// safeMethod reports whether an HTTP method only reads data
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// challenge rejects a request with 401, naming the schemes it accepts
+func challenge(c *gin.Context, keys APIKeyVerifier, bearer string, code problem.Code, message string) {
+	c.Header("WWW-Authenticate", bearer)
+	if keys != nil {
+		c.Writer.Header().Add("WWW-Authenticate", `ApiKey realm="api"`)
+	}
+	problem.Write(c, http.StatusUnauthorized, code, message)
+}
+
+// Authenticate is a middleware that requires a valid bearer token, an API key
+// if keys is set, or a session cookie if sessions is set, on every request
+// and puts its principal into the context. Requests without one are rejected
+// with 401. Requests that change data with a session must also carry its CSRF
+// token.
+func Authenticate(a *JWTAuthenticator, keys APIKeyVerifier, sessions SessionVerifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authorization := c.GetHeader("Authorization")
+		scheme, credentials, _ := strings.Cut(authorization, " ")
+		credentials = strings.TrimSpace(credentials)
+		sessionID, _ := c.Cookie(SessionCookie)
+
+		switch {
+		case strings.EqualFold(scheme, "Bearer") && credentials != "":
+			principal, err := a.Authenticate(credentials)
+			if err != nil {
+				code, message := problem.InvalidToken, "Invalid token"
+				if errors.Is(err, jwt.ErrTokenExpired) {
+					code, message = problem.TokenExpired, "Token has expired"
+				}
+				challenge(c, keys, `Bearer realm="api", error="invalid_token", error_description="`+message+`"`, code, message)
+				return
+			}
+			c.Set(PrincipalKey, principal)
+
+		case keys != nil && strings.EqualFold(scheme, "ApiKey") && credentials != "":
+			key, err := keys.Verify(credentials)
+			if err != nil {
+				code, message := problem.InvalidAPIKey, "Invalid API key"
+				switch {
+				case errors.Is(err, models.ErrAPIKeyExpired):
+					code, message = problem.APIKeyExpired, "API key has expired"
+				case errors.Is(err, models.ErrAPIKeyRevoked):
+					code, message = problem.APIKeyRevoked, "API key has been revoked"
+				case !errors.Is(err, models.ErrInvalidAPIKey):
+					slog.ErrorContext(c.Request.Context(), "Error verifying API key", "error", err)
+					problem.Internal(c, "Failed to verify API key")
+					return
+				}
+				challenge(c, keys, `Bearer realm="api"`, code, message)
+				return
+			}
+			c.Set(PrincipalKey, apiKeyPrincipal(key))
+
+		case sessions != nil && authorization == "" && sessionID != "":
+			session, err := sessions.Get(sessionID)
+			if err != nil {
+				slog.ErrorContext(c.Request.Context(), "Error getting session", "error", err)
+				problem.Internal(c, "Failed to verify session")
+				return
+			}
+			if session == nil {
+				challenge(c, keys, `Bearer realm="api"`, problem.SessionExpired, "Session has expired")
+				return
+			}
+			token := c.GetHeader(CSRFHeader)
+			if !safeMethod(c.Request.Method) &&
+				(token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(session.CSRFToken)) != 1) {
+				problem.Write(c, http.StatusForbidden, problem.InvalidCSRFToken, "Missing or invalid CSRF token")
+				return
+			}
+			c.Set(PrincipalKey, SessionPrincipal(session))
+
+		default:
+			challenge(c, keys, `Bearer realm="api"`, problem.AuthRequired, "Authentication required")
+			return
+		}
+
+		c.Next()
+	}
+}
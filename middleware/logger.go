@@ -1,35 +1,64 @@
 package middleware
 
 import (
-	"log"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
 	"time"
 
+	"github.com/bournemouth-uni-it-api-go/problem"
 	"github.com/gin-gonic/gin"
 )
 
-// Logger is a middleware that logs request details
+// Logger is a middleware that logs each request once it has been handled.
+// Server errors are logged at error level, client errors at warn and the rest
+// at info. Register it after RequestID so that the record names the request.
 func Logger() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Start timer
 		start := time.Now()
 		path := c.Request.URL.Path
-		method := c.Request.Method
 
 		// Process request
 		c.Next()
 
-		// Log request details
-		latency := time.Since(start)
-		statusCode := c.Writer.Status()
-		clientIP := c.ClientIP()
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
 
-		log.Printf("[%s] %s %s %d %s %s",
-			method,
-			path,
-			clientIP,
-			statusCode,
-			latency,
-			c.Errors.String(),
-		)
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Int("bytes", c.Writer.Size()),
+		}
+		if principal, ok := GetPrincipal(c); ok {
+			attrs = append(attrs, slog.String("subject", principal.Subject))
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("errors", c.Errors.String()))
+		}
+		slog.LogAttrs(c.Request.Context(), level, "Request handled", attrs...)
 	}
 }
+
+// Recovery is a middleware that turns a panic in a later handler into a 500
+// problem and logs it with the stack. Register it after Logger so that the
+// request is still logged.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered any) {
+		slog.ErrorContext(c.Request.Context(), "Panic handling request",
+			"panic", fmt.Sprint(recovered), "stack", string(debug.Stack()))
+		problem.Internal(c, "Internal server error")
+	})
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bournemouth-uni-it-api-go/metrics"
+	"github.com/bournemouth-uni-it-api-go/problem"
+	"github.com/gin-gonic/gin"
+)
+
+// unmatchedRoute labels requests that match no route, so that unknown paths
+// do not each get their own series
+const unmatchedRoute = "unmatched"
+
+// Metrics is a middleware that records the count, latency and in-flight
+// number of requests by route. Register it before Recovery so that requests
+// which panic are counted with their 500.
+func Metrics(m *metrics.Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		m.RequestStarted()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		m.RequestFinished(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
+
+// MetricsAuth is a middleware that requires the bearer token token, if it is
+// set, so that only the Prometheus scraper can read the metrics
+func MetricsAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.Next()
+			return
+		}
+		scheme, credentials, _ := strings.Cut(c.GetHeader("Authorization"), " ")
+		if !strings.EqualFold(scheme, "Bearer") ||
+			subtle.ConstantTimeCompare([]byte(strings.TrimSpace(credentials)), []byte(token)) != 1 {
+			c.Header("WWW-Authenticate", `Bearer realm="metrics"`)
+			problem.Write(c, http.StatusUnauthorized, problem.AuthRequired, "A valid metrics token is required")
+			return
+		}
+		c.Next()
+	}
+}
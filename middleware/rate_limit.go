@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bournemouth-uni-it-api-go/problem"
+	"github.com/bournemouth-uni-it-api-go/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitOptions configures how many requests each client may make
+type RateLimitOptions struct {
+	// Default applies to routes without a limit of their own. Each client has
+	// one bucket shared by all of these routes.
+	Default ratelimit.Limit
+	// Routes holds limits for single routes, keyed by ratelimit.Route. Each
+	// client has a separate bucket for each of these routes.
+	Routes map[string]ratelimit.Limit
+	// PerIP applies to every request to the routes guarded by RateLimitByIP,
+	// counted per IP address before the client is authenticated
+	PerIP ratelimit.Limit
+	// Shared keeps buckets in PostgreSQL so that all replicas enforce one
+	// limit; otherwise each replica keeps its own in memory
+	Shared bool
+}
+
+// RateLimit is a middleware that throttles clients with token buckets kept in
+// store. Clients are told their limit in RateLimit-* headers, and requests
+// over it get 429 with Retry-After. It must run after Authenticate, if any,
+// so that clients are keyed by API key or user rather than IP address.
+//
+// If the store fails, requests are let through rather than taking the API
+// down with it.
+func RateLimit(store ratelimit.Store, opts RateLimitOptions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scope := "*"
+		limit := opts.Default
+		if path := c.FullPath(); path != "" {
+			route := ratelimit.Route(c.Request.Method, path)
+			if routeLimit, ok := opts.Routes[route]; ok {
+				scope, limit = route, routeLimit
+			}
+		}
+		if limit.Unlimited() {
+			c.Next()
+			return
+		}
+
+		if !takeRateLimit(c, store, scope+" "+rateLimitClient(c), limit) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// RateLimitByIP is a middleware that throttles each IP address to
+// opts.PerIP. It runs before Authenticate so that requests with bad
+// credentials are throttled before they are checked. The address is only
+// taken from X-Forwarded-For when the router trusts the proxy that set it.
+func RateLimitByIP(store ratelimit.Store, opts RateLimitOptions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if opts.PerIP.Unlimited() {
+			c.Next()
+			return
+		}
+		if !takeRateLimit(c, store, "pre-auth ip:"+c.ClientIP(), opts.PerIP) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// takeRateLimit takes a token from the bucket with the given key and sets
+// the RateLimit-* headers. It reports false, having written a 429 response,
+// if the bucket is empty.
+func takeRateLimit(c *gin.Context, store ratelimit.Store, key string, limit ratelimit.Limit) bool {
+	result, err := store.Take(key, limit)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error checking rate limit", "error", err)
+		return true
+	}
+
+	c.Header("RateLimit-Limit", strconv.Itoa(limit.Requests))
+	c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+	c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Requests, ceilSeconds(limit.Period)))
+
+	if !result.Allowed {
+		retryAfter := ceilSeconds(result.RetryAfter)
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		problem.Write(c, http.StatusTooManyRequests, problem.RateLimited,
+			fmt.Sprintf("Rate limit of %d requests per %s exceeded, retry in %d seconds", limit.Requests, limit.Period, retryAfter))
+		return false
+	}
+	return true
+}
+
+// rateLimitClient identifies the client of a request: the API key or user
+// authenticated by Authenticate, or else the client's IP address
+func rateLimitClient(c *gin.Context) string {
+	if p, ok := GetPrincipal(c); ok {
+		if p.Method == MethodAPIKey {
+			return p.Subject
+		}
+		return "user:" + p.Subject
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// ceilSeconds rounds d up to whole seconds
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
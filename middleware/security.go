@@ -0,0 +1,176 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bournemouth-uni-it-api-go/problem"
+	"github.com/gin-gonic/gin"
+)
+
+// CORSOptions configures which other sites may call the API from the browser
+type CORSOptions struct {
+	// AllowedOrigins lists origins such as https://timetable.bournemouth.ac.uk,
+	// or "*" for any. CORS is disabled if it is empty.
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	// AllowCredentials lets browsers send cookies. It is ignored for "*".
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response
+	MaxAge time.Duration
+}
+
+// corsExposedHeaders are response headers that browsers let other sites read
+var corsExposedHeaders = strings.Join([]string{
+	"ETag", "Location", "Retry-After", RequestIDHeader,
+	"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy",
+}, ", ")
+
+// CORS is a middleware that lets the allowed origins call the API from the
+// browser. Preflight requests are answered here, before authentication, with
+// 204 if the origin, method and headers are allowed and 403 otherwise.
+// Requests from other origins are served without CORS headers, so browsers
+// hide the response from the calling page.
+func CORS(opts CORSOptions) gin.HandlerFunc {
+	anyOrigin := containsFold(opts.AllowedOrigins, "*")
+	methods := strings.Join(opts.AllowedMethods, ", ")
+	headers := strings.Join(opts.AllowedHeaders, ", ")
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" || len(opts.AllowedOrigins) == 0 {
+			c.Next()
+			return
+		}
+		c.Writer.Header().Add("Vary", "Origin")
+
+		allowed := anyOrigin || containsFold(opts.AllowedOrigins, origin)
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		if preflight {
+			c.Writer.Header().Add("Vary", "Access-Control-Request-Method")
+			c.Writer.Header().Add("Vary", "Access-Control-Request-Headers")
+			if !allowed {
+				problem.Write(c, http.StatusForbidden, problem.CORSOriginNotAllowed, "Origin not allowed")
+				return
+			}
+			if !containsFold(opts.AllowedMethods, c.GetHeader("Access-Control-Request-Method")) {
+				problem.Write(c, http.StatusForbidden, problem.CORSMethodNotAllowed, "Method not allowed from other origins")
+				return
+			}
+			for _, header := range strings.Split(c.GetHeader("Access-Control-Request-Headers"), ",") {
+				if header = strings.TrimSpace(header); header != "" && !containsFold(opts.AllowedHeaders, header) {
+					problem.Write(c, http.StatusForbidden, problem.CORSHeaderNotAllowed, "Header "+strconv.Quote(header)+" not allowed from other origins")
+					return
+				}
+			}
+		}
+		if !allowed {
+			c.Next()
+			return
+		}
+
+		if anyOrigin {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Access-Control-Allow-Origin", origin)
+			if opts.AllowCredentials {
+				c.Header("Access-Control-Allow-Credentials", "true")
+			}
+		}
+
+		if preflight {
+			c.Header("Access-Control-Allow-Methods", methods)
+			if headers != "" {
+				c.Header("Access-Control-Allow-Headers", headers)
+			}
+			if opts.MaxAge > 0 {
+				c.Header("Access-Control-Max-Age", strconv.Itoa(int(opts.MaxAge.Seconds())))
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Header("Access-Control-Expose-Headers", corsExposedHeaders)
+		c.Next()
+	}
+}
+
+// containsFold reports whether list contains s, ignoring case
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// SecurityOptions configures the security headers sent with every response
+type SecurityOptions struct {
+	// HSTSMaxAge is how long browsers must only use HTTPS. Zero sends no
+	// Strict-Transport-Security header, for deployments without HTTPS.
+	HSTSMaxAge time.Duration
+}
+
+// apiContentSecurityPolicy forbids everything, since API responses are data
+// and never need to load anything or be framed
+const apiContentSecurityPolicy = "default-src 'none'; frame-ancestors 'none'"
+
+// cspNonceKey is the gin context key holding the CSP nonce of a page
+const cspNonceKey = "csp_nonce"
+
+// SecurityHeaders is a middleware that sends a strict set of security headers
+// with every response. Pages that need to run scripts replace the
+// Content-Security-Policy with PageNonce.
+func SecurityHeaders(opts SecurityOptions) gin.HandlerFunc {
+	hsts := ""
+	if opts.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(int(opts.HSTSMaxAge.Seconds())) + "; includeSubDomains"
+	}
+
+	return func(c *gin.Context) {
+		h := c.Writer.Header()
+		h.Set("Content-Security-Policy", apiContentSecurityPolicy)
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("X-Frame-Options", "DENY")
+		h.Set("Referrer-Policy", "no-referrer")
+		h.Set("Cross-Origin-Opener-Policy", "same-origin")
+		if hsts != "" {
+			h.Set("Strict-Transport-Security", hsts)
+		}
+		c.Next()
+	}
+}
+
+// PageNonce returns a random nonce for the inline scripts and styles of an
+// HTML page, and sets a Content-Security-Policy that only allows those
+// carrying it. Inline event handlers and style attributes are not allowed.
+func PageNonce(c *gin.Context) string {
+	if nonce := c.GetString(cspNonceKey); nonce != "" {
+		return nonce
+	}
+
+	b := make([]byte, 16)
+	// crypto/rand.Read does not fail on supported platforms
+	_, _ = rand.Read(b)
+	nonce := base64.StdEncoding.EncodeToString(b)
+	c.Set(cspNonceKey, nonce)
+
+	c.Header("Content-Security-Policy", strings.Join([]string{
+		"default-src 'self'",
+		"script-src 'nonce-" + nonce + "'",
+		"style-src 'nonce-" + nonce + "'",
+		"img-src 'self' data:",
+		"connect-src 'self'",
+		"object-src 'none'",
+		"base-uri 'none'",
+		"frame-ancestors 'none'",
+	}, "; "))
+	return nonce
+}
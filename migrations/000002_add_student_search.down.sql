@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_students_email_trgm;
+DROP INDEX IF EXISTS idx_students_last_name_trgm;
+DROP INDEX IF EXISTS idx_students_first_name_trgm;
+DROP INDEX IF EXISTS idx_students_search_vector;
+ALTER TABLE students DROP COLUMN IF EXISTS search_vector;
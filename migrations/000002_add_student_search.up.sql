@@ -0,0 +1,15 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE students ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', coalesce(first_name, '')), 'A') ||
+        setweight(to_tsvector('simple', coalesce(last_name, '')), 'A') ||
+        setweight(to_tsvector('simple', coalesce(student_id, '')), 'B') ||
+        setweight(to_tsvector('simple', coalesce(email, '')), 'B') ||
+        setweight(to_tsvector('simple', coalesce(course, '')), 'C')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_students_search_vector ON students USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_students_first_name_trgm ON students USING GIN (first_name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_students_last_name_trgm ON students USING GIN (last_name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_students_email_trgm ON students USING GIN (email gin_trgm_ops);
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_students_course;
+ALTER TABLE students DROP CONSTRAINT IF EXISTS fk_students_course;
+
+-- Restore free-text course titles
+UPDATE students s
+SET course = c.title
+FROM courses c
+WHERE c.code = s.course;
+
+DROP TABLE IF EXISTS courses;
@@ -0,0 +1,45 @@
+CREATE TABLE IF NOT EXISTS courses (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(20) NOT NULL UNIQUE,
+    title VARCHAR(200) NOT NULL,
+    department VARCHAR(200) NOT NULL,
+    level VARCHAR(20) NOT NULL CHECK (level IN ('foundation', 'undergraduate', 'postgraduate')),
+    duration_years INT NOT NULL CHECK (duration_years BETWEEN 1 AND 7),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO courses (code, title, department, level, duration_years) VALUES
+    ('BSC-IT', 'Information Technology', 'Computing and Informatics', 'undergraduate', 3),
+    ('BSC-CS', 'Computer Science', 'Computing and Informatics', 'undergraduate', 3),
+    ('BSC-SE', 'Software Engineering', 'Computing and Informatics', 'undergraduate', 3)
+ON CONFLICT (code) DO NOTHING;
+
+-- Map the free-text spellings already in use to the canonical courses
+UPDATE students s
+SET course = a.code
+FROM (VALUES
+    ('information technology', 'BSC-IT'),
+    ('info tech', 'BSC-IT'),
+    ('it', 'BSC-IT'),
+    ('computer science', 'BSC-CS'),
+    ('cs', 'BSC-CS'),
+    ('software engineering', 'BSC-SE'),
+    ('se', 'BSC-SE')
+) AS a(alias, code)
+WHERE lower(trim(s.course)) = a.alias;
+
+-- Any other value becomes a placeholder course to be tidied up by registry
+INSERT INTO courses (code, title, department, level, duration_years)
+SELECT 'LEGACY-' || row_number() OVER (ORDER BY d.course), d.course, 'Unassigned', 'undergraduate', 3
+FROM (SELECT DISTINCT course FROM students WHERE course NOT IN (SELECT code FROM courses)) d;
+
+UPDATE students s
+SET course = c.code
+FROM courses c
+WHERE c.code LIKE 'LEGACY-%' AND c.title = s.course;
+
+ALTER TABLE students
+    ADD CONSTRAINT fk_students_course FOREIGN KEY (course) REFERENCES courses (code) ON UPDATE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_students_course ON students (course);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS enrolments;
+DROP TABLE IF EXISTS modules;
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS modules (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(20) NOT NULL UNIQUE,
+    title VARCHAR(200) NOT NULL,
+    level INT NOT NULL CHECK (level BETWEEN 1 AND 7),
+    credits INT NOT NULL CHECK (credits > 0),
+    capacity INT NOT NULL CHECK (capacity > 0),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS enrolments (
+    id SERIAL PRIMARY KEY,
+    student_id INT NOT NULL REFERENCES students (id) ON DELETE CASCADE,
+    module_id INT NOT NULL REFERENCES modules (id),
+    academic_year VARCHAR(7) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (student_id, module_id, academic_year)
+);
+
+CREATE INDEX IF NOT EXISTS idx_enrolments_module_year ON enrolments (module_id, academic_year);
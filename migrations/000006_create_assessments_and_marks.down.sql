@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS marks;
+DROP TABLE IF EXISTS assessments;
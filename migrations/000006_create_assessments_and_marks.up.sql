@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS assessments (
+    id SERIAL PRIMARY KEY,
+    module_id INT NOT NULL REFERENCES modules (id),
+    name VARCHAR(200) NOT NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('coursework', 'exam')),
+    weighting INT NOT NULL CHECK (weighting BETWEEN 1 AND 100),
+    max_mark INT NOT NULL CHECK (max_mark > 0),
+    due_date DATE NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_assessments_module ON assessments (module_id);
+
+CREATE TABLE IF NOT EXISTS marks (
+    id SERIAL PRIMARY KEY,
+    assessment_id INT NOT NULL REFERENCES assessments (id),
+    enrolment_id INT NOT NULL REFERENCES enrolments (id) ON DELETE CASCADE,
+    mark NUMERIC(6, 2) NOT NULL CHECK (mark >= 0),
+    original_mark NUMERIC(6, 2),
+    status VARCHAR(20) NOT NULL DEFAULT 'submitted' CHECK (status IN ('submitted', 'moderated', 'published')),
+    moderation_note TEXT NOT NULL DEFAULT '',
+    submitted_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    moderated_at TIMESTAMP,
+    published_at TIMESTAMP,
+    UNIQUE (assessment_id, enrolment_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_marks_enrolment ON marks (enrolment_id);
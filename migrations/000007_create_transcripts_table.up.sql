@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS transcripts (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(20) NOT NULL UNIQUE,
+    student_id INT NOT NULL REFERENCES students (id) ON DELETE CASCADE,
+    student_number VARCHAR(50) NOT NULL,
+    student_name VARCHAR(200) NOT NULL,
+    course VARCHAR(100) NOT NULL,
+    classification VARCHAR(50) NOT NULL DEFAULT '',
+    pages INT NOT NULL CHECK (pages > 0),
+    sha256 CHAR(64) NOT NULL,
+    issued_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_transcripts_student ON transcripts (student_id);
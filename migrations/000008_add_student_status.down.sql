@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS student_status_transitions;
+DROP INDEX IF EXISTS idx_students_status;
+ALTER TABLE students DROP COLUMN IF EXISTS status;
@@ -0,0 +1,17 @@
+ALTER TABLE students ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'enrolled'
+    CHECK (status IN ('applicant', 'enrolled', 'interrupted', 'withdrawn', 'graduated', 'deleted'));
+
+CREATE INDEX IF NOT EXISTS idx_students_status ON students (status);
+
+CREATE TABLE IF NOT EXISTS student_status_transitions (
+    id SERIAL PRIMARY KEY,
+    student_id INT NOT NULL REFERENCES students (id) ON DELETE CASCADE,
+    from_status VARCHAR(20) NOT NULL,
+    to_status VARCHAR(20) NOT NULL,
+    reason VARCHAR(50) NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    effective_date DATE NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_student_status_transitions_student ON student_status_transitions (student_id, effective_date);
@@ -0,0 +1,3 @@
+DELETE FROM students WHERE deleted_at IS NOT NULL;
+DROP INDEX IF EXISTS idx_students_deleted_at;
+ALTER TABLE students DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS students_history ON students;
+DROP FUNCTION IF EXISTS record_student_history();
+DROP TABLE IF EXISTS student_history;
+DROP FUNCTION IF EXISTS reject_student_history_update();
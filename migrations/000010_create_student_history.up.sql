@@ -0,0 +1,70 @@
+CREATE TABLE IF NOT EXISTS student_history (
+    id BIGSERIAL PRIMARY KEY,
+    student_id INT NOT NULL REFERENCES students (id) ON DELETE CASCADE,
+    action VARCHAR(20) NOT NULL,
+    changed_fields TEXT[] NOT NULL DEFAULT '{}',
+    before JSONB,
+    after JSONB NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    request_id VARCHAR(128) NOT NULL DEFAULT '',
+    changed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_student_history_student ON student_history (student_id, changed_at);
+
+-- Every insert into and update of students is recorded. The actor and request
+-- ID are read from the app.actor and app.request_id settings of the
+-- transaction; changes made without them are attributed to "system".
+CREATE OR REPLACE FUNCTION record_student_history() RETURNS trigger AS $$
+DECLARE
+    old_row JSONB;
+    new_row JSONB := to_jsonb(NEW) - 'search_vector';
+    change VARCHAR(20) := 'update';
+    fields TEXT[];
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        change := 'create';
+    ELSE
+        old_row := to_jsonb(OLD) - 'search_vector';
+        IF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+            change := 'delete';
+        ELSIF OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+            change := 'restore';
+        END IF;
+    END IF;
+
+    SELECT COALESCE(array_agg(n.key ORDER BY n.key), '{}') INTO fields
+    FROM jsonb_each(new_row) n
+    WHERE n.key NOT IN ('version', 'updated_at') AND n.value IS DISTINCT FROM old_row -> n.key;
+
+    INSERT INTO student_history (student_id, action, changed_fields, before, after, actor, request_id)
+    VALUES (NEW.id, change, fields, old_row, new_row,
+        COALESCE(NULLIF(current_setting('app.actor', true), ''), 'system'),
+        COALESCE(current_setting('app.request_id', true), ''));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS students_history ON students;
+CREATE TRIGGER students_history
+    AFTER INSERT OR UPDATE ON students
+    FOR EACH ROW EXECUTE FUNCTION record_student_history();
+
+-- History is append-only; rows are only removed when their student is purged
+CREATE OR REPLACE FUNCTION reject_student_history_update() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'student_history is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS student_history_append_only ON student_history;
+CREATE TRIGGER student_history_append_only
+    BEFORE UPDATE ON student_history
+    FOR EACH ROW EXECUTE FUNCTION reject_student_history_update();
+
+-- Students that existed before history was kept start with a baseline of
+-- their values as of their last update
+INSERT INTO student_history (student_id, action, changed_fields, after, actor, changed_at)
+SELECT s.id, 'baseline', '{}', to_jsonb(s) - 'search_vector', 'migration', COALESCE(s.updated_at, s.created_at, CURRENT_TIMESTAMP)
+FROM students s
+WHERE NOT EXISTS (SELECT 1 FROM student_history h WHERE h.student_id = s.id);
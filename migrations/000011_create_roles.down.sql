@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS personal_tutors;
+DROP TABLE IF EXISTS role_members;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS roles;
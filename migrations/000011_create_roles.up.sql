@@ -0,0 +1,51 @@
+CREATE TABLE IF NOT EXISTS roles (
+    name VARCHAR(50) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role VARCHAR(50) NOT NULL REFERENCES roles (name) ON DELETE CASCADE,
+    permission VARCHAR(100) NOT NULL,
+    PRIMARY KEY (role, permission)
+);
+
+-- Roles granted to a token subject in addition to the roles claim of its tokens
+CREATE TABLE IF NOT EXISTS role_members (
+    role VARCHAR(50) NOT NULL REFERENCES roles (name) ON DELETE CASCADE,
+    subject VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (role, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_role_members_subject ON role_members (subject);
+
+CREATE TABLE IF NOT EXISTS personal_tutors (
+    tutor_subject VARCHAR(255) NOT NULL,
+    student_id INT NOT NULL REFERENCES students (id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (tutor_subject, student_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_personal_tutors_student ON personal_tutors (student_id);
+
+INSERT INTO roles (name, description) VALUES
+    ('admin', 'Manages roles and has full access to student records'),
+    ('registry', 'Registry staff who can read and edit any student'),
+    ('tutor', 'Personal tutors who can read their tutees'),
+    ('student', 'Students who can read and partially edit their own record')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('admin', 'roles:manage'),
+    ('admin', 'students:read'),
+    ('admin', 'students:write'),
+    ('admin', 'students:delete'),
+    ('registry', 'students:read'),
+    ('registry', 'students:write'),
+    ('registry', 'students:delete'),
+    ('tutor', 'students:read:tutees'),
+    ('student', 'students:read:own'),
+    ('student', 'students:write:own')
+ON CONFLICT DO NOTHING;
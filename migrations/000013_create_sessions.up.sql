@@ -0,0 +1,24 @@
+-- Pending OpenID Connect logins, keyed by a hash of their state parameter
+CREATE TABLE IF NOT EXISTS oidc_logins (
+    state_hash CHAR(64) PRIMARY KEY,
+    code_verifier VARCHAR(128) NOT NULL,
+    nonce VARCHAR(64) NOT NULL,
+    return_to TEXT NOT NULL DEFAULT '/',
+    expires_at TIMESTAMP NOT NULL
+);
+
+-- Browser sessions of the web interface, keyed by a hash of the session ID
+-- held in the session cookie
+CREATE TABLE IF NOT EXISTS sessions (
+    id_hash CHAR(64) PRIMARY KEY,
+    subject VARCHAR(255) NOT NULL,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    roles TEXT[] NOT NULL DEFAULT '{}',
+    id_token TEXT NOT NULL DEFAULT '',
+    csrf_token VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions (expires_at);
@@ -0,0 +1,12 @@
+-- Token buckets of the shared rate limit store, used when several replicas of
+-- the API must enforce one limit per client
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    key TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL DEFAULT 0,
+    -- updated_at is NULL until the bucket is first used
+    updated_at TIMESTAMPTZ,
+    -- full_at is when the bucket will have refilled and can be dropped
+    full_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_full_at ON rate_limit_buckets(full_at);
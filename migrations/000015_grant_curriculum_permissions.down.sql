@@ -0,0 +1,4 @@
+DELETE FROM role_permissions
+WHERE permission IN ('courses:write', 'modules:write', 'enrolments:write', 'marks:write', 'marks:moderate', 'marks:publish');
+
+DELETE FROM roles WHERE name = 'academic';
@@ -0,0 +1,18 @@
+INSERT INTO roles (name, description) VALUES
+    ('academic', 'Academic staff who submit and moderate marks')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('admin', 'courses:write'),
+    ('admin', 'modules:write'),
+    ('admin', 'enrolments:write'),
+    ('admin', 'marks:write'),
+    ('admin', 'marks:moderate'),
+    ('admin', 'marks:publish'),
+    ('registry', 'courses:write'),
+    ('registry', 'modules:write'),
+    ('registry', 'enrolments:write'),
+    ('registry', 'marks:publish'),
+    ('academic', 'marks:write'),
+    ('academic', 'marks:moderate')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,3 @@
+ALTER TABLE marks
+    DROP CONSTRAINT IF EXISTS marks_enrolment_id_fkey,
+    ADD CONSTRAINT marks_enrolment_id_fkey FOREIGN KEY (enrolment_id) REFERENCES enrolments (id) ON DELETE CASCADE;
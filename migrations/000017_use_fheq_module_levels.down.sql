@@ -0,0 +1,5 @@
+ALTER TABLE modules
+    DROP CONSTRAINT IF EXISTS modules_level_check,
+    ADD CONSTRAINT modules_level_check CHECK (level BETWEEN 1 AND 7);
+
+UPDATE modules SET level = level - 3 WHERE level BETWEEN 4 AND 7;
@@ -0,0 +1,8 @@
+-- Module levels were the year of study they are taught in, which enrolment
+-- required to match. They are now FHEQ levels, where the first year of an
+-- honours degree is level 4.
+UPDATE modules SET level = level + 3 WHERE level BETWEEN 1 AND 4;
+
+ALTER TABLE modules
+    DROP CONSTRAINT IF EXISTS modules_level_check,
+    ADD CONSTRAINT modules_level_check CHECK (level BETWEEN 4 AND 7);
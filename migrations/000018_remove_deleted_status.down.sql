@@ -0,0 +1,4 @@
+ALTER TABLE students
+    DROP CONSTRAINT IF EXISTS students_status_check,
+    ADD CONSTRAINT students_status_check
+        CHECK (status IN ('applicant', 'enrolled', 'interrupted', 'withdrawn', 'graduated', 'deleted'));
@@ -0,0 +1,18 @@
+-- Deleting a student sets deleted_at; a deleted lifecycle state duplicated it.
+-- Students moved to that state are deleted instead, and go back to the state
+-- they were in before.
+UPDATE students s
+SET status = COALESCE((
+        SELECT t.from_status
+        FROM student_status_transitions t
+        WHERE t.student_id = s.id AND t.to_status = 'deleted'
+        ORDER BY t.effective_date DESC, t.id DESC
+        LIMIT 1
+    ), 'withdrawn'),
+    deleted_at = COALESCE(s.deleted_at, CURRENT_TIMESTAMP)
+WHERE s.status = 'deleted';
+
+ALTER TABLE students
+    DROP CONSTRAINT IF EXISTS students_status_check,
+    ADD CONSTRAINT students_status_check
+        CHECK (status IN ('applicant', 'enrolled', 'interrupted', 'withdrawn', 'graduated'));
@@ -0,0 +1,283 @@
+package models
+
+import (
+	"database/sql"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Role is a named set of permissions. Callers hold the roles named in their
+// token and any they have been made a member of.
+type Role struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// AccessRepository defines the interface for role, membership and personal
+// tutor data operations
+type AccessRepository interface {
+	ListRoles() ([]Role, error)
+	GetRole(name string) (*Role, error)
+	SaveRole(role *Role) error
+	DeleteRole(name string) error
+	ListMembers(role string) ([]string, error)
+	AddMember(role, subject string) error
+	RemoveMember(role, subject string) error
+	ListTutees(tutor string) ([]int, error)
+	AssignTutee(tutor string, studentID int) error
+	UnassignTutee(tutor string, studentID int) error
+	Permissions(subject string, roles []string) ([]string, error)
+	IsTutorOf(tutor string, studentID int) (bool, error)
+	// WithAudit returns a repository that logs errors with a's request ID
+	WithAudit(a Audit) AccessRepository
+}
+
+// PostgresAccessRepository implements AccessRepository for PostgreSQL
+type PostgresAccessRepository struct {
+	DB    *sql.DB
+	audit Audit
+}
+
+// NewPostgresAccessRepository creates a new PostgresAccessRepository
+func NewPostgresAccessRepository(db *sql.DB) *PostgresAccessRepository {
+	return &PostgresAccessRepository{DB: db}
+}
+
+// WithAudit returns a copy of the repository whose logged errors name the
+// request a was made in
+func (r *PostgresAccessRepository) WithAudit(a Audit) AccessRepository {
+	audited := *r
+	audited.audit = a
+	return &audited
+}
+
+// roleColumns is the column list selected for every role query, in the order
+// expected by scanRole
+const roleColumns = `r.name, r.description, r.created_at, r.updated_at,
+	COALESCE(ARRAY(SELECT permission FROM role_permissions p WHERE p.role = r.name ORDER BY permission), '{}')`
+
+// scanRole reads a row selected with roleColumns into a Role
+func scanRole(row rowScanner, role *Role) error {
+	var permissions pq.StringArray
+	if err := row.Scan(&role.Name, &role.Description, &role.CreatedAt, &role.UpdatedAt, &permissions); err != nil {
+		return err
+	}
+	role.Permissions = []string(permissions)
+	return nil
+}
+
+// ListRoles retrieves all roles with their permissions ordered by name
+func (r *PostgresAccessRepository) ListRoles() ([]Role, error) {
+	rows, err := r.DB.Query(`SELECT ` + roleColumns + ` FROM roles r ORDER BY r.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := rows.Close(); closeErr != nil {
+			slog.ErrorContext(r.audit.logContext(), "Error closing rows", "error", closeErr)
+		}
+	}()
+
+	roles := []Role{}
+	for rows.Next() {
+		var role Role
+		if err := scanRole(rows, &role); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
+
+// GetRole retrieves a role by name, or nil if there is none
+func (r *PostgresAccessRepository) GetRole(name string) (*Role, error) {
+	var role Role
+	err := scanRole(r.DB.QueryRow(`SELECT `+roleColumns+` FROM roles r WHERE r.name = $1`, name), &role)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &role, nil
+}
+
+// SaveRole creates a role or updates the description of an existing one, and
+// replaces its permissions with role.Permissions
+func (r *PostgresAccessRepository) SaveRole(role *Role) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO roles (name, description)
+		VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET description = EXCLUDED.description, updated_at = CURRENT_TIMESTAMP`,
+		role.Name, role.Description); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM role_permissions WHERE role = $1`, role.Name); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO role_permissions (role, permission)
+		SELECT $1, unnest($2::text[])
+		ON CONFLICT DO NOTHING`,
+		role.Name, pq.Array(role.Permissions)); err != nil {
+		return err
+	}
+
+	if err := scanRole(tx.QueryRow(`SELECT `+roleColumns+` FROM roles r WHERE r.name = $1`, role.Name), role); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteRole removes a role along with its permissions and memberships
+func (r *PostgresAccessRepository) DeleteRole(name string) error {
+	return r.execOne(`DELETE FROM roles WHERE name = $1`, name)
+}
+
+// ListMembers retrieves the subjects that have been made members of a role
+func (r *PostgresAccessRepository) ListMembers(role string) ([]string, error) {
+	return r.queryStrings(`SELECT subject FROM role_members WHERE role = $1 ORDER BY subject`, role)
+}
+
+// AddMember grants a role to a subject. Adding an existing member is not an
+// error; a role that does not exist is a foreign key violation.
+func (r *PostgresAccessRepository) AddMember(role, subject string) error {
+	_, err := r.DB.Exec(`
+		INSERT INTO role_members (role, subject) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`, role, subject)
+	return err
+}
+
+// RemoveMember revokes a role from a subject. It returns sql.ErrNoRows if the
+// subject was not a member.
+func (r *PostgresAccessRepository) RemoveMember(role, subject string) error {
+	return r.execOne(`DELETE FROM role_members WHERE role = $1 AND subject = $2`, role, subject)
+}
+
+// ListTutees retrieves the IDs of the students a personal tutor is assigned to
+func (r *PostgresAccessRepository) ListTutees(tutor string) ([]int, error) {
+	rows, err := r.DB.Query(`
+		SELECT pt.student_id FROM personal_tutors pt
+		JOIN students s ON s.id = pt.student_id AND s.`+notDeleted+`
+		WHERE pt.tutor_subject = $1
+		ORDER BY pt.student_id`, tutor)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := rows.Close(); closeErr != nil {
+			slog.ErrorContext(r.audit.logContext(), "Error closing rows", "error", closeErr)
+		}
+	}()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// AssignTutee makes a subject the personal tutor of a student. A student that
+// does not exist is a foreign key violation.
+func (r *PostgresAccessRepository) AssignTutee(tutor string, studentID int) error {
+	_, err := r.DB.Exec(`
+		INSERT INTO personal_tutors (tutor_subject, student_id) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`, tutor, studentID)
+	return err
+}
+
+// UnassignTutee removes a personal tutor assignment. It returns sql.ErrNoRows
+// if there was none.
+func (r *PostgresAccessRepository) UnassignTutee(tutor string, studentID int) error {
+	return r.execOne(`DELETE FROM personal_tutors WHERE tutor_subject = $1 AND student_id = $2`, tutor, studentID)
+}
+
+// Permissions returns the sorted, distinct permissions granted to a subject
+// by the given roles, usually those of its token, and the roles it is a
+// member of
+func (r *PostgresAccessRepository) Permissions(subject string, roles []string) ([]string, error) {
+	permissions, err := r.queryStrings(`
+		SELECT DISTINCT permission FROM role_permissions
+		WHERE role = ANY($1::text[])
+		   OR role IN (SELECT role FROM role_members WHERE subject = $2)`,
+		pq.Array(roles), subject)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(permissions)
+	return permissions, nil
+}
+
+// IsTutorOf reports whether a subject is the personal tutor of a student
+func (r *PostgresAccessRepository) IsTutorOf(tutor string, studentID int) (bool, error) {
+	var exists bool
+	err := r.DB.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM personal_tutors WHERE tutor_subject = $1 AND student_id = $2)`,
+		tutor, studentID).Scan(&exists)
+	return exists, err
+}
+
+// queryStrings runs a query selecting a single text column
+func (r *PostgresAccessRepository) queryStrings(query string, args ...interface{}) ([]string, error) {
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := rows.Close(); closeErr != nil {
+			slog.ErrorContext(r.audit.logContext(), "Error closing rows", "error", closeErr)
+		}
+	}()
+
+	values := []string{}
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	return values, rows.Err()
+}
+
+// execOne runs a statement that should affect a single row, returning
+// sql.ErrNoRows if it affected none
+func (r *PostgresAccessRepository) execOne(query string, args ...interface{}) error {
+	result, err := r.DB.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
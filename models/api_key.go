@@ -0,0 +1,279 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// apiKeyPrefix starts every API key so that leaked keys are easy to spot
+const apiKeyPrefix = "bu"
+
+// apiKeyTouchInterval limits how often the last use of a key is written
+const apiKeyTouchInterval = time.Minute
+
+var (
+	// ErrInvalidAPIKey is returned for a key that is malformed or unknown
+	ErrInvalidAPIKey = errors.New("invalid API key")
+	// ErrAPIKeyExpired is returned for a key past its expiry time
+	ErrAPIKeyExpired = errors.New("API key has expired")
+	// ErrAPIKeyRevoked is returned for a key that has been revoked
+	ErrAPIKeyRevoked = errors.New("API key has been revoked")
+)
+
+// APIKey is a credential for machine-to-machine integrations. Only a hash of
+// its secret is stored; the key itself is shown once when it is issued.
+type APIKey struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedBy  string     `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// Subject identifies the key as the caller of a request
+func (k *APIKey) Subject() string {
+	return "api-key:" + k.Name
+}
+
+// APIKeyRepository defines the interface for API key data operations
+type APIKeyRepository interface {
+	List() ([]APIKey, error)
+	GetByID(id int) (*APIKey, error)
+	Create(key *APIKey) (string, error)
+	Rotate(id int) (*APIKey, string, error)
+	Revoke(id int) (*APIKey, error)
+	Verify(key string) (*APIKey, error)
+	// WithAudit returns a repository that logs errors with a's request ID
+	WithAudit(a Audit) APIKeyRepository
+}
+
+// PostgresAPIKeyRepository implements APIKeyRepository for PostgreSQL
+type PostgresAPIKeyRepository struct {
+	DB    *sql.DB
+	audit Audit
+}
+
+// NewPostgresAPIKeyRepository creates a new PostgresAPIKeyRepository
+func NewPostgresAPIKeyRepository(db *sql.DB) *PostgresAPIKeyRepository {
+	return &PostgresAPIKeyRepository{DB: db}
+}
+
+// WithAudit returns a copy of the repository whose logged errors name the
+// request a was made in
+func (r *PostgresAPIKeyRepository) WithAudit(a Audit) APIKeyRepository {
+	audited := *r
+	audited.audit = a
+	return &audited
+}
+
+// apiKeyColumns is the column list selected for every API key query, in the
+// order expected by scanAPIKey
+const apiKeyColumns = `id, name, prefix, scopes, expires_at, last_used_at, revoked_at, created_by, created_at, updated_at`
+
+// scanAPIKey reads a row selected with apiKeyColumns into an APIKey
+func scanAPIKey(row rowScanner, k *APIKey) error {
+	var scopes pq.StringArray
+	if err := row.Scan(&k.ID, &k.Name, &k.Prefix, &scopes, &k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt, &k.CreatedBy, &k.CreatedAt, &k.UpdatedAt); err != nil {
+		return err
+	}
+	k.Scopes = []string(scopes)
+	return nil
+}
+
+// GenerateAPIKey returns a new random key along with its prefix and the hash
+// of its secret. Keys have the form bu_<prefix>_<secret>.
+func GenerateAPIKey() (key, prefix, secretHash string, err error) {
+	prefixBytes := make([]byte, 6)
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return "", "", "", err
+	}
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", "", "", err
+	}
+
+	prefix = hex.EncodeToString(prefixBytes)
+	secret := base64.RawURLEncoding.EncodeToString(secretBytes)
+	return apiKeyPrefix + "_" + prefix + "_" + secret, prefix, hashSecret(secret), nil
+}
+
+// parseAPIKey splits a key into its prefix and secret
+func parseAPIKey(key string) (prefix, secret string, ok bool) {
+	parts := strings.SplitN(key, "_", 3)
+	if len(parts) != 3 || parts[0] != apiKeyPrefix || parts[1] == "" || parts[2] == "" {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+// hashSecret returns the hex SHA-256 hash of a secret such as an API key or
+// session ID. Secrets are long and random, so a fast hash is sufficient.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// List retrieves all API keys, including revoked ones, ordered by name
+func (r *PostgresAPIKeyRepository) List() ([]APIKey, error) {
+	rows, err := r.DB.Query(`SELECT ` + apiKeyColumns + ` FROM api_keys ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := rows.Close(); closeErr != nil {
+			slog.ErrorContext(r.audit.logContext(), "Error closing rows", "error", closeErr)
+		}
+	}()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		var k APIKey
+		if err := scanAPIKey(rows, &k); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+
+	return keys, rows.Err()
+}
+
+// GetByID retrieves an API key by ID, or nil if there is none
+func (r *PostgresAPIKeyRepository) GetByID(id int) (*APIKey, error) {
+	var k APIKey
+	err := scanAPIKey(r.DB.QueryRow(`SELECT `+apiKeyColumns+` FROM api_keys WHERE id = $1`, id), &k)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &k, nil
+}
+
+// Create issues a new API key with the name, scopes, expiry and creator of
+// key, and returns the key. A name that is already taken is a unique
+// violation.
+func (r *PostgresAPIKeyRepository) Create(key *APIKey) (string, error) {
+	plaintext, prefix, secretHash, err := GenerateAPIKey()
+	if err != nil {
+		return "", err
+	}
+
+	err = scanAPIKey(r.DB.QueryRow(`
+		INSERT INTO api_keys (name, prefix, secret_hash, scopes, expires_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+apiKeyColumns,
+		key.Name, prefix, secretHash, pq.Array(key.Scopes), key.ExpiresAt, key.CreatedBy), key)
+	if err != nil {
+		return "", err
+	}
+	return plaintext, nil
+}
+
+// Rotate replaces the secret of an API key that has not been revoked and
+// returns the new key. The old key stops working at once. It returns nil if
+// there is no such key.
+func (r *PostgresAPIKeyRepository) Rotate(id int) (*APIKey, string, error) {
+	plaintext, prefix, secretHash, err := GenerateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	var k APIKey
+	err = scanAPIKey(r.DB.QueryRow(`
+		UPDATE api_keys
+		SET prefix = $1, secret_hash = $2, last_used_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3 AND revoked_at IS NULL
+		RETURNING `+apiKeyColumns,
+		prefix, secretHash, id), &k)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, "", nil
+		}
+		return nil, "", err
+	}
+	return &k, plaintext, nil
+}
+
+// Revoke permanently disables an API key. Revoking a revoked key keeps its
+// original revocation time. It returns nil if there is no such key.
+func (r *PostgresAPIKeyRepository) Revoke(id int) (*APIKey, error) {
+	var k APIKey
+	err := scanAPIKey(r.DB.QueryRow(`
+		UPDATE api_keys
+		SET revoked_at = COALESCE(revoked_at, CURRENT_TIMESTAMP), updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING `+apiKeyColumns, id), &k)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &k, nil
+}
+
+// Verify returns the API key matching key and records that it was used. It
+// returns ErrInvalidAPIKey, ErrAPIKeyRevoked or ErrAPIKeyExpired if the key
+// cannot be used.
+func (r *PostgresAPIKeyRepository) Verify(key string) (*APIKey, error) {
+	prefix, secret, ok := parseAPIKey(key)
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+
+	var (
+		k          APIKey
+		secretHash string
+		expired    bool
+	)
+	err := r.DB.QueryRow(`
+		SELECT `+apiKeyColumns+`, secret_hash, expires_at IS NOT NULL AND expires_at <= CURRENT_TIMESTAMP
+		FROM api_keys WHERE prefix = $1`, prefix).Scan(
+		&k.ID, &k.Name, &k.Prefix, (*pq.StringArray)(&k.Scopes), &k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt, &k.CreatedBy, &k.CreatedAt, &k.UpdatedAt,
+		&secretHash, &expired)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(secretHash)) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	if k.RevokedAt != nil {
+		return nil, ErrAPIKeyRevoked
+	}
+	if expired {
+		return nil, ErrAPIKeyExpired
+	}
+
+	// Writing every use would put a write on every request, so the last use
+	// is only recorded once per interval
+	if _, err := r.DB.Exec(`
+		UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < CURRENT_TIMESTAMP - $2 * INTERVAL '1 second')`,
+		k.ID, int(apiKeyTouchInterval.Seconds())); err != nil {
+		return nil, err
+	}
+
+	return &k, nil
+}
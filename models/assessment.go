@@ -0,0 +1,494 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// Mark statuses. A submitted mark may be moderated before it is published;
+// once published it can no longer change.
+const (
+	MarkSubmitted = "submitted"
+	MarkModerated = "moderated"
+	MarkPublished = "published"
+)
+
+// Assessment is a weighted component of a module such as a coursework or exam
+type Assessment struct {
+	ID         int       `json:"id"`
+	ModuleCode string    `json:"module_code"`
+	Name       string    `json:"name" binding:"required,max=200"`
+	Kind       string    `json:"kind" binding:"required,oneof=coursework exam"`
+	Weighting  int       `json:"weighting" binding:"required,min=1,max=100"`
+	MaxMark    int       `json:"max_mark" binding:"required,min=1"`
+	DueDate    string    `json:"due_date" binding:"required,datetime=2006-01-02"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Mark is a student's mark for one assessment of an enrolment
+type Mark struct {
+	ID             int        `json:"id"`
+	AssessmentID   int        `json:"assessment_id"`
+	EnrolmentID    int        `json:"enrolment_id"`
+	Mark           float64    `json:"mark"`
+	OriginalMark   *float64   `json:"original_mark"`
+	Status         string     `json:"status"`
+	ModerationNote string     `json:"moderation_note"`
+	SubmittedAt    time.Time  `json:"submitted_at"`
+	ModeratedAt    *time.Time `json:"moderated_at"`
+	PublishedAt    *time.Time `json:"published_at"`
+}
+
+// ComponentResult is the published outcome of one assessment in a module
+type ComponentResult struct {
+	AssessmentID int      `json:"assessment_id"`
+	Name         string   `json:"name"`
+	Kind         string   `json:"kind"`
+	Weighting    int      `json:"weighting"`
+	MaxMark      int      `json:"max_mark"`
+	DueDate      string   `json:"due_date"`
+	Mark         *float64 `json:"mark"`
+	Percentage   *float64 `json:"percentage"`
+	Weighted     *float64 `json:"weighted"`
+}
+
+// ModuleResult is a student's weighted result for one module enrolment
+type ModuleResult struct {
+	EnrolmentID  int               `json:"enrolment_id"`
+	ModuleCode   string            `json:"module_code"`
+	ModuleTitle  string            `json:"module_title"`
+	Level        int               `json:"level"`
+	Credits      int               `json:"credits"`
+	AcademicYear string            `json:"academic_year"`
+	Components   []ComponentResult `json:"components"`
+	// ProvisionalTotal is the weighted average of the published components
+	ProvisionalTotal *float64 `json:"provisional_total"`
+	// Total is only set once every component is published and the weightings
+	// add up to 100
+	Total    *float64 `json:"total"`
+	Complete bool     `json:"complete"`
+}
+
+var (
+	// ErrAssessmentNotFound is returned when a mark names a missing assessment
+	ErrAssessmentNotFound = errors.New("assessment not found")
+	// ErrWeightingExceeded is returned when a module's assessment weightings
+	// would add up to more than 100
+	ErrWeightingExceeded = errors.New("assessment weightings exceed 100")
+	// ErrNotEnrolled is returned when a mark is submitted for a student who is
+	// not enrolled on the assessment's module
+	ErrNotEnrolled = errors.New("student is not enrolled on module")
+	// ErrMarkOutOfRange is returned for a mark outside 0 to the maximum mark
+	ErrMarkOutOfRange = errors.New("mark is out of range")
+	// ErrMarkPublished is returned when a published mark would be changed
+	ErrMarkPublished = errors.New("mark has been published")
+)
+
+// roundMark rounds v to two decimal places
+func roundMark(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// Calculate fills in each component's percentage and weighted contribution
+// and the module totals from the components' published marks
+func (m *ModuleResult) Calculate() {
+	var weighted, weightingMarked, weightingTotal float64
+	published := 0
+	for i := range m.Components {
+		c := &m.Components[i]
+		weightingTotal += float64(c.Weighting)
+		c.Percentage, c.Weighted = nil, nil
+		if c.Mark == nil {
+			continue
+		}
+		pct := *c.Mark / float64(c.MaxMark) * 100
+		w := pct * float64(c.Weighting) / 100
+		c.Percentage = floatPtr(roundMark(pct))
+		c.Weighted = floatPtr(roundMark(w))
+		weighted += w
+		weightingMarked += float64(c.Weighting)
+		published++
+	}
+
+	m.ProvisionalTotal, m.Total = nil, nil
+	if published > 0 {
+		m.ProvisionalTotal = floatPtr(roundMark(weighted / weightingMarked * 100))
+	}
+	m.Complete = published > 0 && published == len(m.Components) && weightingTotal == 100
+	if m.Complete {
+		m.Total = floatPtr(roundMark(weighted))
+	}
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+// AssessmentRepository defines the interface for assessment and mark data operations
+type AssessmentRepository interface {
+	ListAssessments(moduleCode string) ([]Assessment, error)
+	GetAssessment(id int) (*Assessment, error)
+	CreateAssessment(assessment *Assessment) error
+	SubmitMark(assessmentID, studentID int, academicYear string, mark float64) (*Mark, error)
+	ModerateMark(id int, mark *float64, note string) (*Mark, error)
+	PublishMark(id int) (*Mark, error)
+	PublishAssessment(assessmentID int) (int, error)
+	StudentResults(studentID int, academicYear string) ([]ModuleResult, error)
+	// WithAudit returns a repository that logs errors with a's request ID
+	WithAudit(a Audit) AssessmentRepository
+}
+
+// PostgresAssessmentRepository implements AssessmentRepository for PostgreSQL
+type PostgresAssessmentRepository struct {
+	DB    *sql.DB
+	audit Audit
+}
+
+// NewPostgresAssessmentRepository creates a new PostgresAssessmentRepository
+func NewPostgresAssessmentRepository(db *sql.DB) *PostgresAssessmentRepository {
+	return &PostgresAssessmentRepository{DB: db}
+}
+
+// WithAudit returns a copy of the repository whose logged errors name the
+// request a was made in
+func (r *PostgresAssessmentRepository) WithAudit(a Audit) AssessmentRepository {
+	audited := *r
+	audited.audit = a
+	return &audited
+}
+
+// assessmentColumns is the column list selected for every assessment query,
+// in the order expected by scanAssessment. It requires modules to be joined
+// as m.
+const assessmentColumns = `a.id, m.code, a.name, a.kind, a.weighting, a.max_mark, to_char(a.due_date, 'YYYY-MM-DD'), a.created_at, a.updated_at`
+
+// scanAssessment reads a row selected with assessmentColumns into an Assessment
+func scanAssessment(row rowScanner, a *Assessment) error {
+	return row.Scan(&a.ID, &a.ModuleCode, &a.Name, &a.Kind, &a.Weighting, &a.MaxMark, &a.DueDate, &a.CreatedAt, &a.UpdatedAt)
+}
+
+// markColumns is the column list selected for every mark query, in the
+// order expected by scanMark
+const markColumns = `id, assessment_id, enrolment_id, mark, original_mark, status, moderation_note, submitted_at, moderated_at, published_at`
+
+// scanMark reads a row selected with markColumns into a Mark
+func scanMark(row rowScanner, m *Mark) error {
+	return row.Scan(&m.ID, &m.AssessmentID, &m.EnrolmentID, &m.Mark, &m.OriginalMark, &m.Status, &m.ModerationNote, &m.SubmittedAt, &m.ModeratedAt, &m.PublishedAt)
+}
+
+// ListAssessments retrieves a module's assessments ordered by due date
+func (r *PostgresAssessmentRepository) ListAssessments(moduleCode string) ([]Assessment, error) {
+	var exists bool
+	if err := r.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM modules WHERE code = $1)`, moduleCode).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrModuleNotFound
+	}
+
+	rows, err := r.DB.Query(`
+		SELECT `+assessmentColumns+`
+		FROM assessments a
+		JOIN modules m ON m.id = a.module_id
+		WHERE m.code = $1
+		ORDER BY a.due_date, a.id`, moduleCode)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := rows.Close(); closeErr != nil {
+			slog.ErrorContext(r.audit.logContext(), "Error closing rows", "error", closeErr)
+		}
+	}()
+
+	assessments := []Assessment{}
+	for rows.Next() {
+		var a Assessment
+		if err := scanAssessment(rows, &a); err != nil {
+			return nil, err
+		}
+		assessments = append(assessments, a)
+	}
+
+	return assessments, rows.Err()
+}
+
+// GetAssessment retrieves an assessment by ID, or nil if there is none
+func (r *PostgresAssessmentRepository) GetAssessment(id int) (*Assessment, error) {
+	var a Assessment
+	err := scanAssessment(r.DB.QueryRow(`
+		SELECT `+assessmentColumns+`
+		FROM assessments a
+		JOIN modules m ON m.id = a.module_id
+		WHERE a.id = $1`, id), &a)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+// CreateAssessment adds an assessment to the module named by
+// assessment.ModuleCode. The module is locked while its weightings are
+// totalled so that they cannot exceed 100.
+func (r *PostgresAssessmentRepository) CreateAssessment(assessment *Assessment) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Rolling back after a successful commit is a no-op
+		_ = tx.Rollback()
+	}()
+
+	var moduleID int
+	err = tx.QueryRow(`SELECT id FROM modules WHERE code = $1 FOR UPDATE`, assessment.ModuleCode).Scan(&moduleID)
+	if err == sql.ErrNoRows {
+		return ErrModuleNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	var weighting int
+	err = tx.QueryRow(`SELECT COALESCE(SUM(weighting), 0) FROM assessments WHERE module_id = $1`, moduleID).Scan(&weighting)
+	if err != nil {
+		return err
+	}
+	if weighting+assessment.Weighting > 100 {
+		return ErrWeightingExceeded
+	}
+
+	err = scanAssessment(tx.QueryRow(`
+		WITH a AS (
+			INSERT INTO assessments (module_id, name, kind, weighting, max_mark, due_date)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING *
+		)
+		SELECT `+assessmentColumns+`
+		FROM a
+		JOIN modules m ON m.id = a.module_id`,
+		moduleID, assessment.Name, assessment.Kind, assessment.Weighting, assessment.MaxMark, assessment.DueDate), assessment)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SubmitMark records a student's mark for an assessment, replacing any mark
+// that has not yet been published
+func (r *PostgresAssessmentRepository) SubmitMark(assessmentID, studentID int, academicYear string, mark float64) (*Mark, error) {
+	var moduleID, maxMark int
+	err := r.DB.QueryRow(`SELECT module_id, max_mark FROM assessments WHERE id = $1`, assessmentID).Scan(&moduleID, &maxMark)
+	if err == sql.ErrNoRows {
+		return nil, ErrAssessmentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if mark < 0 || mark > float64(maxMark) {
+		return nil, ErrMarkOutOfRange
+	}
+
+	var enrolmentID int
+	err = r.DB.QueryRow(`
+		SELECT id FROM enrolments
+		WHERE student_id = $1 AND module_id = $2 AND academic_year = $3
+			AND student_id IN (SELECT id FROM students WHERE deleted_at IS NULL)`,
+		studentID, moduleID, academicYear).Scan(&enrolmentID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotEnrolled
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Resubmitting starts moderation afresh
+	var m Mark
+	err = scanMark(r.DB.QueryRow(`
+		INSERT INTO marks (assessment_id, enrolment_id, mark)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (assessment_id, enrolment_id) DO UPDATE
+		SET mark = EXCLUDED.mark, original_mark = NULL, status = 'submitted', moderation_note = '',
+			submitted_at = CURRENT_TIMESTAMP, moderated_at = NULL
+		WHERE marks.status <> 'published'
+		RETURNING `+markColumns,
+		assessmentID, enrolmentID, mark), &m)
+	if err == sql.ErrNoRows {
+		return nil, ErrMarkPublished
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// ModerateMark records the outcome of moderating a mark. A nil mark confirms
+// the submitted mark; otherwise the mark is replaced and the original kept.
+// It returns nil if the mark does not exist.
+func (r *PostgresAssessmentRepository) ModerateMark(id int, mark *float64, note string) (*Mark, error) {
+	var status string
+	var maxMark int
+	err := r.DB.QueryRow(`
+		SELECT mk.status, a.max_mark
+		FROM marks mk
+		JOIN assessments a ON a.id = mk.assessment_id
+		WHERE mk.id = $1`, id).Scan(&status, &maxMark)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if status == MarkPublished {
+		return nil, ErrMarkPublished
+	}
+	if mark != nil && (*mark < 0 || *mark > float64(maxMark)) {
+		return nil, ErrMarkOutOfRange
+	}
+
+	var m Mark
+	err = scanMark(r.DB.QueryRow(`
+		UPDATE marks
+		SET original_mark = CASE WHEN $2::NUMERIC IS NULL OR $2 = mark THEN original_mark ELSE COALESCE(original_mark, mark) END,
+			mark = COALESCE($2, mark),
+			status = 'moderated', moderation_note = $3, moderated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status <> 'published'
+		RETURNING `+markColumns,
+		id, mark, note), &m)
+	if err == sql.ErrNoRows {
+		return nil, ErrMarkPublished
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// PublishMark releases a mark to the student. Publishing an already
+// published mark has no effect. It returns nil if the mark does not exist.
+func (r *PostgresAssessmentRepository) PublishMark(id int) (*Mark, error) {
+	var m Mark
+	err := scanMark(r.DB.QueryRow(`
+		UPDATE marks
+		SET status = 'published', published_at = COALESCE(published_at, CURRENT_TIMESTAMP)
+		WHERE id = $1
+		RETURNING `+markColumns, id), &m)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// PublishAssessment publishes every unpublished mark for an assessment and
+// returns how many were published
+func (r *PostgresAssessmentRepository) PublishAssessment(assessmentID int) (int, error) {
+	var exists bool
+	if err := r.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM assessments WHERE id = $1)`, assessmentID).Scan(&exists); err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, ErrAssessmentNotFound
+	}
+
+	result, err := r.DB.Exec(`
+		UPDATE marks
+		SET status = 'published', published_at = CURRENT_TIMESTAMP
+		WHERE assessment_id = $1 AND status <> 'published'`, assessmentID)
+	if err != nil {
+		return 0, err
+	}
+
+	published, err := result.RowsAffected()
+	return int(published), err
+}
+
+// StudentResults retrieves a student's module results with their published
+// marks, ordered by academic year and module code. An empty academicYear
+// returns every year.
+func (r *PostgresAssessmentRepository) StudentResults(studentID int, academicYear string) ([]ModuleResult, error) {
+	var exists bool
+	if err := r.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM students WHERE id = $1 AND deleted_at IS NULL)`, studentID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrStudentNotFound
+	}
+
+	rows, err := r.DB.Query(`
+		SELECT e.id, m.code, m.title, m.level, m.credits, e.academic_year,
+			a.id, a.name, a.kind, a.weighting, a.max_mark, to_char(a.due_date, 'YYYY-MM-DD'), mk.mark
+		FROM enrolments e
+		JOIN modules m ON m.id = e.module_id
+		LEFT JOIN assessments a ON a.module_id = m.id
+		LEFT JOIN marks mk ON mk.assessment_id = a.id AND mk.enrolment_id = e.id AND mk.status = 'published'
+		WHERE e.student_id = $1 AND ($2 = '' OR e.academic_year = $2)
+		ORDER BY e.academic_year, m.code, a.due_date, a.id`,
+		studentID, academicYear)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := rows.Close(); closeErr != nil {
+			slog.ErrorContext(r.audit.logContext(), "Error closing rows", "error", closeErr)
+		}
+	}()
+
+	results := []ModuleResult{}
+	for rows.Next() {
+		var (
+			res          ModuleResult
+			assessmentID sql.NullInt64
+			name, kind   sql.NullString
+			dueDate      sql.NullString
+			weighting    sql.NullInt64
+			maxMark      sql.NullInt64
+			mark         *float64
+		)
+		if err := rows.Scan(&res.EnrolmentID, &res.ModuleCode, &res.ModuleTitle, &res.Level, &res.Credits, &res.AcademicYear,
+			&assessmentID, &name, &kind, &weighting, &maxMark, &dueDate, &mark); err != nil {
+			return nil, err
+		}
+
+		if n := len(results); n == 0 || results[n-1].EnrolmentID != res.EnrolmentID {
+			res.Components = []ComponentResult{}
+			results = append(results, res)
+		}
+		if !assessmentID.Valid {
+			continue
+		}
+		last := &results[len(results)-1]
+		last.Components = append(last.Components, ComponentResult{
+			AssessmentID: int(assessmentID.Int64),
+			Name:         name.String,
+			Kind:         kind.String,
+			Weighting:    int(weighting.Int64),
+			MaxMark:      int(maxMark.Int64),
+			DueDate:      dueDate.String,
+			Mark:         mark,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range results {
+		results[i].Calculate()
+	}
+	return results, nil
+}
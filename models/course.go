@@ -0,0 +1,137 @@
+package models
+
+import (
+	"database/sql"
+	"log/slog"
+	"time"
+)
+
+// Course represents a course of study that students are registered on
+type Course struct {
+	ID            int       `json:"id"`
+	Code          string    `json:"code" binding:"required,max=20"`
+	Title         string    `json:"title" binding:"required,max=200"`
+	Department    string    `json:"department" binding:"required,max=200"`
+	Level         string    `json:"level" binding:"required,oneof=foundation undergraduate postgraduate"`
+	DurationYears int       `json:"duration_years" binding:"required,min=1,max=7"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// CourseRepository defines the interface for course data operations
+type CourseRepository interface {
+	List() ([]Course, error)
+	GetByCode(code string) (*Course, error)
+	Create(course *Course) error
+	Update(course *Course) error
+	Delete(id int) error
+	// WithAudit returns a repository that logs errors with a's request ID
+	WithAudit(a Audit) CourseRepository
+}
+
+// PostgresCourseRepository implements CourseRepository for PostgreSQL
+type PostgresCourseRepository struct {
+	DB    *sql.DB
+	audit Audit
+}
+
+// NewPostgresCourseRepository creates a new PostgresCourseRepository
+func NewPostgresCourseRepository(db *sql.DB) *PostgresCourseRepository {
+	return &PostgresCourseRepository{DB: db}
+}
+
+// WithAudit returns a copy of the repository whose logged errors name the
+// request a was made in
+func (r *PostgresCourseRepository) WithAudit(a Audit) CourseRepository {
+	audited := *r
+	audited.audit = a
+	return &audited
+}
+
+// courseColumns is the column list selected for every course query, in the
+// order expected by scanCourse
+const courseColumns = `id, code, title, department, level, duration_years, created_at, updated_at`
+
+// scanCourse reads a row selected with courseColumns into a Course
+func scanCourse(row rowScanner, c *Course) error {
+	return row.Scan(&c.ID, &c.Code, &c.Title, &c.Department, &c.Level, &c.DurationYears, &c.CreatedAt, &c.UpdatedAt)
+}
+
+// List retrieves all courses ordered by code
+func (r *PostgresCourseRepository) List() ([]Course, error) {
+	rows, err := r.DB.Query(`SELECT ` + courseColumns + ` FROM courses ORDER BY code`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := rows.Close(); closeErr != nil {
+			slog.ErrorContext(r.audit.logContext(), "Error closing rows", "error", closeErr)
+		}
+	}()
+
+	courses := []Course{}
+	for rows.Next() {
+		var c Course
+		if err := scanCourse(rows, &c); err != nil {
+			return nil, err
+		}
+		courses = append(courses, c)
+	}
+
+	return courses, rows.Err()
+}
+
+// GetByCode retrieves a course by its code, or nil if there is none
+func (r *PostgresCourseRepository) GetByCode(code string) (*Course, error) {
+	var c Course
+	err := scanCourse(r.DB.QueryRow(`SELECT `+courseColumns+` FROM courses WHERE code = $1`, code), &c)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// Create adds a new course to the database
+func (r *PostgresCourseRepository) Create(course *Course) error {
+	return scanCourse(r.DB.QueryRow(`
+		INSERT INTO courses (code, title, department, level, duration_years)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+courseColumns,
+		course.Code, course.Title, course.Department, course.Level, course.DurationYears), course)
+}
+
+// Update updates an existing course. Changing the code is carried through to
+// the students registered on the course.
+func (r *PostgresCourseRepository) Update(course *Course) error {
+	return scanCourse(r.DB.QueryRow(`
+		UPDATE courses
+		SET code = $1, title = $2, department = $3, level = $4, duration_years = $5, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $6
+		RETURNING `+courseColumns,
+		course.Code, course.Title, course.Department, course.Level, course.DurationYears, course.ID), course)
+}
+
+// Delete removes a course from the database. It fails with a foreign key
+// violation while students are registered on the course.
+func (r *PostgresCourseRepository) Delete(id int) error {
+	result, err := r.DB.Exec("DELETE FROM courses WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
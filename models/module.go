@@ -0,0 +1,382 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Module represents a taught unit that students enrol on for an academic
+// year. Level is the module's FHEQ level, e.g. 4 for the first year of an
+// honours degree.
+type Module struct {
+	ID        int       `json:"id"`
+	Code      string    `json:"code" binding:"required,max=20"`
+	Title     string    `json:"title" binding:"required,max=200"`
+	Level     int       `json:"level" binding:"required,min=4,max=7"`
+	Credits   int       `json:"credits" binding:"required,min=1"`
+	Capacity  int       `json:"capacity" binding:"required,min=1"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// FHEQLevel returns the FHEQ level taught in a year of study of an honours
+// degree: 4 in the first year up to 7 in the fourth year of an integrated
+// master's
+func FHEQLevel(yearOfStudy int) int {
+	return yearOfStudy + 3
+}
+
+// Enrolment records a student taking a module in an academic year
+type Enrolment struct {
+	ID           int       `json:"id"`
+	StudentID    int       `json:"student_id"`
+	ModuleCode   string    `json:"module_code"`
+	ModuleTitle  string    `json:"module_title"`
+	Credits      int       `json:"credits"`
+	AcademicYear string    `json:"academic_year"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+var (
+	// ErrStudentNotFound is returned when an enrolment names a missing student
+	ErrStudentNotFound = errors.New("student not found")
+	// ErrModuleNotFound is returned when an enrolment names a missing module
+	ErrModuleNotFound = errors.New("module not found")
+	// ErrModuleFull is returned when a module has no places left for the year
+	ErrModuleFull = errors.New("module is full")
+	// ErrLevelMismatch is returned when a student's year of study is not the
+	// one the module's level is taught in
+	ErrLevelMismatch = errors.New("year of study does not match module level")
+	// ErrAlreadyEnrolled is returned when a student is already on the module
+	// for the year
+	ErrAlreadyEnrolled = errors.New("student is already enrolled on module")
+	// ErrEnrolmentHasMarks is returned when unenrolling a student who has marks
+	// on the module, which would otherwise be lost
+	ErrEnrolmentHasMarks = errors.New("enrolment has marks")
+)
+
+// academicYearPattern matches academic years written as 2024/25
+var academicYearPattern = regexp.MustCompile(`^(\d{4})/(\d{2})$`)
+
+// CurrentAcademicYear returns the academic year containing t. Academic years
+// start on 1 September.
+func CurrentAcademicYear(t time.Time) string {
+	start := t.Year()
+	if t.Month() < time.September {
+		start--
+	}
+	return fmt.Sprintf("%d/%02d", start, (start+1)%100)
+}
+
+// IsValidAcademicYear reports whether s is an academic year such as 2024/25
+func IsValidAcademicYear(s string) bool {
+	m := academicYearPattern.FindStringSubmatch(s)
+	if m == nil {
+		return false
+	}
+	start, _ := strconv.Atoi(m[1])
+	end, _ := strconv.Atoi(m[2])
+	return (start+1)%100 == end
+}
+
+// ModuleRepository defines the interface for module and enrolment data operations
+type ModuleRepository interface {
+	List() ([]Module, error)
+	GetByCode(code string) (*Module, error)
+	Create(module *Module) error
+	Update(module *Module) error
+	Delete(id int) error
+	Enrol(studentID int, moduleCode, academicYear string) (*Enrolment, error)
+	Unenrol(studentID int, moduleCode, academicYear string) error
+	ListEnrolments(studentID int, academicYear string) ([]Enrolment, error)
+	ListStudents(moduleCode, academicYear string) ([]Student, error)
+	// WithAudit returns a repository that logs errors with a's request ID
+	WithAudit(a Audit) ModuleRepository
+}
+
+// PostgresModuleRepository implements ModuleRepository for PostgreSQL
+type PostgresModuleRepository struct {
+	DB    *sql.DB
+	audit Audit
+}
+
+// NewPostgresModuleRepository creates a new PostgresModuleRepository
+func NewPostgresModuleRepository(db *sql.DB) *PostgresModuleRepository {
+	return &PostgresModuleRepository{DB: db}
+}
+
+// WithAudit returns a copy of the repository whose logged errors name the
+// request a was made in
+func (r *PostgresModuleRepository) WithAudit(a Audit) ModuleRepository {
+	audited := *r
+	audited.audit = a
+	return &audited
+}
+
+// moduleColumns is the column list selected for every module query, in the
+// order expected by scanModule
+const moduleColumns = `id, code, title, level, credits, capacity, created_at, updated_at`
+
+// scanModule reads a row selected with moduleColumns into a Module
+func scanModule(row rowScanner, m *Module) error {
+	return row.Scan(&m.ID, &m.Code, &m.Title, &m.Level, &m.Credits, &m.Capacity, &m.CreatedAt, &m.UpdatedAt)
+}
+
+// List retrieves all modules ordered by code
+func (r *PostgresModuleRepository) List() ([]Module, error) {
+	rows, err := r.DB.Query(`SELECT ` + moduleColumns + ` FROM modules ORDER BY code`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := rows.Close(); closeErr != nil {
+			slog.ErrorContext(r.audit.logContext(), "Error closing rows", "error", closeErr)
+		}
+	}()
+
+	modules := []Module{}
+	for rows.Next() {
+		var m Module
+		if err := scanModule(rows, &m); err != nil {
+			return nil, err
+		}
+		modules = append(modules, m)
+	}
+
+	return modules, rows.Err()
+}
+
+// GetByCode retrieves a module by its code, or nil if there is none
+func (r *PostgresModuleRepository) GetByCode(code string) (*Module, error) {
+	var m Module
+	err := scanModule(r.DB.QueryRow(`SELECT `+moduleColumns+` FROM modules WHERE code = $1`, code), &m)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// Create adds a new module to the database
+func (r *PostgresModuleRepository) Create(module *Module) error {
+	return scanModule(r.DB.QueryRow(`
+		INSERT INTO modules (code, title, level, credits, capacity)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+moduleColumns,
+		module.Code, module.Title, module.Level, module.Credits, module.Capacity), module)
+}
+
+// Update updates an existing module. Lowering the capacity does not remove
+// students who are already enrolled.
+func (r *PostgresModuleRepository) Update(module *Module) error {
+	return scanModule(r.DB.QueryRow(`
+		UPDATE modules
+		SET code = $1, title = $2, level = $3, credits = $4, capacity = $5, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $6
+		RETURNING `+moduleColumns,
+		module.Code, module.Title, module.Level, module.Credits, module.Capacity, module.ID), module)
+}
+
+// Delete removes a module from the database. It fails with a foreign key
+// violation while the module has enrolments.
+func (r *PostgresModuleRepository) Delete(id int) error {
+	result, err := r.DB.Exec("DELETE FROM modules WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// Enrol enrols a student on a module for an academic year. The module row is
+// locked while its places are counted so that concurrent enrolments cannot
+// exceed its capacity.
+func (r *PostgresModuleRepository) Enrol(studentID int, moduleCode, academicYear string) (*Enrolment, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		// Rolling back after a successful commit is a no-op
+		_ = tx.Rollback()
+	}()
+
+	var yearOfStudy int
+	err = tx.QueryRow(`SELECT year_of_study FROM students WHERE id = $1 AND deleted_at IS NULL FOR SHARE`, studentID).Scan(&yearOfStudy)
+	if err == sql.ErrNoRows {
+		return nil, ErrStudentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var m Module
+	err = scanModule(tx.QueryRow(`SELECT `+moduleColumns+` FROM modules WHERE code = $1 FOR UPDATE`, moduleCode), &m)
+	if err == sql.ErrNoRows {
+		return nil, ErrModuleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if FHEQLevel(yearOfStudy) != m.Level {
+		return nil, ErrLevelMismatch
+	}
+
+	var enrolled int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM enrolments WHERE module_id = $1 AND academic_year = $2`, m.ID, academicYear).Scan(&enrolled)
+	if err != nil {
+		return nil, err
+	}
+	if enrolled >= m.Capacity {
+		return nil, ErrModuleFull
+	}
+
+	e := Enrolment{StudentID: studentID, ModuleCode: m.Code, ModuleTitle: m.Title, Credits: m.Credits, AcademicYear: academicYear}
+	err = tx.QueryRow(`
+		INSERT INTO enrolments (student_id, module_id, academic_year)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`,
+		studentID, m.ID, academicYear).Scan(&e.ID, &e.CreatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" { // unique_violation
+			return nil, ErrAlreadyEnrolled
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Unenrol removes a student from a module for an academic year. It fails
+// with ErrEnrolmentHasMarks once any mark has been submitted for the
+// enrolment.
+func (r *PostgresModuleRepository) Unenrol(studentID int, moduleCode, academicYear string) error {
+	result, err := r.DB.Exec(`
+		DELETE FROM enrolments
+		WHERE student_id = $1 AND academic_year = $3
+			AND module_id = (SELECT id FROM modules WHERE code = $2)`,
+		studentID, moduleCode, academicYear)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" { // foreign_key_violation
+			return ErrEnrolmentHasMarks
+		}
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// ListEnrolments retrieves a student's enrolments, ordered by academic year
+// and module code. An empty academicYear returns every year.
+func (r *PostgresModuleRepository) ListEnrolments(studentID int, academicYear string) ([]Enrolment, error) {
+	var exists bool
+	if err := r.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM students WHERE id = $1 AND deleted_at IS NULL)`, studentID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrStudentNotFound
+	}
+
+	rows, err := r.DB.Query(`
+		SELECT e.id, e.student_id, m.code, m.title, m.credits, e.academic_year, e.created_at
+		FROM enrolments e
+		JOIN modules m ON m.id = e.module_id
+		WHERE e.student_id = $1 AND ($2 = '' OR e.academic_year = $2)
+		ORDER BY e.academic_year, m.code`,
+		studentID, academicYear)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := rows.Close(); closeErr != nil {
+			slog.ErrorContext(r.audit.logContext(), "Error closing rows", "error", closeErr)
+		}
+	}()
+
+	enrolments := []Enrolment{}
+	for rows.Next() {
+		var e Enrolment
+		if err := rows.Scan(&e.ID, &e.StudentID, &e.ModuleCode, &e.ModuleTitle, &e.Credits, &e.AcademicYear, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		enrolments = append(enrolments, e)
+	}
+
+	return enrolments, rows.Err()
+}
+
+// ListStudents retrieves the students enrolled on a module in an academic
+// year, ordered by name
+func (r *PostgresModuleRepository) ListStudents(moduleCode, academicYear string) ([]Student, error) {
+	var exists bool
+	if err := r.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM modules WHERE code = $1)`, moduleCode).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrModuleNotFound
+	}
+
+	rows, err := r.DB.Query(`
+		SELECT `+studentColumns+`
+		FROM students
+		WHERE deleted_at IS NULL AND id IN (
+			SELECT e.student_id
+			FROM enrolments e
+			JOIN modules m ON m.id = e.module_id
+			WHERE m.code = $1 AND e.academic_year = $2
+		)
+		ORDER BY last_name, first_name, id`,
+		moduleCode, academicYear)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := rows.Close(); closeErr != nil {
+			slog.ErrorContext(r.audit.logContext(), "Error closing rows", "error", closeErr)
+		}
+	}()
+
+	students := []Student{}
+	for rows.Next() {
+		var s Student
+		if err := scanStudent(rows, &s); err != nil {
+			return nil, err
+		}
+		students = append(students, s)
+	}
+
+	return students, rows.Err()
+}
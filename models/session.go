@@ -0,0 +1,152 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Session is a signed-in browser session of the web interface. The session
+// ID is only held by the browser; the database stores a hash of it.
+type Session struct {
+	Subject string
+	Name    string
+	Email   string
+	Roles   []string
+	// IDToken is the raw ID token of the login, used as a hint on logout
+	IDToken string
+	// CSRFToken must accompany requests that change data
+	CSRFToken string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+// LoginAttempt holds what an OpenID Connect login needs to complete, between
+// redirecting to the identity provider and the callback
+type LoginAttempt struct {
+	State        string
+	CodeVerifier string
+	Nonce        string
+	// ReturnTo is the local path to go back to after signing in
+	ReturnTo string
+}
+
+// SessionRepository defines the interface for session data operations
+type SessionRepository interface {
+	CreateLogin(login *LoginAttempt, ttl time.Duration) error
+	TakeLogin(state string) (*LoginAttempt, error)
+	Create(session *Session, ttl time.Duration) (string, error)
+	Get(id string) (*Session, error)
+	Delete(id string) error
+}
+
+// PostgresSessionRepository implements SessionRepository for PostgreSQL
+type PostgresSessionRepository struct {
+	DB *sql.DB
+}
+
+// NewPostgresSessionRepository creates a new PostgresSessionRepository
+func NewPostgresSessionRepository(db *sql.DB) *PostgresSessionRepository {
+	return &PostgresSessionRepository{DB: db}
+}
+
+// RandomToken returns a URL-safe random string carrying n bytes of entropy
+func RandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CreateLogin stores a pending login until it is taken or ttl passes. Expired
+// logins and sessions are removed at the same time.
+func (r *PostgresSessionRepository) CreateLogin(login *LoginAttempt, ttl time.Duration) error {
+	if _, err := r.DB.Exec(`DELETE FROM oidc_logins WHERE expires_at < CURRENT_TIMESTAMP`); err != nil {
+		return err
+	}
+	if _, err := r.DB.Exec(`DELETE FROM sessions WHERE expires_at < CURRENT_TIMESTAMP`); err != nil {
+		return err
+	}
+
+	_, err := r.DB.Exec(`
+		INSERT INTO oidc_logins (state_hash, code_verifier, nonce, return_to, expires_at)
+		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP + $5 * INTERVAL '1 second')`,
+		hashSecret(login.State), login.CodeVerifier, login.Nonce, login.ReturnTo, int(ttl.Seconds()))
+	return err
+}
+
+// TakeLogin removes and returns the pending login with the given state, or
+// nil if there is none or it has expired. A login can only be taken once.
+func (r *PostgresSessionRepository) TakeLogin(state string) (*LoginAttempt, error) {
+	login := LoginAttempt{State: state}
+	var expired bool
+	err := r.DB.QueryRow(`
+		DELETE FROM oidc_logins WHERE state_hash = $1
+		RETURNING code_verifier, nonce, return_to, expires_at < CURRENT_TIMESTAMP`,
+		hashSecret(state)).Scan(&login.CodeVerifier, &login.Nonce, &login.ReturnTo, &expired)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if expired {
+		return nil, nil
+	}
+	return &login, nil
+}
+
+// Create starts a session that lasts for ttl and returns its ID. The
+// session's CSRF token, creation and expiry times are filled in.
+func (r *PostgresSessionRepository) Create(session *Session, ttl time.Duration) (string, error) {
+	id, err := RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	session.CSRFToken, err = RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	err = r.DB.QueryRow(`
+		INSERT INTO sessions (id_hash, subject, name, email, roles, id_token, csrf_token, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP + $8 * INTERVAL '1 second')
+		RETURNING created_at, expires_at`,
+		hashSecret(id), session.Subject, session.Name, session.Email, pq.Array(session.Roles),
+		session.IDToken, session.CSRFToken, int(ttl.Seconds())).Scan(&session.CreatedAt, &session.ExpiresAt)
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Get retrieves the session with the given ID, or nil if there is none or it
+// has expired
+func (r *PostgresSessionRepository) Get(id string) (*Session, error) {
+	var (
+		s     Session
+		roles pq.StringArray
+	)
+	err := r.DB.QueryRow(`
+		SELECT subject, name, email, roles, id_token, csrf_token, expires_at, created_at
+		FROM sessions WHERE id_hash = $1 AND expires_at > CURRENT_TIMESTAMP`,
+		hashSecret(id)).Scan(&s.Subject, &s.Name, &s.Email, &roles, &s.IDToken, &s.CSRFToken, &s.ExpiresAt, &s.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	s.Roles = []string(roles)
+	return &s, nil
+}
+
+// Delete ends a session. Ending a session that does not exist is not an error.
+func (r *PostgresSessionRepository) Delete(id string) error {
+	_, err := r.DB.Exec(`DELETE FROM sessions WHERE id_hash = $1`, hashSecret(id))
+	return err
+}
@@ -2,7 +2,11 @@ package models
 
 import (
 	"database/sql"
-	"log"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -15,22 +19,65 @@ type Student struct {
 	StudentID   string    `json:"student_id" binding:"required"`
 	Course      string    `json:"course" binding:"required"`
 	YearOfStudy int       `json:"year_of_study" binding:"required"`
+	Status      string    `json:"status"`
+	Version     int       `json:"version"`
+	ETag        string    `json:"etag"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+	// DeletedAt is set once the student has been deleted. Deleted students are
+	// kept until they are purged and can be restored until then.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// ErrVersionConflict is returned when a write expected a version of the
+// student that is no longer current
+var ErrVersionConflict = errors.New("student has been modified")
+
+// ErrStudentDeleted is returned when a write would reuse the student number of
+// a deleted student that has not been purged yet
+var ErrStudentDeleted = errors.New("student has been deleted")
+
+// StudentETag returns the strong entity tag for the given student version
+func StudentETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
 }
 
 // StudentRepository defines the interface for student data operations
 type StudentRepository interface {
-	GetAll() ([]Student, error)
+	List(opts StudentListOptions) (*StudentPage, error)
+	Stream(filter StudentFilter, fn func(*Student) error) error
+	Search(query string, limit int) ([]StudentSearchResult, error)
 	GetByID(id int) (*Student, error)
+	GetByStudentID(studentID string) (*Student, error)
+	GetByEmail(email string) (*Student, error)
 	Create(student *Student) error
+	Upsert(student *Student) (bool, error)
 	Update(student *Student) error
-	Delete(id int) error
+	UpdateFields(id, version int, fields map[string]interface{}) (*Student, error)
+	Delete(id, version int) error
+	Restore(id int) (*Student, error)
+	History(id int) ([]StudentHistoryEntry, error)
+	GetAsOf(id int, at time.Time) (*Student, error)
+	// WithAudit returns a repository that records a as the author of its changes
+	WithAudit(a Audit) StudentRepository
+	Begin() (StudentTx, error)
+}
+
+// StudentTx is a StudentRepository bound to a database transaction
+type StudentTx interface {
+	StudentRepository
+	// Try runs fn inside a savepoint. If fn returns an error only the changes
+	// made by fn are rolled back and the transaction remains usable.
+	Try(fn func() error) error
+	Commit() error
+	Rollback() error
 }
 
 // PostgresStudentRepository implements StudentRepository for PostgreSQL
 type PostgresStudentRepository struct {
-	DB *sql.DB
+	DB    *sql.DB
+	tx    *sql.Tx
+	audit Audit
 }
 
 // NewPostgresStudentRepository creates a new PostgresStudentRepository
@@ -38,44 +85,82 @@ func NewPostgresStudentRepository(db *sql.DB) *PostgresStudentRepository {
 	return &PostgresStudentRepository{DB: db}
 }
 
-// GetAll retrieves all students from the database
-func (r *PostgresStudentRepository) GetAll() ([]Student, error) {
-	rows, err := r.DB.Query(`
-		SELECT id, first_name, last_name, email, student_id, course, year_of_study, created_at, updated_at 
-		FROM students
-	`)
-	if err != nil {
-		return nil, err
+// queryer is the subset of *sql.DB and *sql.Tx used by the repository
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// conn returns the transaction the repository is bound to, if any, or the
+// connection pool
+func (r *PostgresStudentRepository) conn() queryer {
+	if r.tx != nil {
+		return r.tx
 	}
-	defer func() {
-		if closeErr := rows.Close(); closeErr != nil {
-			log.Printf("Error closing rows: %v", closeErr)
-		}
-	}()
+	return r.DB
+}
 
-	var students []Student
-	for rows.Next() {
-		var s Student
-		if err := rows.Scan(&s.ID, &s.FirstName, &s.LastName, &s.Email, &s.StudentID, &s.Course, &s.YearOfStudy, &s.CreatedAt, &s.UpdatedAt); err != nil {
-			return nil, err
-		}
-		students = append(students, s)
+// studentColumns is the column list selected for every student query, in the
+// order expected by scanStudent
+const studentColumns = `id, first_name, last_name, email, student_id, course, year_of_study, status, version, created_at, updated_at, deleted_at`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanStudent reads a row selected with studentColumns into a Student. Any
+// extra destinations receive the columns selected after studentColumns.
+func scanStudent(row rowScanner, s *Student, extra ...interface{}) error {
+	dest := []interface{}{&s.ID, &s.FirstName, &s.LastName, &s.Email, &s.StudentID, &s.Course, &s.YearOfStudy, &s.Status, &s.Version, &s.CreatedAt, &s.UpdatedAt, &s.DeletedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
 	}
+	s.ETag = StudentETag(s.Version)
+	return nil
+}
 
-	if err := rows.Err(); err != nil {
-		return nil, err
+// notDeleted is the condition that excludes deleted students
+const notDeleted = "deleted_at IS NULL"
+
+// versionCondition restricts a write to the expected version of a student.
+// A version of zero applies the write unconditionally.
+func versionCondition(q *queryArgs, version int) string {
+	if version == 0 {
+		return ""
 	}
+	return " AND version = " + q.add(version)
+}
 
-	return students, nil
+// missingRowError is the error for a write that matched no row
+func missingRowError(version int) error {
+	if version == 0 {
+		return sql.ErrNoRows
+	}
+	return ErrVersionConflict
 }
 
 // GetByID retrieves a student by ID
 func (r *PostgresStudentRepository) GetByID(id int) (*Student, error) {
+	return r.getOne("id = $1", id)
+}
+
+// GetByStudentID retrieves a student by university student number
+func (r *PostgresStudentRepository) GetByStudentID(studentID string) (*Student, error) {
+	return r.getOne("student_id = $1", studentID)
+}
+
+// GetByEmail retrieves a student by email address
+func (r *PostgresStudentRepository) GetByEmail(email string) (*Student, error) {
+	return r.getOne("email = $1", email)
+}
+
+// getOne retrieves the single student matching condition, or nil if none
+// does. Deleted students are never returned.
+func (r *PostgresStudentRepository) getOne(condition string, arg interface{}) (*Student, error) {
 	var s Student
-	err := r.DB.QueryRow(`
-		SELECT id, first_name, last_name, email, student_id, course, year_of_study, created_at, updated_at 
-		FROM students WHERE id = $1
-	`, id).Scan(&s.ID, &s.FirstName, &s.LastName, &s.Email, &s.StudentID, &s.Course, &s.YearOfStudy, &s.CreatedAt, &s.UpdatedAt)
+	err := scanStudent(r.conn().QueryRow(`SELECT `+studentColumns+` FROM students WHERE `+condition+` AND `+notDeleted, arg), &s)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -87,44 +172,195 @@ func (r *PostgresStudentRepository) GetByID(id int) (*Student, error) {
 	return &s, nil
 }
 
-// Create adds a new student to the database
+// Create adds a new student to the database. A student created without a
+// status is enrolled.
 func (r *PostgresStudentRepository) Create(student *Student) error {
-	return r.DB.QueryRow(`
-		INSERT INTO students (first_name, last_name, email, student_id, course, year_of_study)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id, created_at, updated_at
-	`, student.FirstName, student.LastName, student.Email, student.StudentID, student.Course, student.YearOfStudy).
-		Scan(&student.ID, &student.CreatedAt, &student.UpdatedAt)
+	return r.write(func(q queryer) error {
+		return scanStudent(q.QueryRow(`
+			INSERT INTO students (first_name, last_name, email, student_id, course, year_of_study, status)
+			VALUES ($1, $2, $3, $4, $5, $6, COALESCE(NULLIF($7, ''), 'enrolled'))
+			RETURNING `+studentColumns,
+			student.FirstName, student.LastName, student.Email, student.StudentID, student.Course, student.YearOfStudy, student.Status), student)
+	})
 }
 
-// Update updates an existing student
+// Upsert creates a student or, if one with the same student_id exists,
+// replaces its fields. It reports whether a new student was created. A
+// deleted student is not replaced; ErrStudentDeleted is returned instead.
+func (r *PostgresStudentRepository) Upsert(student *Student) (bool, error) {
+	var inserted bool
+	err := r.write(func(q queryer) error {
+		return scanStudent(q.QueryRow(`
+			INSERT INTO students (first_name, last_name, email, student_id, course, year_of_study)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (student_id) DO UPDATE
+			SET first_name = EXCLUDED.first_name, last_name = EXCLUDED.last_name, email = EXCLUDED.email,
+				course = EXCLUDED.course, year_of_study = EXCLUDED.year_of_study,
+				version = students.version + 1, updated_at = CURRENT_TIMESTAMP
+			WHERE students.deleted_at IS NULL
+			RETURNING `+studentColumns+`, (xmax = 0)`,
+			student.FirstName, student.LastName, student.Email, student.StudentID, student.Course, student.YearOfStudy), student, &inserted)
+	})
+	// A conflicting row that was not updated belongs to a deleted student
+	if err == sql.ErrNoRows {
+		return false, ErrStudentDeleted
+	}
+	return inserted, err
+}
+
+// Update updates an existing student. If student.Version is set the update
+// only succeeds while that version is still current. The status is left
+// unchanged; it only changes through a StudentStatusRepository transition.
 func (r *PostgresStudentRepository) Update(student *Student) error {
-	err := r.DB.QueryRow(`
-		UPDATE students
-		SET first_name = $1, last_name = $2, email = $3, student_id = $4, course = $5, year_of_study = $6, updated_at = CURRENT_TIMESTAMP
-		WHERE id = $7
-		RETURNING created_at, updated_at
-	`, student.FirstName, student.LastName, student.Email, student.StudentID, student.Course, student.YearOfStudy, student.ID).
-		Scan(&student.CreatedAt, &student.UpdatedAt)
+	args := &queryArgs{values: []interface{}{student.FirstName, student.LastName, student.Email, student.StudentID, student.Course, student.YearOfStudy}}
+	condition := "id = " + args.add(student.ID) + " AND " + notDeleted + versionCondition(args, student.Version)
+
+	err := r.write(func(q queryer) error {
+		return scanStudent(q.QueryRow(`
+			UPDATE students
+			SET first_name = $1, last_name = $2, email = $3, student_id = $4, course = $5, year_of_study = $6,
+				version = version + 1, updated_at = CURRENT_TIMESTAMP
+			WHERE `+condition+`
+			RETURNING `+studentColumns, args.values...), student)
+	})
 
+	if err == sql.ErrNoRows {
+		return missingRowError(student.Version)
+	}
 	return err
 }
 
-// Delete removes a student from the database
-func (r *PostgresStudentRepository) Delete(id int) error {
-	result, err := r.DB.Exec("DELETE FROM students WHERE id = $1", id)
+// studentEditableColumns are the columns that may be changed by UpdateFields
+var studentEditableColumns = map[string]bool{
+	"first_name":    true,
+	"last_name":     true,
+	"email":         true,
+	"student_id":    true,
+	"course":        true,
+	"year_of_study": true,
+}
+
+// UpdateFields updates only the given columns of a student and returns the
+// updated record, or nil if the student does not exist. A non-zero version
+// must match the current version of the student.
+func (r *PostgresStudentRepository) UpdateFields(id, version int, fields map[string]interface{}) (*Student, error) {
+	columns := make([]string, 0, len(fields))
+	for column := range fields {
+		if !studentEditableColumns[column] {
+			return nil, fmt.Errorf("column %q cannot be updated", column)
+		}
+		columns = append(columns, column)
+	}
+	// Keep the statement text stable for a given set of columns
+	sort.Strings(columns)
+
+	args := &queryArgs{}
+	assignments := make([]string, 0, len(columns)+2)
+	for _, column := range columns {
+		assignments = append(assignments, column+" = "+args.add(fields[column]))
+	}
+	assignments = append(assignments, "version = version + 1", "updated_at = CURRENT_TIMESTAMP")
+	condition := "id = " + args.add(id) + " AND " + notDeleted + versionCondition(args, version)
+
+	var s Student
+	err := r.write(func(q queryer) error {
+		return scanStudent(q.QueryRow(`UPDATE students SET `+strings.Join(assignments, ", ")+
+			` WHERE `+condition+` RETURNING `+studentColumns, args.values...), &s)
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			if version != 0 {
+				return nil, ErrVersionConflict
+			}
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// Delete marks a student as deleted. The record is kept, hidden from every
+// other query, until it is restored or purged. A non-zero version must match
+// the current version of the student.
+func (r *PostgresStudentRepository) Delete(id, version int) error {
+	args := &queryArgs{}
+	return r.write(func(q queryer) error {
+		result, err := q.Exec(`
+			UPDATE students
+			SET deleted_at = CURRENT_TIMESTAMP, version = version + 1, updated_at = CURRENT_TIMESTAMP
+			WHERE id = `+args.add(id)+` AND `+notDeleted+versionCondition(args, version), args.values...)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rowsAffected == 0 {
+			return missingRowError(version)
+		}
+
+		return nil
+	})
+}
+
+// Restore undoes the deletion of a student and returns the restored record,
+// or nil if there is no deleted student with the given ID
+func (r *PostgresStudentRepository) Restore(id int) (*Student, error) {
+	var s Student
+	err := r.write(func(q queryer) error {
+		return scanStudent(q.QueryRow(`
+			UPDATE students
+			SET deleted_at = NULL, version = version + 1, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND deleted_at IS NOT NULL
+			RETURNING `+studentColumns, id), &s)
+	})
 	if err != nil {
-		return err
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	return &s, nil
+}
+
+// PurgeDeleted permanently removes students that were deleted before the
+// given time, along with their enrolments, marks, history and other records. It
+// returns the number of students removed.
+func (r *PostgresStudentRepository) PurgeDeleted(before time.Time) (int64, error) {
+	tx, err := r.DB.Begin()
 	if err != nil {
-		return err
+		return 0, err
 	}
+	defer func() {
+		// Rolling back after a successful commit is a no-op
+		_ = tx.Rollback()
+	}()
 
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+	// Marks are kept when an enrolment is deleted, so they go first
+	_, err = tx.Exec(`
+		DELETE FROM marks
+		WHERE enrolment_id IN (
+			SELECT e.id
+			FROM enrolments e
+			JOIN students s ON s.id = e.student_id
+			WHERE s.deleted_at < $1
+		)`, before)
+	if err != nil {
+		return 0, err
 	}
 
-	return nil
+	result, err := tx.Exec(`DELETE FROM students WHERE deleted_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return purged, tx.Commit()
 }
@@ -0,0 +1,150 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/bournemouth-uni-it-api-go/logging"
+	"github.com/lib/pq"
+)
+
+// Audit identifies who made a change to a student and in which request. It
+// is stored with the change in the student history.
+type Audit struct {
+	Actor     string
+	RequestID string
+}
+
+// logContext returns a context carrying the request ID, so that errors logged
+// by a repository with these audit details name the request
+func (a Audit) logContext() context.Context {
+	return logging.WithRequestID(context.Background(), a.RequestID)
+}
+
+// apply makes the audit details visible to the student_history trigger for
+// the rest of the transaction q belongs to
+func (a Audit) apply(q queryer) error {
+	_, err := q.Exec(`SELECT set_config('app.actor', $1, true), set_config('app.request_id', $2, true)`, a.Actor, a.RequestID)
+	return err
+}
+
+// StudentHistoryEntry is one recorded change to a student. Before and After
+// hold every column of the student; Before is null when the student was
+// created.
+type StudentHistoryEntry struct {
+	ID            int64           `json:"id"`
+	StudentID     int             `json:"student_id"`
+	Action        string          `json:"action"`
+	ChangedFields []string        `json:"changed_fields"`
+	Before        json.RawMessage `json:"before"`
+	After         json.RawMessage `json:"after"`
+	Actor         string          `json:"actor"`
+	RequestID     string          `json:"request_id"`
+	ChangedAt     time.Time       `json:"changed_at"`
+}
+
+// WithAudit returns a copy of the repository that records a as the author of
+// every change it makes
+func (r *PostgresStudentRepository) WithAudit(a Audit) StudentRepository {
+	audited := *r
+	audited.audit = a
+	return &audited
+}
+
+// write runs fn in the repository's transaction, or in a new one if the
+// repository is not bound to a transaction, so that the change is recorded
+// with the repository's audit details
+func (r *PostgresStudentRepository) write(fn func(q queryer) error) error {
+	if r.tx != nil {
+		return fn(r.tx)
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Rolling back after a successful commit is a no-op
+		_ = tx.Rollback()
+	}()
+
+	if err := r.audit.apply(tx); err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// History retrieves every recorded change to a student, oldest first.
+// Deleted students keep their history until they are purged.
+func (r *PostgresStudentRepository) History(id int) ([]StudentHistoryEntry, error) {
+	var exists bool
+	if err := r.conn().QueryRow(`SELECT EXISTS (SELECT 1 FROM students WHERE id = $1)`, id).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrStudentNotFound
+	}
+
+	rows, err := r.conn().Query(`
+		SELECT id, student_id, action, changed_fields, before, after, actor, request_id, changed_at
+		FROM student_history
+		WHERE student_id = $1
+		ORDER BY changed_at, id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := rows.Close(); closeErr != nil {
+			slog.ErrorContext(r.audit.logContext(), "Error closing rows", "error", closeErr)
+		}
+	}()
+
+	entries := []StudentHistoryEntry{}
+	for rows.Next() {
+		var e StudentHistoryEntry
+		// Scanning into []byte copies the values out of the driver's buffer
+		var before, after []byte
+		if err := rows.Scan(&e.ID, &e.StudentID, &e.Action, pq.Array(&e.ChangedFields), &before, &after, &e.Actor, &e.RequestID, &e.ChangedAt); err != nil {
+			return nil, err
+		}
+		e.Before, e.After = before, after
+		entries = append(entries, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// GetAsOf rebuilds a student as it was at the given time from its history, or
+// returns nil if the student did not exist then. A student that had been
+// deleted by then is returned with DeletedAt set.
+func (r *PostgresStudentRepository) GetAsOf(id int, at time.Time) (*Student, error) {
+	var s Student
+	err := scanStudent(r.conn().QueryRow(`
+		SELECT `+studentColumns+`
+		FROM (
+			SELECT after FROM student_history
+			WHERE student_id = $1 AND changed_at <= $2
+			ORDER BY changed_at DESC, id DESC
+			LIMIT 1
+		) h
+		CROSS JOIN LATERAL jsonb_populate_record(NULL::students, h.after)`, id, at), &s)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &s, nil
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// StudentPurger permanently removes deleted students
+type StudentPurger interface {
+	PurgeDeleted(before time.Time) (int64, error)
+}
+
+// RunStudentPurge purges students deleted more than retention ago, once
+// straight away and then every interval, until ctx is cancelled. Failures are
+// logged and retried at the next interval.
+func RunStudentPurge(ctx context.Context, purger StudentPurger, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := purger.PurgeDeleted(time.Now().Add(-retention))
+		if err != nil {
+			slog.ErrorContext(ctx, "Error purging deleted students", "error", err)
+		} else if purged > 0 {
+			slog.InfoContext(ctx, "Purged deleted students", "count", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// DefaultStudentPageSize is used when no limit is requested
+	DefaultStudentPageSize = 50
+	// MaxStudentPageSize caps the number of students returned in one page
+	MaxStudentPageSize = 200
+)
+
+// cursorTimeLayout keeps the full precision of a TIMESTAMP column
+const cursorTimeLayout = "2006-01-02 15:04:05.999999"
+
+var (
+	// ErrInvalidCursor is returned when a pagination cursor cannot be decoded
+	// or does not match the requested sort order
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrInvalidSort is returned when a listing is sorted by an unknown field
+	ErrInvalidSort = errors.New("invalid sort field")
+)
+
+// studentSortColumns maps the sort keys accepted by the API to SQL columns
+var studentSortColumns = map[string]string{
+	"id":            "id",
+	"first_name":    "first_name",
+	"last_name":     "last_name",
+	"email":         "email",
+	"student_id":    "student_id",
+	"course":        "course",
+	"year_of_study": "year_of_study",
+	"status":        "status",
+	"created_at":    "created_at",
+	"updated_at":    "updated_at",
+}
+
+// IsValidStudentSort reports whether students can be sorted by the given field
+func IsValidStudentSort(field string) bool {
+	_, ok := studentSortColumns[field]
+	return ok
+}
+
+// StudentFilter narrows the set of students returned by list queries
+type StudentFilter struct {
+	Course        string
+	YearOfStudy   int
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	// Statuses restricts the results to students in one of the given states.
+	// When empty only students in ActiveStudentStatuses are returned.
+	Statuses []string
+	// IncludeDeleted also returns students that have been deleted but not
+	// purged yet
+	IncludeDeleted bool
+}
+
+// StudentListOptions controls filtering, ordering and pagination of a listing
+type StudentListOptions struct {
+	Filter StudentFilter
+	Limit  int
+	Cursor string
+	Sort   string
+	Desc   bool
+}
+
+// StudentPage is a single page of a student listing
+type StudentPage struct {
+	Students   []Student
+	NextCursor string
+	Total      int
+}
+
+// studentCursor is the decoded form of the opaque pagination cursor. It holds
+// the sort key and id of the last row of the previous page.
+type studentCursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d"`
+	Value string `json:"v"`
+	ID    int    `json:"i"`
+}
+
+func encodeStudentCursor(c studentCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeStudentCursor(raw string) (*studentCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c studentCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// sortValue returns the text form of the sort column for the given student
+func (s *Student) sortValue(field string) string {
+	switch field {
+	case "first_name":
+		return s.FirstName
+	case "last_name":
+		return s.LastName
+	case "email":
+		return s.Email
+	case "student_id":
+		return s.StudentID
+	case "course":
+		return s.Course
+	case "status":
+		return s.Status
+	case "year_of_study":
+		return strconv.Itoa(s.YearOfStudy)
+	case "created_at":
+		return s.CreatedAt.Format(cursorTimeLayout)
+	case "updated_at":
+		return s.UpdatedAt.Format(cursorTimeLayout)
+	default:
+		return strconv.Itoa(s.ID)
+	}
+}
+
+// queryArgs accumulates positional parameters while a query is being built
+type queryArgs struct {
+	values []interface{}
+}
+
+// add appends a parameter and returns its placeholder
+func (q *queryArgs) add(v interface{}) string {
+	q.values = append(q.values, v)
+	return "$" + strconv.Itoa(len(q.values))
+}
+
+// conditions returns the WHERE clause terms for the filter
+func (f StudentFilter) conditions(q *queryArgs) []string {
+	statuses := f.Statuses
+	if len(statuses) == 0 {
+		statuses = ActiveStudentStatuses
+	}
+	where := []string{"status = ANY(" + q.add(pq.Array(statuses)) + ")"}
+	if !f.IncludeDeleted {
+		where = append(where, notDeleted)
+	}
+	if f.Course != "" {
+		where = append(where, "course = "+q.add(f.Course))
+	}
+	if f.YearOfStudy != 0 {
+		where = append(where, "year_of_study = "+q.add(f.YearOfStudy))
+	}
+	if f.CreatedAfter != nil {
+		where = append(where, "created_at > "+q.add(*f.CreatedAfter))
+	}
+	if f.CreatedBefore != nil {
+		where = append(where, "created_at < "+q.add(*f.CreatedBefore))
+	}
+	return where
+}
+
+// whereClause joins conditions into a WHERE clause, or returns an empty string
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+// List retrieves a page of students using keyset pagination
+func (r *PostgresStudentRepository) List(opts StudentListOptions) (*StudentPage, error) {
+	sortField := opts.Sort
+	if sortField == "" {
+		sortField = "id"
+	}
+	column, ok := studentSortColumns[sortField]
+	if !ok {
+		return nil, ErrInvalidSort
+	}
+	limit := opts.Limit
+	if limit <= 0 || limit > MaxStudentPageSize {
+		limit = DefaultStudentPageSize
+	}
+
+	page := &StudentPage{Students: []Student{}}
+
+	countArgs := &queryArgs{}
+	if err := r.conn().QueryRow(`SELECT COUNT(*) FROM students`+whereClause(opts.Filter.conditions(countArgs)), countArgs.values...).
+		Scan(&page.Total); err != nil {
+		return nil, err
+	}
+
+	args := &queryArgs{}
+	conditions := opts.Filter.conditions(args)
+	direction, comparison := "ASC", ">"
+	if opts.Desc {
+		direction, comparison = "DESC", "<"
+	}
+	if opts.Cursor != "" {
+		cursor, err := decodeStudentCursor(opts.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.Sort != sortField || cursor.Desc != opts.Desc {
+			return nil, ErrInvalidCursor
+		}
+		conditions = append(conditions,
+			"("+column+", id) "+comparison+" ("+args.add(cursor.Value)+", "+args.add(cursor.ID)+")")
+	}
+
+	query := `SELECT ` + studentColumns + ` FROM students` + whereClause(conditions) +
+		` ORDER BY ` + column + ` ` + direction + `, id ` + direction +
+		` LIMIT ` + args.add(limit+1)
+
+	rows, err := r.conn().Query(query, args.values...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := rows.Close(); closeErr != nil {
+			slog.ErrorContext(r.audit.logContext(), "Error closing rows", "error", closeErr)
+		}
+	}()
+
+	for rows.Next() {
+		var s Student
+		if err := scanStudent(rows, &s); err != nil {
+			return nil, err
+		}
+		page.Students = append(page.Students, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// One extra row was requested to detect whether another page exists
+	if len(page.Students) > limit {
+		page.Students = page.Students[:limit]
+		last := page.Students[limit-1]
+		page.NextCursor = encodeStudentCursor(studentCursor{
+			Sort:  sortField,
+			Desc:  opts.Desc,
+			Value: last.sortValue(sortField),
+			ID:    last.ID,
+		})
+	}
+
+	return page, nil
+}
+
+// Stream passes every student matching filter to fn in id order. Rows are
+// read from the cursor one at a time, so memory use does not grow with the
+// number of students. Iteration stops at the first error returned by fn.
+func (r *PostgresStudentRepository) Stream(filter StudentFilter, fn func(*Student) error) error {
+	args := &queryArgs{}
+	rows, err := r.conn().Query(`SELECT `+studentColumns+` FROM students`+whereClause(filter.conditions(args))+` ORDER BY id`, args.values...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := rows.Close(); closeErr != nil {
+			slog.ErrorContext(r.audit.logContext(), "Error closing rows", "error", closeErr)
+		}
+	}()
+
+	var s Student
+	for rows.Next() {
+		if err := scanStudent(rows, &s); err != nil {
+			return err
+		}
+		if err := fn(&s); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"html"
+	"log/slog"
+	"strings"
+	"unicode"
+)
+
+const (
+	// DefaultStudentSearchLimit is used when no limit is requested
+	DefaultStudentSearchLimit = 20
+	// MaxStudentSearchLimit caps the number of search results returned
+	MaxStudentSearchLimit = 100
+)
+
+// StudentSearchResult is a student matched by a search, with its relevance
+// and the fields that matched
+type StudentSearchResult struct {
+	Student
+	Rank       float64           `json:"rank"`
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
+
+// SearchTerms splits a search query into lower-case words of letters and digits
+func SearchTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// HighlightMatches HTML-escapes value and wraps every case-insensitive
+// occurrence of any term in <mark> tags. It returns an empty string when no
+// term occurs in value.
+func HighlightMatches(value string, terms []string) string {
+	lower := strings.ToLower(value)
+	marked := make([]bool, len(value))
+	found := false
+	for _, term := range terms {
+		if term == "" {
+			continue
+		}
+		for start := 0; ; {
+			i := strings.Index(lower[start:], term)
+			if i < 0 {
+				break
+			}
+			for j := start + i; j < start+i+len(term); j++ {
+				marked[j] = true
+			}
+			found = true
+			start += i + len(term)
+		}
+	}
+	if !found || len(lower) != len(value) {
+		return ""
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); {
+		j := i
+		for j < len(value) && marked[j] == marked[i] {
+			j++
+		}
+		if marked[i] {
+			b.WriteString("<mark>" + html.EscapeString(value[i:j]) + "</mark>")
+		} else {
+			b.WriteString(html.EscapeString(value[i:j]))
+		}
+		i = j
+	}
+	return b.String()
+}
+
+// highlights returns the highlighted form of every searchable field that
+// contains one of the terms
+func (s *Student) highlights(terms []string) map[string]string {
+	fields := map[string]string{
+		"first_name": s.FirstName,
+		"last_name":  s.LastName,
+		"email":      s.Email,
+		"student_id": s.StudentID,
+		"course":     s.Course,
+	}
+	result := make(map[string]string)
+	for name, value := range fields {
+		if h := HighlightMatches(value, terms); h != "" {
+			result[name] = h
+		}
+	}
+	return result
+}
+
+// escapeLike escapes the LIKE wildcards in s
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// Search finds students by prefix full-text match on any searchable field,
+// trigram similarity on names, or substring match on email and student ID.
+// Results are ordered by relevance.
+func (r *PostgresStudentRepository) Search(query string, limit int) ([]StudentSearchResult, error) {
+	results := []StudentSearchResult{}
+	terms := SearchTerms(query)
+	if len(terms) == 0 {
+		return results, nil
+	}
+	if limit <= 0 || limit > MaxStudentSearchLimit {
+		limit = DefaultStudentSearchLimit
+	}
+
+	prefixes := make([]string, len(terms))
+	for i, term := range terms {
+		prefixes[i] = term + ":*"
+	}
+
+	args := &queryArgs{}
+	tsQuery := "to_tsquery('simple', " + args.add(strings.Join(prefixes, " & ")) + ")"
+	fullQuery := args.add(strings.TrimSpace(query))
+	pattern := args.add("%" + escapeLike(strings.TrimSpace(query)) + "%")
+
+	conditions := []string{
+		"search_vector @@ " + tsQuery,
+		"email ILIKE " + pattern,
+		"student_id ILIKE " + pattern,
+	}
+	for _, term := range terms {
+		p := args.add(term)
+		conditions = append(conditions, "first_name % "+p, "last_name % "+p)
+	}
+
+	sqlQuery := `
+		SELECT ` + studentColumns + `,
+			ts_rank(search_vector, ` + tsQuery + `) +
+			GREATEST(similarity(first_name || ' ' || last_name, ` + fullQuery + `), similarity(email, ` + fullQuery + `)) AS rank
+		FROM students
+		WHERE ` + notDeleted + ` AND (` + strings.Join(conditions, " OR ") + `)
+		ORDER BY rank DESC, id
+		LIMIT ` + args.add(limit)
+
+	rows, err := r.conn().Query(sqlQuery, args.values...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := rows.Close(); closeErr != nil {
+			slog.ErrorContext(r.audit.logContext(), "Error closing rows", "error", closeErr)
+		}
+	}()
+
+	for rows.Next() {
+		var res StudentSearchResult
+		if err := scanStudent(rows, &res.Student, &res.Rank); err != nil {
+			return nil, err
+		}
+		res.Highlights = res.highlights(terms)
+		results = append(results, res)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
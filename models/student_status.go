@@ -0,0 +1,297 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// Student lifecycle states
+const (
+	StatusApplicant   = "applicant"
+	StatusEnrolled    = "enrolled"
+	StatusInterrupted = "interrupted"
+	StatusWithdrawn   = "withdrawn"
+	StatusGraduated   = "graduated"
+)
+
+// StudentStatuses lists every lifecycle state. Deleting a student is not a
+// state: it sets deleted_at, whatever the student's status.
+var StudentStatuses = []string{StatusApplicant, StatusEnrolled, StatusInterrupted, StatusWithdrawn, StatusGraduated}
+
+// ActiveStudentStatuses are the states listed when no status filter is given.
+// Interrupted students are still registered with the university.
+var ActiveStudentStatuses = []string{StatusEnrolled, StatusInterrupted}
+
+// studentTransitions is the student lifecycle state machine. It maps each
+// state to the states it may move to and the reason codes accepted for that
+// move. Graduated students cannot move on.
+var studentTransitions = map[string]map[string][]string{
+	StatusApplicant: {
+		StatusEnrolled:  {"admitted"},
+		StatusWithdrawn: {"offer_declined", "offer_withdrawn", "other"},
+	},
+	StatusEnrolled: {
+		StatusInterrupted: {"medical", "personal", "financial", "other"},
+		StatusWithdrawn:   {"academic_failure", "personal", "financial", "transfer", "other"},
+		StatusGraduated:   {"completed"},
+	},
+	StatusInterrupted: {
+		StatusEnrolled:  {"returned"},
+		StatusWithdrawn: {"did_not_return", "personal", "financial", "other"},
+	},
+	StatusWithdrawn: {
+		StatusEnrolled: {"readmitted"},
+	},
+}
+
+var (
+	// ErrIllegalTransition is returned when the state machine does not allow a
+	// student to move from their current status to the requested one
+	ErrIllegalTransition = errors.New("illegal status transition")
+	// ErrInvalidReason is returned when a reason code is not accepted for a
+	// transition
+	ErrInvalidReason = errors.New("invalid reason for status transition")
+	// ErrEffectiveDateOrder is returned when a transition would take effect
+	// before the student's previous transition
+	ErrEffectiveDateOrder = errors.New("effective date is before the previous transition")
+)
+
+// IsValidStudentStatus reports whether s is a lifecycle state
+func IsValidStudentStatus(s string) bool {
+	for _, status := range StudentStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidInitialStatus reports whether a new student may be created with the
+// given status. An empty status means enrolled.
+func IsValidInitialStatus(s string) bool {
+	return s == "" || s == StatusApplicant || s == StatusEnrolled
+}
+
+// AllowedTransitions returns the states a student may move to from status,
+// in alphabetical order
+func AllowedTransitions(status string) []string {
+	targets := make([]string, 0, len(studentTransitions[status]))
+	for to := range studentTransitions[status] {
+		targets = append(targets, to)
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// TransitionReasons returns the reason codes accepted for moving from one
+// state to another, or nil if the move is not allowed
+func TransitionReasons(from, to string) []string {
+	return studentTransitions[from][to]
+}
+
+// CheckTransition validates a move between states against the state machine
+func CheckTransition(from, to, reason string) error {
+	reasons, ok := studentTransitions[from][to]
+	if !ok {
+		return ErrIllegalTransition
+	}
+	for _, r := range reasons {
+		if r == reason {
+			return nil
+		}
+	}
+	return ErrInvalidReason
+}
+
+// StudentTransition records a change of a student's lifecycle status
+type StudentTransition struct {
+	ID            int       `json:"id"`
+	StudentID     int       `json:"student_id"`
+	FromStatus    string    `json:"from_status"`
+	ToStatus      string    `json:"to_status"`
+	Reason        string    `json:"reason"`
+	Note          string    `json:"note"`
+	EffectiveDate string    `json:"effective_date"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// StudentStatusRepository defines the interface for student lifecycle operations
+type StudentStatusRepository interface {
+	Transition(t *StudentTransition) (*Student, error)
+	ListTransitions(studentID int) ([]StudentTransition, error)
+	CountByStatus() (map[string]int, error)
+	// WithAudit returns a repository that records a as the author of its changes
+	WithAudit(a Audit) StudentStatusRepository
+}
+
+// PostgresStudentStatusRepository implements StudentStatusRepository for PostgreSQL
+type PostgresStudentStatusRepository struct {
+	DB    *sql.DB
+	audit Audit
+}
+
+// NewPostgresStudentStatusRepository creates a new PostgresStudentStatusRepository
+func NewPostgresStudentStatusRepository(db *sql.DB) *PostgresStudentStatusRepository {
+	return &PostgresStudentStatusRepository{DB: db}
+}
+
+// WithAudit returns a copy of the repository that records a as the author of
+// every transition it makes
+func (r *PostgresStudentStatusRepository) WithAudit(a Audit) StudentStatusRepository {
+	audited := *r
+	audited.audit = a
+	return &audited
+}
+
+// transitionColumns is the column list selected for every transition query,
+// in the order expected by scanTransition
+const transitionColumns = `id, student_id, from_status, to_status, reason, note, to_char(effective_date, 'YYYY-MM-DD'), created_at`
+
+// scanTransition reads a row selected with transitionColumns into a StudentTransition
+func scanTransition(row rowScanner, t *StudentTransition) error {
+	return row.Scan(&t.ID, &t.StudentID, &t.FromStatus, &t.ToStatus, &t.Reason, &t.Note, &t.EffectiveDate, &t.CreatedAt)
+}
+
+// Transition moves a student to t.ToStatus, recording the transition from
+// their current status, and returns the updated student. The student is
+// locked while the move is checked against the state machine.
+func (r *PostgresStudentStatusRepository) Transition(t *StudentTransition) (*Student, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		// Rolling back after a successful commit is a no-op
+		_ = tx.Rollback()
+	}()
+
+	if err := r.audit.apply(tx); err != nil {
+		return nil, err
+	}
+
+	var from string
+	err = tx.QueryRow(`SELECT status FROM students WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, t.StudentID).Scan(&from)
+	if err == sql.ErrNoRows {
+		return nil, ErrStudentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The current status is kept on t so callers can explain a rejected move
+	t.FromStatus = from
+	if err := CheckTransition(from, t.ToStatus, t.Reason); err != nil {
+		return nil, err
+	}
+
+	var previous sql.NullString
+	err = tx.QueryRow(`SELECT to_char(MAX(effective_date), 'YYYY-MM-DD') FROM student_status_transitions WHERE student_id = $1`,
+		t.StudentID).Scan(&previous)
+	if err != nil {
+		return nil, err
+	}
+	// Dates are YYYY-MM-DD, so they compare correctly as strings
+	if previous.Valid && t.EffectiveDate < previous.String {
+		return nil, ErrEffectiveDateOrder
+	}
+
+	err = scanTransition(tx.QueryRow(`
+		INSERT INTO student_status_transitions (student_id, from_status, to_status, reason, note, effective_date)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+transitionColumns,
+		t.StudentID, t.FromStatus, t.ToStatus, t.Reason, t.Note, t.EffectiveDate), t)
+	if err != nil {
+		return nil, err
+	}
+
+	var s Student
+	err = scanStudent(tx.QueryRow(`
+		UPDATE students
+		SET status = $1, version = version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+		RETURNING `+studentColumns, t.ToStatus, t.StudentID), &s)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ListTransitions retrieves a student's status history, oldest first
+func (r *PostgresStudentStatusRepository) ListTransitions(studentID int) ([]StudentTransition, error) {
+	var exists bool
+	if err := r.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM students WHERE id = $1 AND deleted_at IS NULL)`, studentID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrStudentNotFound
+	}
+
+	rows, err := r.DB.Query(`
+		SELECT `+transitionColumns+`
+		FROM student_status_transitions
+		WHERE student_id = $1
+		ORDER BY effective_date, id`, studentID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := rows.Close(); closeErr != nil {
+			slog.ErrorContext(r.audit.logContext(), "Error closing rows", "error", closeErr)
+		}
+	}()
+
+	transitions := []StudentTransition{}
+	for rows.Next() {
+		var t StudentTransition
+		if err := scanTransition(rows, &t); err != nil {
+			return nil, err
+		}
+		transitions = append(transitions, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return transitions, nil
+}
+
+// CountByStatus returns the number of students in each lifecycle state,
+// including states that have no students. Deleted students are not counted.
+func (r *PostgresStudentStatusRepository) CountByStatus() (map[string]int, error) {
+	rows, err := r.DB.Query(`SELECT status, COUNT(*) FROM students WHERE deleted_at IS NULL GROUP BY status`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := rows.Close(); closeErr != nil {
+			slog.ErrorContext(r.audit.logContext(), "Error closing rows", "error", closeErr)
+		}
+	}()
+
+	counts := make(map[string]int, len(StudentStatuses))
+	for _, status := range StudentStatuses {
+		counts[status] = 0
+	}
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		counts[status] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,57 @@
+package models
+
+import "errors"
+
+// ErrNestedTransaction is returned when Begin is called on a repository that
+// is already bound to a transaction
+var ErrNestedTransaction = errors.New("transaction already in progress")
+
+// Begin starts a transaction and returns a repository bound to it
+func (r *PostgresStudentRepository) Begin() (StudentTx, error) {
+	if r.tx != nil {
+		return nil, ErrNestedTransaction
+	}
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.audit.apply(tx); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	return &PostgresStudentRepository{DB: r.DB, tx: tx, audit: r.audit}, nil
+}
+
+// Try runs fn inside a savepoint, rolling back to it if fn fails
+func (r *PostgresStudentRepository) Try(fn func() error) error {
+	if r.tx == nil {
+		return fn()
+	}
+	if _, err := r.tx.Exec("SAVEPOINT student_op"); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		if _, rbErr := r.tx.Exec("ROLLBACK TO SAVEPOINT student_op"); rbErr != nil {
+			return rbErr
+		}
+		return err
+	}
+	_, err := r.tx.Exec("RELEASE SAVEPOINT student_op")
+	return err
+}
+
+// Commit commits the transaction the repository is bound to
+func (r *PostgresStudentRepository) Commit() error {
+	if r.tx == nil {
+		return nil
+	}
+	return r.tx.Commit()
+}
+
+// Rollback aborts the transaction the repository is bound to
+func (r *PostgresStudentRepository) Rollback() error {
+	if r.tx == nil {
+		return nil
+	}
+	return r.tx.Rollback()
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Transcript records an issued transcript so that its verification code can
+// be checked later
+type Transcript struct {
+	ID             int       `json:"-"`
+	Code           string    `json:"code"`
+	StudentID      int       `json:"student_id"`
+	StudentNumber  string    `json:"student_number"`
+	StudentName    string    `json:"student_name"`
+	Course         string    `json:"course"`
+	Classification string    `json:"classification"`
+	Pages          int       `json:"pages"`
+	SHA256         string    `json:"sha256"`
+	IssuedAt       time.Time `json:"issued_at"`
+}
+
+// TranscriptRepository defines the interface for transcript data operations
+type TranscriptRepository interface {
+	Create(transcript *Transcript) error
+	GetByCode(code string) (*Transcript, error)
+}
+
+// PostgresTranscriptRepository implements TranscriptRepository for PostgreSQL
+type PostgresTranscriptRepository struct {
+	DB *sql.DB
+}
+
+// NewPostgresTranscriptRepository creates a new PostgresTranscriptRepository
+func NewPostgresTranscriptRepository(db *sql.DB) *PostgresTranscriptRepository {
+	return &PostgresTranscriptRepository{DB: db}
+}
+
+// Create records an issued transcript
+func (r *PostgresTranscriptRepository) Create(transcript *Transcript) error {
+	return r.DB.QueryRow(`
+		INSERT INTO transcripts (code, student_id, student_number, student_name, course, classification, pages, sha256)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, issued_at`,
+		transcript.Code, transcript.StudentID, transcript.StudentNumber, transcript.StudentName,
+		transcript.Course, transcript.Classification, transcript.Pages, transcript.SHA256,
+	).Scan(&transcript.ID, &transcript.IssuedAt)
+}
+
+// GetByCode retrieves an issued transcript by its verification code, or nil
+// if there is none
+func (r *PostgresTranscriptRepository) GetByCode(code string) (*Transcript, error) {
+	var t Transcript
+	err := r.DB.QueryRow(`
+		SELECT id, code, student_id, student_number, student_name, course, classification, pages, sha256, issued_at
+		FROM transcripts
+		WHERE code = $1`, code).Scan(
+		&t.ID, &t.Code, &t.StudentID, &t.StudentNumber, &t.StudentName,
+		&t.Course, &t.Classification, &t.Pages, &t.SHA256, &t.IssuedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &t, nil
+}
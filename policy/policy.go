@@ -0,0 +1,263 @@
+// Package policy decides what an authenticated caller may do with student
+// records, based on the permissions granted by its roles
+package policy
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/bournemouth-uni-it-api-go/models"
+)
+
+// Permissions granted by roles and API key scopes
+const (
+	// StudentsRead allows reading any student
+	StudentsRead = "students:read"
+	// StudentsReadTutees allows a personal tutor to read their tutees
+	StudentsReadTutees = "students:read:tutees"
+	// StudentsReadOwn allows a student to read their own record
+	StudentsReadOwn = "students:read:own"
+	// StudentsWrite allows creating and editing any student
+	StudentsWrite = "students:write"
+	// StudentsWriteOwn allows a student to edit OwnEditableFields of their
+	// own record
+	StudentsWriteOwn = "students:write:own"
+	// StudentsDelete allows deleting and restoring students
+	StudentsDelete = "students:delete"
+	// RolesManage allows managing roles, their members and personal tutors
+	RolesManage = "roles:manage"
+	// APIKeysManage allows issuing, rotating and revoking API keys
+	APIKeysManage = "api-keys:manage"
+	// CoursesWrite allows creating, editing and deleting courses
+	CoursesWrite = "courses:write"
+	// ModulesWrite allows creating, editing and deleting modules and their
+	// assessments
+	ModulesWrite = "modules:write"
+	// EnrolmentsWrite allows enrolling students on modules and unenrolling them
+	EnrolmentsWrite = "enrolments:write"
+	// MarksWrite allows submitting marks
+	MarksWrite = "marks:write"
+	// MarksModerate allows moderating submitted marks
+	MarksModerate = "marks:moderate"
+	// MarksPublish allows publishing marks to students
+	MarksPublish = "marks:publish"
+)
+
+// Permissions lists every permission that can be granted, sorted
+var Permissions = []string{
+	APIKeysManage,
+	CoursesWrite,
+	EnrolmentsWrite,
+	MarksModerate,
+	MarksPublish,
+	MarksWrite,
+	ModulesWrite,
+	RolesManage,
+	StudentsDelete,
+	StudentsRead,
+	StudentsReadOwn,
+	StudentsReadTutees,
+	StudentsWrite,
+	StudentsWriteOwn,
+}
+
+// IsValidPermission reports whether p is one of Permissions
+func IsValidPermission(p string) bool {
+	i := sort.SearchStrings(Permissions, p)
+	return i < len(Permissions) && Permissions[i] == p
+}
+
+// OwnEditableFields are the columns a student may change on their own record
+var OwnEditableFields = []string{"first_name", "last_name"}
+
+// Store looks up the grants that decisions are based on
+type Store interface {
+	Permissions(subject string, roles []string) ([]string, error)
+	IsTutorOf(tutor string, studentID int) (bool, error)
+}
+
+// Subject is the caller a decision is made for. For students, ID is their
+// university student number.
+type Subject struct {
+	ID    string
+	Roles []string
+	// Permissions are granted directly rather than through a role, such as
+	// the scopes of an API key
+	Permissions []string
+}
+
+// Decision is the outcome of a policy check. Reason explains a denial.
+type Decision struct {
+	Allowed bool
+	Reason  string
+}
+
+var allow = Decision{Allowed: true}
+
+// deny returns a denial with the given reason
+func deny(reason string) Decision {
+	return Decision{Reason: reason}
+}
+
+// Policy answers access questions about student records
+type Policy struct {
+	Store Store
+}
+
+// New creates a Policy backed by store
+func New(store Store) *Policy {
+	return &Policy{Store: store}
+}
+
+// auditedStore is a Store that can log errors with the request ID of an audit
+type auditedStore interface {
+	WithAudit(a models.Audit) models.AccessRepository
+}
+
+// WithAudit returns a copy of the policy whose store logs errors with a's
+// request ID, if the store supports it
+func (p *Policy) WithAudit(a models.Audit) *Policy {
+	if store, ok := p.Store.(auditedStore); ok {
+		return &Policy{Store: store.WithAudit(a)}
+	}
+	return p
+}
+
+// grants is the set of permissions held by a subject
+type grants map[string]bool
+
+// grants looks up the permissions of a subject
+func (p *Policy) grants(s Subject) (grants, error) {
+	permissions, err := p.Store.Permissions(s.ID, s.Roles)
+	if err != nil {
+		return nil, err
+	}
+	g := make(grants, len(permissions)+len(s.Permissions))
+	for _, permission := range permissions {
+		g[permission] = true
+	}
+	for _, permission := range s.Permissions {
+		g[permission] = true
+	}
+	return g, nil
+}
+
+// Require allows subjects holding permission
+func (p *Policy) Require(s Subject, permission string) (Decision, error) {
+	g, err := p.grants(s)
+	if err != nil {
+		return Decision{}, err
+	}
+	if !g[permission] {
+		return deny("Requires permission " + permission), nil
+	}
+	return allow, nil
+}
+
+// RequireAny allows subjects holding at least one of permissions
+func (p *Policy) RequireAny(s Subject, permissions ...string) (Decision, error) {
+	g, err := p.grants(s)
+	if err != nil {
+		return Decision{}, err
+	}
+	for _, permission := range permissions {
+		if g[permission] {
+			return allow, nil
+		}
+	}
+	return deny("Requires permission " + strings.Join(permissions, " or ")), nil
+}
+
+// Missing returns those of permissions the subject does not hold, sorted
+func (p *Policy) Missing(s Subject, permissions []string) ([]string, error) {
+	g, err := p.grants(s)
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
+	for _, permission := range permissions {
+		if !g[permission] {
+			missing = append(missing, permission)
+		}
+	}
+	sort.Strings(missing)
+	return missing, nil
+}
+
+// ReadStudent allows reading a student to subjects that can read any
+// student, to the student's personal tutors and to the student themselves.
+// A nil student, e.g. one that has been deleted, can only be read with
+// StudentsRead.
+func (p *Policy) ReadStudent(s Subject, student *models.Student) (Decision, error) {
+	g, err := p.grants(s)
+	if err != nil {
+		return Decision{}, err
+	}
+	if g[StudentsRead] {
+		return allow, nil
+	}
+
+	if student != nil && g[StudentsReadOwn] && isOwn(s, student) {
+		return allow, nil
+	}
+	if student != nil && g[StudentsReadTutees] {
+		tutor, err := p.Store.IsTutorOf(s.ID, student.ID)
+		if err != nil {
+			return Decision{}, err
+		}
+		if tutor {
+			return allow, nil
+		}
+		return deny("Tutors can only read their own tutees"), nil
+	}
+	if g[StudentsReadOwn] {
+		return deny("Students can only read their own record"), nil
+	}
+	return deny("Requires permission " + StudentsRead), nil
+}
+
+// EditStudent allows changing the given columns of a student to subjects
+// that can edit any student, and to the student themselves if every column
+// is one of OwnEditableFields
+func (p *Policy) EditStudent(s Subject, student *models.Student, columns []string) (Decision, error) {
+	g, err := p.grants(s)
+	if err != nil {
+		return Decision{}, err
+	}
+	if g[StudentsWrite] {
+		return allow, nil
+	}
+	if !g[StudentsWriteOwn] {
+		return deny("Requires permission " + StudentsWrite), nil
+	}
+	if !isOwn(s, student) {
+		return deny("Students can only edit their own record"), nil
+	}
+
+	var forbidden []string
+	for _, column := range columns {
+		if !isOwnEditable(column) {
+			forbidden = append(forbidden, column)
+		}
+	}
+	if len(forbidden) > 0 {
+		sort.Strings(forbidden)
+		return deny("Students cannot change " + strings.Join(forbidden, ", ") + "; only " + strings.Join(OwnEditableFields, ", ")), nil
+	}
+	return allow, nil
+}
+
+// isOwn reports whether student is the subject's own record
+func isOwn(s Subject, student *models.Student) bool {
+	return student != nil && s.ID != "" && strings.EqualFold(s.ID, student.StudentID)
+}
+
+// isOwnEditable reports whether column is one of OwnEditableFields
+func isOwnEditable(column string) bool {
+	for _, field := range OwnEditableFields {
+		if field == column {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,200 @@
+package problem
+
+import (
+	"net/http"
+	"sort"
+)
+
+// Code identifies a kind of problem. Codes are part of the API: once
+// published they keep their meaning, so clients can rely on them.
+type Code string
+
+// Problems with requests in general
+const (
+	InvalidBody          Code = "request.invalid_body"
+	InvalidParameter     Code = "request.invalid_parameter"
+	ValidationFailed     Code = "request.validation_failed"
+	UnsupportedMediaType Code = "request.unsupported_media_type"
+	TooLarge             Code = "request.too_large"
+	PreconditionRequired Code = "request.precondition_required"
+	PatchNotApplicable   Code = "request.patch_not_applicable"
+	RouteNotFound        Code = "request.route_not_found"
+	RateLimited          Code = "request.rate_limited"
+	InternalError        Code = "internal.error"
+)
+
+// Authentication, authorisation and cross-origin problems
+const (
+	AuthRequired         Code = "auth.required"
+	InvalidToken         Code = "auth.invalid_token"
+	TokenExpired         Code = "auth.token_expired"
+	InvalidAPIKey        Code = "auth.invalid_api_key"
+	APIKeyExpired        Code = "auth.api_key_expired"
+	APIKeyRevoked        Code = "auth.api_key_revoked"
+	SessionExpired       Code = "auth.session_expired"
+	InvalidCSRFToken     Code = "auth.invalid_csrf_token"
+	Forbidden            Code = "auth.forbidden"
+	NotSignedIn          Code = "auth.not_signed_in"
+	SignInFailed         Code = "auth.sign_in_failed"
+	SignInExpired        Code = "auth.sign_in_expired"
+	CORSOriginNotAllowed Code = "cors.origin_not_allowed"
+	CORSMethodNotAllowed Code = "cors.method_not_allowed"
+	CORSHeaderNotAllowed Code = "cors.header_not_allowed"
+)
+
+// Problems with students
+const (
+	StudentNotFound          Code = "student.not_found"
+	StudentModified          Code = "student.modified"
+	StudentEmailTaken        Code = "student.email_taken"
+	StudentIDTaken           Code = "student.student_id_taken"
+	StudentDeleted           Code = "student.deleted"
+	StudentNotDeleted        Code = "student.not_deleted"
+	StudentUnknownCourse     Code = "student.unknown_course"
+	StudentInvalidStatus     Code = "student.invalid_initial_status"
+	TransitionNotAllowed     Code = "student.transition_not_allowed"
+	InvalidTransitionReason  Code = "student.invalid_transition_reason"
+	InvalidEffectiveDate     Code = "student.invalid_effective_date"
+	ClassificationIncomplete Code = "student.classification_incomplete"
+	ImportInvalidFile        Code = "import.invalid_file"
+	BatchInvalid             Code = "batch.invalid"
+	BatchNotAttempted        Code = "batch.not_attempted"
+	BatchRolledBack          Code = "batch.rolled_back"
+	DuplicateEntry           Code = "resource.duplicate"
+)
+
+// Problems with courses, modules, enrolments, assessments, marks, transcripts,
+// roles and API keys
+const (
+	CourseNotFound          Code = "course.not_found"
+	CourseCodeTaken         Code = "course.code_taken"
+	CourseInUse             Code = "course.in_use"
+	ModuleNotFound          Code = "module.not_found"
+	ModuleCodeTaken         Code = "module.code_taken"
+	ModuleInUse             Code = "module.in_use"
+	ModuleFull              Code = "module.full"
+	EnrolmentNotFound       Code = "enrolment.not_found"
+	AlreadyEnrolled         Code = "enrolment.already_enrolled"
+	NotEnrolled             Code = "enrolment.not_enrolled"
+	EnrolmentHasMarks       Code = "enrolment.has_marks"
+	LevelMismatch           Code = "enrolment.level_mismatch"
+	AssessmentNotFound      Code = "assessment.not_found"
+	WeightingExceeded       Code = "assessment.weighting_exceeded"
+	MarkNotFound            Code = "mark.not_found"
+	MarkOutOfRange          Code = "mark.out_of_range"
+	MarkAlreadyPublished    Code = "mark.already_published"
+	TranscriptUnknownCode   Code = "transcript.unknown_code"
+	RoleNotFound            Code = "role.not_found"
+	RoleProtected           Code = "role.protected"
+	RoleUnknownPermission   Code = "role.unknown_permission"
+	RoleMemberNotFound      Code = "role.member_not_found"
+	TuteeNotFound           Code = "role.tutee_not_found"
+	APIKeyNotFound          Code = "api_key.not_found"
+	APIKeyNameTaken         Code = "api_key.name_taken"
+	APIKeyAlreadyRevoked    Code = "api_key.revoked"
+	APIKeyUnknownScope      Code = "api_key.unknown_scope"
+	APIKeyScopeNotHeld      Code = "api_key.scope_not_held"
+	APIKeyInvalidExpiration Code = "api_key.invalid_expiration"
+)
+
+// titles are the short, human-readable summaries of codes
+var titles = map[Code]string{
+	InvalidBody:          "Request body is malformed",
+	InvalidParameter:     "Invalid parameter",
+	ValidationFailed:     "Validation failed",
+	UnsupportedMediaType: "Unsupported media type",
+	TooLarge:             "Request is too large",
+	PreconditionRequired: "Precondition required",
+	PatchNotApplicable:   "Patch cannot be applied",
+	RouteNotFound:        "Route not found",
+	RateLimited:          "Rate limit exceeded",
+	InternalError:        "Internal server error",
+
+	AuthRequired:         "Authentication required",
+	InvalidToken:         "Invalid bearer token",
+	TokenExpired:         "Bearer token has expired",
+	InvalidAPIKey:        "Invalid API key",
+	APIKeyExpired:        "API key has expired",
+	APIKeyRevoked:        "API key has been revoked",
+	SessionExpired:       "Session has expired",
+	InvalidCSRFToken:     "Missing or invalid CSRF token",
+	Forbidden:            "Permission denied",
+	NotSignedIn:          "Not signed in",
+	SignInFailed:         "Sign-in failed",
+	SignInExpired:        "Sign-in has expired",
+	CORSOriginNotAllowed: "Origin not allowed",
+	CORSMethodNotAllowed: "Method not allowed from other origins",
+	CORSHeaderNotAllowed: "Header not allowed from other origins",
+
+	StudentNotFound:          "Student not found",
+	StudentModified:          "Student has been modified",
+	StudentEmailTaken:        "Email already exists",
+	StudentIDTaken:           "Student ID already exists",
+	StudentDeleted:           "Student is deleted",
+	StudentNotDeleted:        "Student is not deleted",
+	StudentUnknownCourse:     "Course does not exist",
+	StudentInvalidStatus:     "Invalid initial status",
+	TransitionNotAllowed:     "Status transition not allowed",
+	InvalidTransitionReason:  "Invalid reason for status transition",
+	InvalidEffectiveDate:     "Invalid effective date",
+	ClassificationIncomplete: "Classification is incomplete",
+	ImportInvalidFile:        "Invalid import file",
+	BatchInvalid:             "Invalid batch",
+	BatchNotAttempted:        "Operation not attempted",
+	BatchRolledBack:          "Operation rolled back",
+	DuplicateEntry:           "Duplicate entry",
+
+	CourseNotFound:          "Course not found",
+	CourseCodeTaken:         "Course code already exists",
+	CourseInUse:             "Course has students",
+	ModuleNotFound:          "Module not found",
+	ModuleCodeTaken:         "Module code already exists",
+	ModuleInUse:             "Module has enrolments",
+	ModuleFull:              "Module is full",
+	EnrolmentNotFound:       "Enrolment not found",
+	AlreadyEnrolled:         "Student is already enrolled",
+	NotEnrolled:             "Student is not enrolled",
+	EnrolmentHasMarks:       "Enrolment has marks",
+	LevelMismatch:           "Module level does not match year of study",
+	AssessmentNotFound:      "Assessment not found",
+	WeightingExceeded:       "Assessment weightings exceed 100",
+	MarkNotFound:            "Mark not found",
+	MarkOutOfRange:          "Mark is out of range",
+	MarkAlreadyPublished:    "Mark has already been published",
+	TranscriptUnknownCode:   "Unknown verification code",
+	RoleNotFound:            "Role not found",
+	RoleProtected:           "Role is protected",
+	RoleUnknownPermission:   "Unknown permission",
+	RoleMemberNotFound:      "Role member not found",
+	TuteeNotFound:           "Tutee not found",
+	APIKeyNotFound:          "API key not found",
+	APIKeyNameTaken:         "API key name already exists",
+	APIKeyAlreadyRevoked:    "API key has been revoked",
+	APIKeyUnknownScope:      "Unknown API key scope",
+	APIKeyScopeNotHeld:      "API key scope not held by caller",
+	APIKeyInvalidExpiration: "Invalid API key expiration",
+}
+
+// Title returns the title of code, or the text of status if code is unknown
+func (code Code) Title(status int) string {
+	if title, ok := titles[code]; ok {
+		return title
+	}
+	return http.StatusText(status)
+}
+
+// Known reports whether code is one of the codes defined here
+func (code Code) Known() bool {
+	_, ok := titles[code]
+	return ok
+}
+
+// Codes returns every code defined here, sorted
+func Codes() []Code {
+	codes := make([]Code, 0, len(titles))
+	for code := range titles {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	return codes
+}
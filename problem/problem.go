@@ -0,0 +1,128 @@
+// Package problem writes error responses as RFC 7807 problem details, served
+// as application/problem+json. Every problem carries a stable code, such as
+// student.email_taken, that clients can branch on instead of matching the
+// human-readable title and detail, which may change.
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContentType is the media type of problem responses
+const ContentType = "application/problem+json"
+
+// TypeBase is prefixed to a code to form the problem's type URI. It is a
+// path on this API, where the code is described.
+const TypeBase = "/problems/"
+
+// Problem is the body of an error response
+type Problem struct {
+	// Type is a URI identifying the kind of problem, TypeBase + Code
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	// Status repeats the HTTP status code of the response
+	Status int `json:"status"`
+	// Detail explains this occurrence of the problem
+	Detail string `json:"detail,omitempty"`
+	// Instance is the path of the request that had the problem
+	Instance string `json:"instance,omitempty"`
+	Code     Code   `json:"code"`
+	// Errors lists the fields of the request that are invalid
+	Errors []FieldError `json:"errors,omitempty"`
+	// Extensions holds further members specific to the problem
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// FieldError is an invalid field of a request
+type FieldError struct {
+	// Field is the JSON name of the field, or the query parameter
+	Field string `json:"field"`
+	// Code names the failed rule, e.g. required, email or max
+	Code   string `json:"code"`
+	Detail string `json:"detail"`
+}
+
+// New creates a problem with the given status, code and detail
+func New(status int, code Code, detail string) *Problem {
+	return &Problem{
+		Type:   TypeBase + string(code),
+		Title:  code.Title(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// With adds an extension member to p and returns p
+func (p *Problem) With(key string, value interface{}) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = make(map[string]interface{})
+	}
+	p.Extensions[key] = value
+	return p
+}
+
+// WithErrors adds invalid fields to p and returns p
+func (p *Problem) WithErrors(errs ...FieldError) *Problem {
+	p.Errors = append(p.Errors, errs...)
+	return p
+}
+
+// Error returns the detail of p, or its title if it has none
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+	return p.Title
+}
+
+// MarshalJSON encodes p with its extension members alongside the standard ones
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	type standard Problem
+	if len(p.Extensions) == 0 {
+		return json.Marshal((*standard)(p))
+	}
+
+	members := make(map[string]interface{}, len(p.Extensions)+7)
+	for k, v := range p.Extensions {
+		members[k] = v
+	}
+	b, err := json.Marshal((*standard)(p))
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	// Standard members win over extensions of the same name
+	for k, v := range fields {
+		members[k] = v
+	}
+	return json.Marshal(members)
+}
+
+// Abort writes p as the response and stops the request's remaining handlers.
+// The instance is set to the request path if p has none.
+func Abort(c *gin.Context, p *Problem) {
+	if p.Instance == "" && c.Request != nil {
+		p.Instance = c.Request.URL.Path
+	}
+	c.Header("Content-Type", ContentType)
+	c.AbortWithStatusJSON(p.Status, p)
+}
+
+// Write writes a problem with the given status, code and detail as the
+// response and stops the request's remaining handlers
+func Write(c *gin.Context, status int, code Code, detail string) {
+	Abort(c, New(status, code, detail))
+}
+
+// Internal writes a 500 problem. The detail must not reveal the underlying
+// error, which should be logged instead.
+func Internal(c *gin.Context, detail string) {
+	Write(c, http.StatusInternalServerError, InternalError, detail)
+}
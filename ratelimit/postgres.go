@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// PostgresStore keeps buckets in PostgreSQL so that all replicas of the API
+// share them. Times come from the database clock, so replica clocks need not
+// agree.
+type PostgresStore struct {
+	DB *sql.DB
+
+	mu        sync.Mutex
+	lastPrune time.Time
+}
+
+// NewPostgresStore creates a new PostgresStore
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{DB: db}
+}
+
+// Take takes a request from the bucket with the given key. The bucket's row is
+// locked while it is updated, so concurrent requests are counted correctly.
+func (s *PostgresStore) Take(key string, limit Limit) (Result, error) {
+	if err := s.maybePrune(); err != nil {
+		return Result{}, err
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return Result{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`INSERT INTO rate_limit_buckets (key) VALUES ($1) ON CONFLICT (key) DO NOTHING`, key); err != nil {
+		return Result{}, err
+	}
+
+	var (
+		b       bucket
+		updated sql.NullTime
+		now     time.Time
+	)
+	err = tx.QueryRow(`
+		SELECT tokens, updated_at, CURRENT_TIMESTAMP
+		FROM rate_limit_buckets WHERE key = $1 FOR UPDATE`, key).Scan(&b.tokens, &updated, &now)
+	if err != nil {
+		return Result{}, err
+	}
+	b.updated = updated.Time
+
+	result := b.take(limit, now)
+	_, err = tx.Exec(`
+		UPDATE rate_limit_buckets SET tokens = $2, updated_at = $3, full_at = $4
+		WHERE key = $1`, key, b.tokens, b.updated, now.Add(result.Reset))
+	if err != nil {
+		return Result{}, err
+	}
+	return result, tx.Commit()
+}
+
+// maybePrune drops buckets that have refilled, at most once every
+// pruneInterval per replica
+func (s *PostgresStore) maybePrune() error {
+	s.mu.Lock()
+	if time.Since(s.lastPrune) < pruneInterval {
+		s.mu.Unlock()
+		return nil
+	}
+	s.lastPrune = time.Now()
+	s.mu.Unlock()
+
+	_, err := s.DB.Exec(`DELETE FROM rate_limit_buckets WHERE full_at < CURRENT_TIMESTAMP`)
+	return err
+}
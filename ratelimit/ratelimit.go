@@ -0,0 +1,142 @@
+// Package ratelimit throttles clients with token buckets. A bucket holds up to
+// a limit's number of requests and refills evenly over its period, so clients
+// can burst up to the limit but not exceed it on average.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pruneInterval is how often stores drop buckets that have refilled
+const pruneInterval = time.Minute
+
+// Limit allows Requests requests every Period. The zero Limit is unlimited.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// Unlimited reports whether l does not restrict requests
+func (l Limit) Unlimited() bool {
+	return l.Requests <= 0 || l.Period <= 0
+}
+
+// String formats l as accepted by ParseLimit
+func (l Limit) String() string {
+	if l.Unlimited() {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", l.Requests, l.Period)
+}
+
+// interval is the time taken to refill one request
+func (l Limit) interval() time.Duration {
+	return l.Period / time.Duration(l.Requests)
+}
+
+// ParseLimit parses a limit such as "100/1m", or "off" for no limit
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "off" {
+		return Limit{}, nil
+	}
+
+	requests, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q, expected requests/period such as 100/1m", s)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(requests))
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("invalid number of requests in rate limit %q", s)
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(period))
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("invalid period in rate limit %q", s)
+	}
+	return Limit{Requests: n, Period: d}, nil
+}
+
+// ParseRouteLimits parses comma-separated limits for routes, such as
+// "GET /api/v1/students=60/1m, POST /api/v1/students/import=10/1h". Routes are
+// a method and a path pattern as registered with the router.
+func ParseRouteLimits(s string) (map[string]Limit, error) {
+	limits := make(map[string]Limit)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid route rate limit %q, expected METHOD /path=requests/period", entry)
+		}
+		method, path, ok := strings.Cut(strings.TrimSpace(route), " ")
+		if !ok || !strings.HasPrefix(strings.TrimSpace(path), "/") {
+			return nil, fmt.Errorf("invalid route %q in rate limit, expected METHOD /path", route)
+		}
+		limit, err := ParseLimit(value)
+		if err != nil {
+			return nil, err
+		}
+		limits[Route(method, strings.TrimSpace(path))] = limit
+	}
+	return limits, nil
+}
+
+// Route returns the key of a route in the limits returned by ParseRouteLimits
+func Route(method, path string) string {
+	return strings.ToUpper(strings.TrimSpace(method)) + " " + path
+}
+
+// Result is the outcome of taking a request from a bucket
+type Result struct {
+	Limit   Limit
+	Allowed bool
+	// Remaining is the number of requests that can be made straight away
+	Remaining int
+	// RetryAfter is how long to wait until the next request is allowed. It
+	// is zero if Remaining is positive.
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+}
+
+// Store keeps the buckets of all clients
+type Store interface {
+	// Take takes a request from the bucket with the given key, if there is
+	// one left
+	Take(key string, limit Limit) (Result, error)
+}
+
+// bucket is the state of one client's token bucket
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// take refills b up to now and takes a request from it if there is one
+func (b *bucket) take(limit Limit, now time.Time) Result {
+	capacity := float64(limit.Requests)
+	if b.updated.IsZero() {
+		b.tokens = capacity
+	} else if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+float64(elapsed)/float64(limit.interval()))
+	}
+	b.updated = now
+
+	result := Result{Limit: limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	}
+	result.Remaining = int(b.tokens)
+	if b.tokens < 1 {
+		result.RetryAfter = time.Duration((1 - b.tokens) * float64(limit.interval()))
+	}
+	result.Reset = time.Duration((capacity - b.tokens) * float64(limit.interval()))
+	return result
+}
@@ -1,34 +1,93 @@
 package router
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"net/http"
+	"strings"
 
+	"github.com/bournemouth-uni-it-api-go/config"
 	"github.com/bournemouth-uni-it-api-go/handlers"
+	"github.com/bournemouth-uni-it-api-go/metrics"
 	"github.com/bournemouth-uni-it-api-go/middleware"
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/bournemouth-uni-it-api-go/policy"
+	"github.com/bournemouth-uni-it-api-go/ratelimit"
 	"github.com/gin-gonic/gin"
 )
 
-// SetupRouter configures the API routes
-func SetupRouter(db *sql.DB) *gin.Engine {
+// SetupRouter configures the API routes. Requests are recorded in m and
+// served at /metrics unless m is nil. It fails if the token verification keys
+// cannot be loaded.
+func SetupRouter(db *sql.DB, cfg *config.Config, m *metrics.Metrics) (*gin.Engine, error) {
+	authenticator, err := middleware.NewJWTAuthenticator(cfg.JWT)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up authentication: %w", err)
+	}
+
+	// Sign-in to the web interface is optional
+	var (
+		authHandler *handlers.AuthHandler
+		sessions    middleware.SessionVerifier
+	)
+	if cfg.OIDC.Enabled() {
+		sessionRepo := models.NewPostgresSessionRepository(db)
+		authHandler, err = handlers.NewAuthHandler(context.Background(), sessionRepo, cfg.OIDC)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up sign-in: %w", err)
+		}
+		sessions = sessionRepo
+	}
+
+	// Rate limits are shared between replicas when they are kept in Postgres
+	var rateLimitStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if cfg.RateLimit.Shared {
+		rateLimitStore = ratelimit.NewPostgresStore(db)
+	}
+	rateLimit := middleware.RateLimit(rateLimitStore, cfg.RateLimit)
+	ipRateLimit := middleware.RateLimitByIP(rateLimitStore, cfg.RateLimit)
+
 	r := gin.New()
 
-	// Use middleware
-	r.Use(gin.Recovery())
+	// Client IP addresses, which rate limits are keyed on, are only read from
+	// X-Forwarded-For when it was set by a trusted proxy
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+
+	// Use middleware. Recovery comes after Logger and Metrics so that
+	// requests which panic are logged and counted with their 500.
+	r.Use(middleware.RequestID())
 	r.Use(middleware.Logger())
+	if m != nil {
+		r.Use(middleware.Metrics(m))
+	}
+	r.Use(middleware.Recovery())
+	r.Use(middleware.SecurityHeaders(cfg.Security))
+	r.Use(middleware.CORS(cfg.CORS))
 
 	// Create handlers
 	studentHandler := handlers.NewStudentHandler(db)
+	studentStatusHandler := handlers.NewStudentStatusHandler(db)
+	courseHandler := handlers.NewCourseHandler(db)
+	moduleHandler := handlers.NewModuleHandler(db)
+	assessmentHandler := handlers.NewAssessmentHandler(db)
+	classificationHandler := handlers.NewClassificationHandler(db, cfg.Classification)
+	transcriptHandler := handlers.NewTranscriptHandler(db, cfg.Classification)
+	accessHandler := handlers.NewAccessHandler(db)
+	apiKeyHandler := handlers.NewAPIKeyHandler(db)
+	accessPolicy := policy.New(models.NewPostgresAccessRepository(db))
 
-	// Serve frontend HTML directly
-	r.GET("/", func(c *gin.Context) {
-		c.Header("Content-Type", "text/html")
-		c.String(http.StatusOK, getIndexHTML())
-	})
-	r.GET("/index.html", func(c *gin.Context) {
+	// Serve frontend HTML directly. Its inline script and styles carry a
+	// per-response nonce allowed by the page's Content-Security-Policy.
+	indexPage := func(c *gin.Context) {
+		nonce := middleware.PageNonce(c)
 		c.Header("Content-Type", "text/html")
-		c.String(http.StatusOK, getIndexHTML())
-	})
+		c.String(http.StatusOK, getIndexHTML(nonce))
+	}
+	r.GET("/", indexPage)
+	r.GET("/index.html", indexPage)
 
 	// Test route
 	r.GET("/test", func(c *gin.Context) {
@@ -38,31 +97,163 @@ func SetupRouter(db *sql.DB) *gin.Engine {
 	// Health check endpoint
 	r.GET("/healthcheck", studentHandler.HealthCheck)
 
-	// API v1 routes
+	// Prometheus scrapes each replica directly, optionally with a token
+	if m != nil {
+		r.GET("/metrics", middleware.MetricsAuth(cfg.Metrics.Token), gin.WrapH(m.Handler()))
+	}
+
+	// Error responses are problem details whose type is a URI under
+	// /problems, which describes the problem's code
+	r.GET("/problems", handlers.GetProblemTypes)
+	r.GET("/problems/:code", handlers.GetProblemType)
+	r.NoRoute(handlers.NotFound)
+
+	// Sign-in for the web interface
+	if authHandler != nil {
+		auth := r.Group("/auth", rateLimit)
+		{
+			auth.GET("/login", authHandler.Login)
+			auth.GET("/callback", authHandler.Callback)
+			auth.GET("/session", authHandler.Session)
+			auth.POST("/logout", authHandler.Logout)
+		}
+	}
+
+	// Transcript verification is public so that employers can check a
+	// transcript they were given
+	r.GET("/api/v1/transcripts/verify/:code", rateLimit, transcriptHandler.VerifyTranscript)
+
+	// API v1 routes, which all require a bearer token, an API key or a
+	// signed-in session. They are rate limited per IP address before
+	// credentials are checked, and per client after.
 	v1 := r.Group("/api/v1")
+	v1.Use(ipRateLimit, middleware.Authenticate(authenticator, apiKeyHandler.Repo, sessions), rateLimit)
+
+	// Routes outside StudentHandler are guarded by permission here. The
+	// marks, classification and transcript of a student are checked by their
+	// handlers, so that students and their tutors can read them.
+	readStudents := handlers.RequirePermission(accessPolicy, policy.StudentsRead)
+	writeStudents := handlers.RequirePermission(accessPolicy, policy.StudentsWrite)
+	writeEnrolments := handlers.RequirePermission(accessPolicy, policy.EnrolmentsWrite)
+	writeCourses := handlers.RequirePermission(accessPolicy, policy.CoursesWrite)
+	writeModules := handlers.RequirePermission(accessPolicy, policy.ModulesWrite)
+	publishMarks := handlers.RequirePermission(accessPolicy, policy.MarksPublish)
 	{
 		students := v1.Group("/students")
 		{
 			students.GET("", studentHandler.GetAllStudents)
+			students.GET("/search", studentHandler.SearchStudents)
+			students.GET("/export", studentHandler.ExportStudents)
+			students.GET("/by-student-id/:student_id", studentHandler.GetStudentByStudentID)
+			students.GET("/by-email/:email", studentHandler.GetStudentByEmail)
 			students.GET("/:id", studentHandler.GetStudentByID)
 			students.POST("", studentHandler.CreateStudent)
+			students.POST("/import", studentHandler.ImportStudents)
 			students.PUT("/:id", studentHandler.UpdateStudent)
+			students.PATCH("/:id", studentHandler.PatchStudent)
 			students.DELETE("/:id", studentHandler.DeleteStudent)
+			students.GET("/:id/history", studentHandler.GetStudentHistory)
+			students.POST("/:id/restore", studentHandler.RestoreStudent)
+			students.GET("/:id/transitions", readStudents, studentStatusHandler.GetStudentTransitions)
+			students.POST("/:id/transitions", writeStudents, studentStatusHandler.TransitionStudent)
+			students.GET("/:id/enrolments", readStudents, moduleHandler.GetStudentEnrolments)
+			students.POST("/:id/enrolments", writeEnrolments, moduleHandler.EnrolStudent)
+			students.DELETE("/:id/enrolments/:code", writeEnrolments, moduleHandler.DeleteEnrolment)
+			students.GET("/:id/marks", assessmentHandler.GetStudentMarks)
+			students.GET("/:id/classification", classificationHandler.GetStudentClassification)
+			students.GET("/:id/transcript.pdf", transcriptHandler.GetStudentTranscript)
 		}
+
+		courses := v1.Group("/courses")
+		{
+			courses.GET("", courseHandler.GetAllCourses)
+			courses.GET("/:code", courseHandler.GetCourseByCode)
+			courses.POST("", writeCourses, courseHandler.CreateCourse)
+			courses.PUT("/:code", writeCourses, courseHandler.UpdateCourse)
+			courses.DELETE("/:code", writeCourses, courseHandler.DeleteCourse)
+		}
+
+		modules := v1.Group("/modules")
+		{
+			modules.GET("", moduleHandler.GetAllModules)
+			modules.GET("/:code", moduleHandler.GetModuleByCode)
+			modules.GET("/:code/students", readStudents, moduleHandler.GetModuleStudents)
+			modules.POST("", writeModules, moduleHandler.CreateModule)
+			modules.PUT("/:code", writeModules, moduleHandler.UpdateModule)
+			modules.DELETE("/:code", writeModules, moduleHandler.DeleteModule)
+			modules.GET("/:code/assessments", assessmentHandler.GetModuleAssessments)
+			modules.POST("/:code/assessments", writeModules, assessmentHandler.CreateAssessment)
+		}
+
+		assessments := v1.Group("/assessments")
+		{
+			assessments.POST("/:id/marks", handlers.RequirePermission(accessPolicy, policy.MarksWrite), assessmentHandler.SubmitMark)
+			assessments.POST("/:id/publish", publishMarks, assessmentHandler.PublishAssessment)
+		}
+
+		marks := v1.Group("/marks")
+		{
+			marks.POST("/:id/moderate", handlers.RequirePermission(accessPolicy, policy.MarksModerate), assessmentHandler.ModerateMark)
+			marks.POST("/:id/publish", publishMarks, assessmentHandler.PublishMark)
+		}
+
+		// Access administration requires roles:manage
+		admin := v1.Group("/admin")
+		admin.Use(handlers.RequirePermission(accessPolicy, policy.RolesManage))
+		{
+			admin.GET("/roles", accessHandler.GetRoles)
+			admin.GET("/roles/:name", accessHandler.GetRole)
+			admin.PUT("/roles/:name", accessHandler.PutRole)
+			admin.DELETE("/roles/:name", accessHandler.DeleteRole)
+			admin.GET("/roles/:name/members", accessHandler.GetRoleMembers)
+			admin.PUT("/roles/:name/members/:subject", accessHandler.AddRoleMember)
+			admin.DELETE("/roles/:name/members/:subject", accessHandler.RemoveRoleMember)
+			admin.GET("/tutors/:subject/tutees", accessHandler.GetTutees)
+			admin.PUT("/tutors/:subject/tutees/:id", accessHandler.AssignTutee)
+			admin.DELETE("/tutors/:subject/tutees/:id", accessHandler.UnassignTutee)
+		}
+
+		// API keys for machine-to-machine integrations require api-keys:manage
+		apiKeys := v1.Group("/admin/api-keys")
+		apiKeys.Use(handlers.RequirePermission(accessPolicy, policy.APIKeysManage))
+		{
+			apiKeys.GET("", apiKeyHandler.GetAPIKeys)
+			apiKeys.GET("/:id", apiKeyHandler.GetAPIKey)
+			apiKeys.POST("", apiKeyHandler.CreateAPIKey)
+			apiKeys.POST("/:id/rotate", apiKeyHandler.RotateAPIKey)
+			apiKeys.DELETE("/:id", apiKeyHandler.RevokeAPIKey)
+		}
+
+		// Custom methods such as POST /students:batch cannot be registered as
+		// static routes, so they are dispatched on the whole path segment
+		v1.POST("/:action", func(c *gin.Context) {
+			switch c.Param("action") {
+			case "students:batch":
+				studentHandler.BatchStudents(c)
+			default:
+				handlers.NotFound(c)
+			}
+		})
 	}
 
-	return r
+	return r, nil
 }
 
-// getIndexHTML returns the HTML content for the frontend
-func getIndexHTML() string {
-	return `<!DOCTYPE html>
+// getIndexHTML returns the HTML content for the frontend, with nonce on its
+// inline script and styles
+func getIndexHTML(nonce string) string {
+	return strings.ReplaceAll(indexHTML, "{{nonce}}", nonce)
+}
+
+// indexHTML is the frontend. It must not use inline event handlers or style
+// attributes, which the Content-Security-Policy blocks.
+const indexHTML = `<!DOCTYPE html>
 <html lang="en">
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
     <title>Bournemouth University IT Students</title>
-    <style>
+    <style nonce="{{nonce}}">
         * { margin: 0; padding: 0; box-sizing: border-box; }
         body { font-family: Arial, sans-serif; background: #f5f5f5; }
         .container { max-width: 1200px; margin: 0 auto; padding: 20px; }
@@ -85,12 +276,21 @@ func getIndexHTML() string {
         .error { background: #f8d7da; color: #721c24; border: 1px solid #f5c6cb; }
         .form-row { display: flex; gap: 15px; }
         .form-row .form-group { flex: 1; }
+        #account { margin-top: 10px; }
+        #account a { color: white; }
     </style>
 </head>
 <body>
     <div class="header">
         <h1>Bournemouth University IT Students Management</h1>
         <p>Student Information System - Load Balanced</p>
+        <div id="account">
+            <a id="signIn" href="/auth/login" hidden>Sign in</a>
+            <form id="signOut" method="post" action="/auth/logout" hidden>
+                <span id="userName"></span>
+                <button type="submit">Sign out</button>
+            </form>
+        </div>
     </div>
     <div class="container">
         <div id="message"></div>
@@ -121,7 +321,7 @@ func getIndexHTML() string {
                 <div class="form-row">
                     <div class="form-group">
                         <label for="course">Course:</label>
-                        <input type="text" id="course" value="Information Technology" required>
+                        <select id="course" required></select>
                     </div>
                     <div class="form-group">
                         <label for="yearOfStudy">Year of Study:</label>
@@ -134,8 +334,20 @@ func getIndexHTML() string {
                     </div>
                 </div>
                 <button type="submit" id="submitBtn">Add Student</button>
-                <button type="button" id="cancelBtn" onclick="resetForm()" style="display:none;">Cancel</button>
+                <button type="button" id="cancelBtn" hidden>Cancel</button>
+            </form>
+        </div>
+        <div class="form-section">
+            <h2>Import Students from CSV</h2>
+            <form id="importForm">
+                <div class="form-group">
+                    <label for="importFile">CSV File:</label>
+                    <input type="file" id="importFile" accept=".csv,text/csv">
+                </div>
+                <button type="button" id="validateBtn">Validate</button>
+                <button type="button" id="importBtn">Import</button>
             </form>
+            <pre id="importReport"></pre>
         </div>
         <div class="students-table">
             <h2>Students List</h2>
@@ -155,11 +367,47 @@ func getIndexHTML() string {
             </table>
         </div>
     </div>
-    <script>
+    <script nonce="{{nonce}}">
         const API_BASE = '/api/v1';
         let editingId = null;
+        let editingETag = null;
+        
+        let csrfToken = null;
+        
+        document.addEventListener('DOMContentLoaded', async function() {
+            if (!(await loadSession())) return;
+            loadCourses();
+            loadStudents();
+        });
+        
+        // loadSession shows who is signed in, or a sign-in link if nobody is
+        async function loadSession() {
+            try {
+                const response = await fetch('/auth/session');
+                if (response.ok) {
+                    const session = await response.json();
+                    csrfToken = session.csrf_token;
+                    document.getElementById('userName').textContent = session.name || session.subject;
+                    document.getElementById('signOut').hidden = false;
+                    return true;
+                }
+                const signIn = document.getElementById('signIn');
+                signIn.href = '/auth/login?return_to=' + encodeURIComponent(location.pathname);
+                signIn.hidden = false;
+                showMessage(response.status === 404 ? 'Sign-in is not configured' : 'Please sign in', 'error');
+            } catch (error) {
+                showMessage('Network error: ' + error.message, 'error');
+            }
+            return false;
+        }
         
-        document.addEventListener('DOMContentLoaded', loadStudents);
+        // api calls the API with the session's CSRF token, which is required
+        // on requests that change data
+        function api(url, options) {
+            options = options || {};
+            options.headers = Object.assign({}, options.headers, csrfToken ? { 'X-CSRF-Token': csrfToken } : {});
+            return fetch(url, options);
+        }
         
         document.getElementById('studentForm').addEventListener('submit', async (e) => {
             e.preventDefault();
@@ -175,13 +423,13 @@ func getIndexHTML() string {
             try {
                 let response;
                 if (editingId) {
-                    response = await fetch(API_BASE + '/students/' + editingId, {
+                    response = await api(API_BASE + '/students/' + editingId, {
                         method: 'PUT',
-                        headers: { 'Content-Type': 'application/json' },
+                        headers: { 'Content-Type': 'application/json', 'If-Match': editingETag },
                         body: JSON.stringify(student)
                     });
                 } else {
-                    response = await fetch(API_BASE + '/students', {
+                    response = await api(API_BASE + '/students', {
                         method: 'POST',
                         headers: { 'Content-Type': 'application/json' },
                         body: JSON.stringify(student)
@@ -194,19 +442,40 @@ func getIndexHTML() string {
                     loadStudents();
                 } else {
                     const error = await response.json();
-                    showMessage(error.error || 'Operation failed', 'error');
+                    showMessage(error.detail || error.title || 'Operation failed', 'error');
                 }
             } catch (error) {
                 showMessage('Network error: ' + error.message, 'error');
             }
         });
         
+        async function loadCourses() {
+            try {
+                const response = await api(API_BASE + '/courses');
+                if (response.ok) {
+                    const courses = await response.json();
+                    const select = document.getElementById('course');
+                    select.innerHTML = '';
+                    courses.forEach(course => {
+                        const option = document.createElement('option');
+                        option.value = course.code;
+                        option.textContent = course.code + ' - ' + course.title;
+                        select.appendChild(option);
+                    });
+                } else {
+                    showMessage('Failed to load courses', 'error');
+                }
+            } catch (error) {
+                showMessage('Network error: ' + error.message, 'error');
+            }
+        }
+        
         async function loadStudents() {
             try {
-                const response = await fetch(API_BASE + '/students');
+                const response = await api(API_BASE + '/students?limit=200&sort=last_name');
                 if (response.ok) {
-                    const students = await response.json();
-                    displayStudents(students || []);
+                    const page = await response.json();
+                    displayStudents(page.data || []);
                 } else {
                     showMessage('Failed to load students', 'error');
                 }
@@ -227,15 +496,32 @@ func getIndexHTML() string {
                     '<td>' + student.course + '</td>' +
                     '<td>Year ' + student.year_of_study + '</td>' +
                     '<td class="actions">' +
-                        '<button onclick="editStudent(' + student.id + ')">Edit</button>' +
-                        '<button class="btn-danger" onclick="deleteStudent(' + student.id + ')">Delete</button>' +
+                        '<button data-action="edit" data-id="' + student.id + '">Edit</button>' +
+                        '<button class="btn-danger" data-action="delete" data-id="' + student.id + '" data-version="' + student.version + '">Delete</button>' +
                     '</td>';
             });
         }
         
+        document.getElementById('cancelBtn').addEventListener('click', resetForm);
+        document.getElementById('validateBtn').addEventListener('click', function() { importStudents(true); });
+        document.getElementById('importBtn').addEventListener('click', function() { importStudents(false); });
+        
+        // The Edit and Delete buttons of each row are handled here, since
+        // inline event handlers are blocked by the Content-Security-Policy
+        document.getElementById('studentsBody').addEventListener('click', function(e) {
+            const button = e.target.closest('button[data-action]');
+            if (!button) return;
+            const id = parseInt(button.dataset.id);
+            if (button.dataset.action === 'edit') {
+                editStudent(id);
+            } else if (button.dataset.action === 'delete') {
+                deleteStudent(id, parseInt(button.dataset.version));
+            }
+        });
+        
         async function editStudent(id) {
             try {
-                const response = await fetch(API_BASE + '/students/' + id);
+                const response = await api(API_BASE + '/students/' + id);
                 if (response.ok) {
                     const student = await response.json();
                     document.getElementById('studentId').value = student.id;
@@ -246,8 +532,9 @@ func getIndexHTML() string {
                     document.getElementById('course').value = student.course;
                     document.getElementById('yearOfStudy').value = student.year_of_study;
                     editingId = id;
+                    editingETag = response.headers.get('ETag') || student.etag;
                     document.getElementById('submitBtn').textContent = 'Update Student';
-                    document.getElementById('cancelBtn').style.display = 'inline-block';
+                    document.getElementById('cancelBtn').hidden = false;
                 } else {
                     showMessage('Failed to load student details', 'error');
                 }
@@ -256,18 +543,52 @@ func getIndexHTML() string {
             }
         }
         
-        async function deleteStudent(id) {
+        async function deleteStudent(id, version) {
             if (!confirm('Are you sure you want to delete this student?')) return;
             try {
-                const response = await fetch(API_BASE + '/students/' + id, {
-                    method: 'DELETE'
+                const response = await api(API_BASE + '/students/' + id, {
+                    method: 'DELETE',
+                    headers: { 'If-Match': '"' + version + '"' }
                 });
                 if (response.ok) {
                     showMessage('Student deleted successfully!', 'success');
                     loadStudents();
                 } else {
                     const error = await response.json();
-                    showMessage(error.error || 'Delete failed', 'error');
+                    showMessage(error.detail || error.title || 'Delete failed', 'error');
+                }
+            } catch (error) {
+                showMessage('Network error: ' + error.message, 'error');
+            }
+        }
+        
+        async function importStudents(dryRun) {
+            const file = document.getElementById('importFile').files[0];
+            if (!file) {
+                showMessage('Choose a CSV file first', 'error');
+                return;
+            }
+            const data = new FormData();
+            data.append('file', file);
+            try {
+                const response = await api(API_BASE + '/students/import?dry_run=' + dryRun, {
+                    method: 'POST',
+                    body: data
+                });
+                const report = await response.json();
+                if (!report.rows) {
+                    showMessage(report.detail || report.title || 'Import failed', 'error');
+                    return;
+                }
+                const lines = report.rows
+                    .filter(function(row) { return row.errors; })
+                    .map(function(row) { return 'Row ' + row.row + ': ' + row.errors.join('; '); });
+                document.getElementById('importReport').textContent = lines.join('\n');
+                if (response.ok) {
+                    showMessage((dryRun ? 'Valid: ' : 'Imported: ') + report.created + ' to create, ' + report.updated + ' to update', 'success');
+                    if (!dryRun) loadStudents();
+                } else {
+                    showMessage(report.invalid_rows + ' of ' + report.total_rows + ' rows have errors', 'error');
                 }
             } catch (error) {
                 showMessage('Network error: ' + error.message, 'error');
@@ -277,10 +598,10 @@ func getIndexHTML() string {
         function resetForm() {
             document.getElementById('studentForm').reset();
             document.getElementById('studentId').value = '';
-            document.getElementById('course').value = 'Information Technology';
             editingId = null;
+            editingETag = null;
             document.getElementById('submitBtn').textContent = 'Add Student';
-            document.getElementById('cancelBtn').style.display = 'none';
+            document.getElementById('cancelBtn').hidden = true;
         }
         
         function showMessage(text, type) {
@@ -290,5 +611,4 @@ func getIndexHTML() string {
         }
     </script>
 </body>
-</html>`
-}
\ No newline at end of file
+</html>`
\ No newline at end of file
@@ -0,0 +1,545 @@
+package tests
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bournemouth-uni-it-api-go/classification"
+	"github.com/bournemouth-uni-it-api-go/handlers"
+	"github.com/bournemouth-uni-it-api-go/middleware"
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/bournemouth-uni-it-api-go/policy"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockAccessRepository is a mock implementation of AccessRepository
+type MockAccessRepository struct {
+	mock.Mock
+	audits []models.Audit
+}
+
+// WithAudit records the audit details and returns the same mock, so that
+// expectations set on the mock apply to the audited repository
+func (m *MockAccessRepository) WithAudit(a models.Audit) models.AccessRepository {
+	m.audits = append(m.audits, a)
+	return m
+}
+
+func (m *MockAccessRepository) ListRoles() ([]models.Role, error) {
+	args := m.Called()
+	return args.Get(0).([]models.Role), args.Error(1)
+}
+
+func (m *MockAccessRepository) GetRole(name string) (*models.Role, error) {
+	args := m.Called(name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Role), args.Error(1)
+}
+
+func (m *MockAccessRepository) SaveRole(role *models.Role) error {
+	args := m.Called(role)
+	return args.Error(0)
+}
+
+func (m *MockAccessRepository) DeleteRole(name string) error {
+	args := m.Called(name)
+	return args.Error(0)
+}
+
+func (m *MockAccessRepository) ListMembers(role string) ([]string, error) {
+	args := m.Called(role)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockAccessRepository) AddMember(role, subject string) error {
+	args := m.Called(role, subject)
+	return args.Error(0)
+}
+
+func (m *MockAccessRepository) RemoveMember(role, subject string) error {
+	args := m.Called(role, subject)
+	return args.Error(0)
+}
+
+func (m *MockAccessRepository) ListTutees(tutor string) ([]int, error) {
+	args := m.Called(tutor)
+	return args.Get(0).([]int), args.Error(1)
+}
+
+func (m *MockAccessRepository) AssignTutee(tutor string, studentID int) error {
+	args := m.Called(tutor, studentID)
+	return args.Error(0)
+}
+
+func (m *MockAccessRepository) UnassignTutee(tutor string, studentID int) error {
+	args := m.Called(tutor, studentID)
+	return args.Error(0)
+}
+
+func (m *MockAccessRepository) Permissions(subject string, roles []string) ([]string, error) {
+	args := m.Called(subject, roles)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockAccessRepository) IsTutorOf(tutor string, studentID int) (bool, error) {
+	args := m.Called(tutor, studentID)
+	return args.Bool(0), args.Error(1)
+}
+
+// withTestPrincipal is a middleware that authenticates the subject named by
+// the X-Test-Subject header. With X-Test-Scopes it is an API key holding the
+// space separated scopes.
+func withTestPrincipal(c *gin.Context) {
+	if subject := c.GetHeader("X-Test-Subject"); subject != "" {
+		principal := &middleware.Principal{Subject: subject}
+		if scopes := c.GetHeader("X-Test-Scopes"); scopes != "" {
+			principal.Method = middleware.MethodAPIKey
+			principal.Scopes = strings.Fields(scopes)
+		}
+		c.Set(middleware.PrincipalKey, principal)
+	}
+}
+
+// setupPolicyTestRouter returns a router whose student routes are guarded by
+// a policy over a mock access repository
+func setupPolicyTestRouter() (*gin.Engine, *MockStudentRepository, *MockAccessRepository) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(withTestPrincipal)
+	mockRepo := new(MockStudentRepository)
+	mockAccess := new(MockAccessRepository)
+
+	// Create a test handler with the mock repositories
+	handler := &handlers.StudentHandler{
+		Repo:   mockRepo,
+		Policy: policy.New(mockAccess),
+	}
+
+	// Set up routes
+	r.GET("/api/v1/students", handler.GetAllStudents)
+	r.GET("/api/v1/students/:id", handler.GetStudentByID)
+	r.POST("/api/v1/students", handler.CreateStudent)
+	r.PATCH("/api/v1/students/:id", handler.PatchStudent)
+	r.DELETE("/api/v1/students/:id", handler.DeleteStudent)
+	r.GET("/api/v1/students/:id/history", handler.GetStudentHistory)
+
+	// Grants of the test subjects
+	var none []string
+	mockAccess.On("Permissions", "registry-1", none).Return([]string{policy.StudentsDelete, policy.StudentsRead, policy.StudentsWrite}, nil)
+	mockAccess.On("Permissions", "tutor-1", none).Return([]string{policy.StudentsReadTutees}, nil)
+	mockAccess.On("Permissions", "S12345", none).Return([]string{policy.StudentsReadOwn, policy.StudentsWriteOwn}, nil)
+	mockAccess.On("Permissions", "visitor", none).Return([]string{}, nil)
+	mockAccess.On("IsTutorOf", "tutor-1", 1).Return(true, nil)
+	mockAccess.On("IsTutorOf", "tutor-1", 2).Return(false, nil)
+
+	// Student 1 is S12345, student 2 someone else
+	mockRepo.On("GetByID", 1).Return(&models.Student{ID: 1, FirstName: "John", LastName: "Doe", Email: "john@example.com", StudentID: "S12345", Course: "IT", YearOfStudy: 1, Version: 1, ETag: `"1"`}, nil)
+	mockRepo.On("GetByID", 2).Return(&models.Student{ID: 2, FirstName: "Jane", LastName: "Roe", Email: "jane@example.com", StudentID: "S67890", Course: "IT", YearOfStudy: 1, Version: 1, ETag: `"1"`}, nil)
+
+	return r, mockRepo, mockAccess
+}
+
+// callAs sends a request as the given subject. Writes are made against
+// version 1.
+func callAs(r *gin.Engine, subject, method, path, contentType, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if method != "GET" {
+		req.Header.Set("If-Match", `"1"`)
+	}
+	req.Header.Set("X-Test-Subject", subject)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestPolicyReadStudent(t *testing.T) {
+	r, _, _ := setupPolicyTestRouter()
+
+	for _, tc := range []struct {
+		subject string
+		path    string
+		code    int
+		reason  string
+	}{
+		{"registry-1", "/api/v1/students/2", http.StatusOK, ""},
+		{"tutor-1", "/api/v1/students/1", http.StatusOK, ""},
+		{"tutor-1", "/api/v1/students/2", http.StatusForbidden, "Tutors can only read their own tutees"},
+		{"S12345", "/api/v1/students/1", http.StatusOK, ""},
+		{"S12345", "/api/v1/students/2", http.StatusForbidden, "Students can only read their own record"},
+		{"visitor", "/api/v1/students/1", http.StatusForbidden, "Requires permission students:read"},
+	} {
+		w := callAs(r, tc.subject, "GET", tc.path, "", "")
+		assert.Equal(t, tc.code, w.Code, "%s %s", tc.subject, tc.path)
+		if tc.reason != "" {
+			assert.Contains(t, w.Body.String(), tc.reason, "%s %s", tc.subject, tc.path)
+		}
+	}
+}
+
+func TestPolicyListAndWriteRequirePermissions(t *testing.T) {
+	r, mockRepo, _ := setupPolicyTestRouter()
+
+	// Assert only subjects that can read any student may list them
+	w := callAs(r, "tutor-1", "GET", "/api/v1/students", "", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "Requires permission students:read")
+
+	// Assert students cannot create or delete
+	w = callAs(r, "S12345", "POST", "/api/v1/students", "application/json", `{}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "students:write")
+
+	w = callAs(r, "S12345", "DELETE", "/api/v1/students/1", "", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "students:delete")
+
+	mockRepo.AssertNotCalled(t, "List", mock.Anything)
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything)
+	mockRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+}
+
+func TestPolicyIncludeDeletedRequiresDelete(t *testing.T) {
+	r, mockRepo, mockAccess := setupPolicyTestRouter()
+
+	// Set expectations
+	var none []string
+	mockAccess.On("Permissions", "examiner-1", none).Return([]string{policy.StudentsRead}, nil)
+	mockRepo.On("List", mock.MatchedBy(func(opts models.StudentListOptions) bool {
+		return opts.Filter.IncludeDeleted
+	})).Return(&models.StudentPage{Students: []models.Student{}}, nil)
+
+	// Assert only callers who can delete students see deleted ones
+	w := callAs(r, "examiner-1", "GET", "/api/v1/students?include_deleted=true", "", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "Requires permission students:delete")
+
+	w = callAs(r, "registry-1", "GET", "/api/v1/students?include_deleted=true", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockRepo.AssertNumberOfCalls(t, "List", 1)
+}
+
+func TestPolicyStudentPatchesOwnRecord(t *testing.T) {
+	r, mockRepo, _ := setupPolicyTestRouter()
+
+	// Set expectations
+	mockRepo.On("UpdateFields", 1, 1, map[string]interface{}{"first_name": "Johnny"}).Return(&models.Student{ID: 1, FirstName: "Johnny", Version: 2, ETag: `"2"`}, nil)
+
+	// Assert a student can change their name
+	w := callAs(r, "S12345", "PATCH", "/api/v1/students/1", "application/merge-patch+json", `{"first_name": "Johnny"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Assert a student cannot change other fields
+	w = callAs(r, "S12345", "PATCH", "/api/v1/students/1", "application/merge-patch+json", `{"first_name": "Johnny", "year_of_study": 4}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "Students cannot change year_of_study")
+
+	// Assert a student cannot change someone else
+	w = callAs(r, "S12345", "PATCH", "/api/v1/students/2", "application/merge-patch+json", `{"first_name": "Janet"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Assert a tutor cannot edit a tutee
+	w = callAs(r, "tutor-1", "PATCH", "/api/v1/students/1", "application/merge-patch+json", `{"first_name": "Johnny"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	mockRepo.AssertNumberOfCalls(t, "UpdateFields", 1)
+}
+
+func TestPolicyPatchRevealsNothingToOthers(t *testing.T) {
+	r, mockRepo, _ := setupPolicyTestRouter()
+
+	// Set expectations
+	mockRepo.On("GetByID", 3).Return(nil, nil)
+
+	// Assert a test operation cannot tell whether another student's value
+	// matches, and a stale version does not reveal the current one
+	for _, value := range []string{"Jane", "Janet"} {
+		w := callAs(r, "S12345", "PATCH", "/api/v1/students/2", "application/json-patch+json", `[{"op": "test", "path": "/first_name", "value": "`+value+`"}]`)
+		assert.Equal(t, http.StatusForbidden, w.Code, value)
+	}
+	req, _ := http.NewRequest("PATCH", "/api/v1/students/2", strings.NewReader(`{"first_name": "Janet"}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req.Header.Set("If-Match", `"7"`)
+	req.Header.Set("X-Test-Subject", "S12345")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, w.Header().Get("ETag"))
+
+	// Assert a missing student looks the same as someone else's
+	w = callAs(r, "S12345", "PATCH", "/api/v1/students/3", "application/merge-patch+json", `{"first_name": "Janet"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Assert callers without a write permission are refused before any lookup
+	w = callAs(r, "tutor-1", "PATCH", "/api/v1/students/4", "application/merge-patch+json", `{"first_name": "Janet"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "Requires permission students:write or students:write:own")
+	mockRepo.AssertNotCalled(t, "GetByID", 4)
+	mockRepo.AssertNotCalled(t, "UpdateFields", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPolicyStudentHistory(t *testing.T) {
+	r, mockRepo, _ := setupPolicyTestRouter()
+
+	// Set expectations
+	mockRepo.On("GetByID", 3).Return(nil, nil)
+	mockRepo.On("History", 1).Return([]models.StudentHistoryEntry{}, nil)
+	mockRepo.On("History", 3).Return([]models.StudentHistoryEntry{}, nil)
+
+	w := callAs(r, "S12345", "GET", "/api/v1/students/1/history", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Assert a deleted student's history needs students:read
+	w = callAs(r, "tutor-1", "GET", "/api/v1/students/3/history", "", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = callAs(r, "registry-1", "GET", "/api/v1/students/3/history", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestPolicyMergesTokenRoles(t *testing.T) {
+	mockAccess := new(MockAccessRepository)
+	p := policy.New(mockAccess)
+
+	mockAccess.On("Permissions", "staff-1", []string{"registry"}).Return([]string{policy.StudentsRead}, nil)
+
+	decision, err := p.Require(policy.Subject{ID: "staff-1", Roles: []string{"registry"}}, policy.StudentsRead)
+	assert.NoError(t, err)
+	assert.True(t, decision.Allowed)
+
+	decision, err = p.Require(policy.Subject{ID: "staff-1", Roles: []string{"registry"}}, policy.RolesManage)
+	assert.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, "Requires permission roles:manage", decision.Reason)
+}
+
+func TestPolicyRequirePermissionLogsWithRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.RequestID(), withTestPrincipal)
+	mockAccess := new(MockAccessRepository)
+	r.GET("/api/v1/admin/roles", handlers.RequirePermission(policy.New(mockAccess), policy.RolesManage), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	var none []string
+	mockAccess.On("Permissions", "admin-1", none).Return([]string{policy.RolesManage}, nil)
+
+	// Create request with a request ID
+	req, _ := http.NewRequest("GET", "/api/v1/admin/roles", nil)
+	req.Header.Set("X-Test-Subject", "admin-1")
+	req.Header.Set(middleware.RequestIDHeader, "req-42")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert permissions were looked up by a store bound to the request
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []models.Audit{{Actor: "admin-1", RequestID: "req-42"}}, mockAccess.audits)
+}
+
+func setupAccessTestRouter() (*gin.Engine, *MockAccessRepository) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(withTestPrincipal)
+	mockRepo := new(MockAccessRepository)
+
+	// Create a test handler with the mock repository
+	handler := &handlers.AccessHandler{
+		Repo: mockRepo,
+	}
+
+	// Set up routes
+	admin := r.Group("/api/v1/admin")
+	admin.Use(handlers.RequirePermission(policy.New(mockRepo), policy.RolesManage))
+	admin.GET("/roles", handler.GetRoles)
+	admin.PUT("/roles/:name", handler.PutRole)
+	admin.DELETE("/roles/:name", handler.DeleteRole)
+	admin.PUT("/roles/:name/members/:subject", handler.AddRoleMember)
+	admin.PUT("/tutors/:subject/tutees/:id", handler.AssignTutee)
+
+	var none []string
+	mockRepo.On("Permissions", "admin-1", none).Return([]string{policy.RolesManage}, nil)
+	mockRepo.On("Permissions", "registry-1", none).Return([]string{policy.StudentsRead, policy.StudentsWrite}, nil)
+
+	return r, mockRepo
+}
+
+func TestAdminRoutesRequireRolesManage(t *testing.T) {
+	r, mockRepo := setupAccessTestRouter()
+
+	mockRepo.On("ListRoles").Return([]models.Role{{Name: "admin", Permissions: []string{policy.RolesManage}}}, nil)
+
+	w := callAs(r, "registry-1", "GET", "/api/v1/admin/roles", "", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "Requires permission roles:manage")
+
+	w = callAs(r, "admin-1", "GET", "/api/v1/admin/roles", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockRepo.AssertNumberOfCalls(t, "ListRoles", 1)
+}
+
+func TestPutRole(t *testing.T) {
+	r, mockRepo := setupAccessTestRouter()
+
+	// Set expectations
+	mockRepo.On("SaveRole", &models.Role{Name: "examiner", Description: "External examiners", Permissions: []string{"students:read"}}).Return(nil)
+
+	// Create request
+	body, _ := json.Marshal(gin.H{"description": "External examiners", "permissions": []string{"students:read"}})
+	w := callAs(r, "admin-1", "PUT", "/api/v1/admin/roles/Examiner", "application/json", string(body))
+
+	// Assert response
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Assert unknown permissions and locking out the admin role are rejected
+	w = callAs(r, "admin-1", "PUT", "/api/v1/admin/roles/examiner", "application/json", `{"permissions": ["students:fly"]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	w = callAs(r, "admin-1", "PUT", "/api/v1/admin/roles/admin", "application/json", `{"permissions": ["students:read"]}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = callAs(r, "admin-1", "PUT", "/api/v1/admin/roles/bad%20name", "application/json", `{"permissions": []}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = callAs(r, "admin-1", "DELETE", "/api/v1/admin/roles/admin", "", "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	mockRepo.AssertNumberOfCalls(t, "SaveRole", 1)
+}
+
+func TestDeleteRoleNotFound(t *testing.T) {
+	r, mockRepo := setupAccessTestRouter()
+
+	mockRepo.On("DeleteRole", "examiner").Return(sql.ErrNoRows)
+
+	w := callAs(r, "admin-1", "DELETE", "/api/v1/admin/roles/examiner", "", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAddRoleMemberAndAssignTutee(t *testing.T) {
+	r, mockRepo := setupAccessTestRouter()
+
+	// Set expectations
+	mockRepo.On("GetRole", "tutor").Return(&models.Role{Name: "tutor"}, nil)
+	mockRepo.On("AddMember", "tutor", "staff-2002").Return(nil)
+	mockRepo.On("AssignTutee", "staff-2002", 1).Return(nil)
+
+	w := callAs(r, "admin-1", "PUT", "/api/v1/admin/roles/tutor/members/staff-2002", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = callAs(r, "admin-1", "PUT", "/api/v1/admin/tutors/staff-2002/tutees/1", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(bytes.TrimSpace(w.Body.Bytes()), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(1), response["student_id"])
+	mockRepo.AssertCalled(t, "AddMember", "tutor", "staff-2002")
+	mockRepo.AssertCalled(t, "AssignTutee", "staff-2002", 1)
+}
+
+func TestPolicyStudentRecordRoutes(t *testing.T) {
+	r, students, mockAccess := setupPolicyTestRouter()
+	results := new(MockAssessmentRepository)
+	p := policy.New(mockAccess)
+
+	// Set up routes as the router guards them
+	classificationHandler := &handlers.ClassificationHandler{Students: students, Results: results, Rules: classification.DefaultRules(), Policy: p}
+	transcriptHandler := &handlers.TranscriptHandler{Students: students, Results: results, Rules: classification.DefaultRules(), Policy: p}
+	assessmentHandler := &handlers.AssessmentHandler{Repo: results, Students: students, Policy: p}
+	notReached := func(c *gin.Context) { c.Status(http.StatusTeapot) }
+	r.GET("/api/v1/students/:id/classification", classificationHandler.GetStudentClassification)
+	r.GET("/api/v1/students/:id/transcript.pdf", transcriptHandler.GetStudentTranscript)
+	r.GET("/api/v1/students/:id/marks", assessmentHandler.GetStudentMarks)
+	r.POST("/api/v1/students/:id/transitions", handlers.RequirePermission(p, policy.StudentsWrite), notReached)
+	r.POST("/api/v1/students/:id/enrolments", handlers.RequirePermission(p, policy.EnrolmentsWrite), notReached)
+	r.DELETE("/api/v1/students/:id/enrolments/:code", handlers.RequirePermission(p, policy.EnrolmentsWrite), notReached)
+	r.POST("/api/v1/courses", handlers.RequirePermission(p, policy.CoursesWrite), notReached)
+	r.DELETE("/api/v1/modules/:code", handlers.RequirePermission(p, policy.ModulesWrite), notReached)
+	r.POST("/api/v1/assessments/:id/marks", handlers.RequirePermission(p, policy.MarksWrite), notReached)
+	r.POST("/api/v1/marks/:id/moderate", handlers.RequirePermission(p, policy.MarksModerate), notReached)
+	r.POST("/api/v1/marks/:id/publish", handlers.RequirePermission(p, policy.MarksPublish), notReached)
+
+	var none []string
+	mockAccess.On("Permissions", "api-key:timetabling", none).Return([]string{}, nil)
+	results.On("StudentResults", 1, "").Return([]models.ModuleResult{}, nil)
+
+	// Assert a student session and a read-only API key cannot change records
+	for _, tc := range []struct {
+		method string
+		path   string
+		reason string
+	}{
+		{"POST", "/api/v1/students/1/transitions", "students:write"},
+		{"POST", "/api/v1/students/1/enrolments", "enrolments:write"},
+		{"DELETE", "/api/v1/students/1/enrolments/CS101", "enrolments:write"},
+		{"POST", "/api/v1/courses", "courses:write"},
+		{"DELETE", "/api/v1/modules/CS101", "modules:write"},
+		{"POST", "/api/v1/assessments/1/marks", "marks:write"},
+		{"POST", "/api/v1/marks/1/moderate", "marks:moderate"},
+		{"POST", "/api/v1/marks/1/publish", "marks:publish"},
+	} {
+		w := callAs(r, "S12345", tc.method, tc.path, "application/json", `{}`)
+		assert.Equal(t, http.StatusForbidden, w.Code, "%s %s", tc.method, tc.path)
+		assert.Contains(t, w.Body.String(), "Requires permission "+tc.reason, "%s %s", tc.method, tc.path)
+
+		req, _ := http.NewRequest(tc.method, tc.path, strings.NewReader(`{}`))
+		req.Header.Set("X-Test-Subject", "api-key:timetabling")
+		req.Header.Set("X-Test-Scopes", policy.StudentsRead)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code, "api key %s %s", tc.method, tc.path)
+	}
+
+	// Assert a student reads only their own classification and transcript
+	w := callAs(r, "S12345", "GET", "/api/v1/students/2/classification", "", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "Students can only read their own record")
+	w = callAs(r, "S12345", "GET", "/api/v1/students/2/transcript.pdf", "", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = callAs(r, "tutor-1", "GET", "/api/v1/students/2/transcript.pdf", "", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = callAs(r, "S12345", "GET", "/api/v1/students/2/marks", "", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "Students can only read their own record")
+	w = callAs(r, "tutor-1", "GET", "/api/v1/students/2/marks", "", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = callAs(r, "S12345", "GET", "/api/v1/students/1/classification", "", "")
+	assert.NotEqual(t, http.StatusForbidden, w.Code)
+
+	results.AssertNumberOfCalls(t, "StudentResults", 1)
+}
+
+func TestPolicyStudentReadsOwnMarks(t *testing.T) {
+	r, students, mockAccess := setupPolicyTestRouter()
+	results := new(MockAssessmentRepository)
+	handler := &handlers.AssessmentHandler{Repo: results, Students: students, Policy: policy.New(mockAccess)}
+	r.GET("/api/v1/students/:id/marks", handler.GetStudentMarks)
+
+	// Set expectations: only published marks come back from the repository
+	published := models.ModuleResult{ModuleCode: "CS101", Level: 4, Credits: 20, AcademicYear: "2024/25",
+		Components: []models.ComponentResult{{Name: "Exam", Weighting: 100, MaxMark: 100, Mark: markPtr(64)}}}
+	published.Calculate()
+	results.On("StudentResults", 1, "").Return([]models.ModuleResult{published}, nil)
+
+	// Assert the student and their tutor can read the student's marks
+	for _, subject := range []string{"S12345", "tutor-1"} {
+		w := callAs(r, subject, "GET", "/api/v1/students/1/marks", "", "")
+		assert.Equal(t, http.StatusOK, w.Code, subject)
+
+		var response []models.ModuleResult
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		require.Len(t, response, 1)
+		assert.Equal(t, 64.0, *response[0].Total)
+	}
+	results.AssertNumberOfCalls(t, "StudentResults", 2)
+}
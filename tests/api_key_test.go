@@ -0,0 +1,292 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bournemouth-uni-it-api-go/handlers"
+	"github.com/bournemouth-uni-it-api-go/middleware"
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/bournemouth-uni-it-api-go/policy"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockAPIKeyRepository is a mock implementation of APIKeyRepository
+type MockAPIKeyRepository struct {
+	mock.Mock
+}
+
+// WithAudit returns the same mock, so that expectations set on the mock apply
+// to the audited repository
+func (m *MockAPIKeyRepository) WithAudit(a models.Audit) models.APIKeyRepository {
+	return m
+}
+
+func (m *MockAPIKeyRepository) List() ([]models.APIKey, error) {
+	args := m.Called()
+	return args.Get(0).([]models.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) GetByID(id int) (*models.APIKey, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) Create(key *models.APIKey) (string, error) {
+	args := m.Called(key)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) Rotate(id int) (*models.APIKey, string, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, "", args.Error(2)
+	}
+	return args.Get(0).(*models.APIKey), args.String(1), args.Error(2)
+}
+
+func (m *MockAPIKeyRepository) Revoke(id int) (*models.APIKey, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) Verify(key string) (*models.APIKey, error) {
+	args := m.Called(key)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.APIKey), args.Error(1)
+}
+
+func setupAPIKeyTestRouter() (*gin.Engine, *MockAPIKeyRepository) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(withTestPrincipal)
+	mockRepo := new(MockAPIKeyRepository)
+	mockAccess := new(MockAccessRepository)
+
+	// Create a test handler with the mock repositories
+	handler := &handlers.APIKeyHandler{
+		Repo:   mockRepo,
+		Policy: policy.New(mockAccess),
+	}
+
+	// admin-1 may issue keys that read students, but not manage roles
+	var none []string
+	mockAccess.On("Permissions", "admin-1", none).Return([]string{policy.APIKeysManage, policy.StudentsRead}, nil)
+
+	// Set up routes
+	r.GET("/api/v1/admin/api-keys", handler.GetAPIKeys)
+	r.GET("/api/v1/admin/api-keys/:id", handler.GetAPIKey)
+	r.POST("/api/v1/admin/api-keys", handler.CreateAPIKey)
+	r.POST("/api/v1/admin/api-keys/:id/rotate", handler.RotateAPIKey)
+	r.DELETE("/api/v1/admin/api-keys/:id", handler.RevokeAPIKey)
+
+	return r, mockRepo
+}
+
+func TestGenerateAPIKey(t *testing.T) {
+	key, prefix, secretHash, err := models.GenerateAPIKey()
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(key, "bu_"+prefix+"_"))
+	assert.Len(t, prefix, 12)
+	assert.Len(t, secretHash, 64)
+	assert.NotContains(t, key, secretHash)
+
+	other, _, _, err := models.GenerateAPIKey()
+	require.NoError(t, err)
+	assert.NotEqual(t, key, other)
+}
+
+func TestCreateAPIKey(t *testing.T) {
+	r, mockRepo := setupAPIKeyTestRouter()
+
+	// Set expectations
+	expires := time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC)
+	mockRepo.On("Create", mock.MatchedBy(func(k *models.APIKey) bool {
+		return k.Name == "timetabling" && assert.ObjectsAreEqual([]string{"students:read"}, k.Scopes) &&
+			k.ExpiresAt.Equal(expires) && k.CreatedBy == "admin-1"
+	})).Run(func(args mock.Arguments) {
+		k := args.Get(0).(*models.APIKey)
+		k.ID = 1
+		k.Prefix = "0a1b2c3d4e5f"
+	}).Return("bu_0a1b2c3d4e5f_secret", nil)
+
+	// Create request
+	body, _ := json.Marshal(gin.H{"name": "timetabling", "scopes": []string{"students:read", "students:read"}, "expires_at": expires})
+	w := callAs(r, "admin-1", "POST", "/api/v1/admin/api-keys", "application/json", string(body))
+
+	// Assert response
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "bu_0a1b2c3d4e5f_secret", response["key"])
+	assert.Equal(t, "0a1b2c3d4e5f", response["api_key"].(map[string]interface{})["prefix"])
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateAPIKeyValidation(t *testing.T) {
+	r, mockRepo := setupAPIKeyTestRouter()
+
+	mockRepo.On("Create", mock.Anything).Return("", &pq.Error{Code: "23505", Message: "duplicate key value violates unique constraint \"api_keys_name_key\""})
+
+	past := time.Now().Add(-time.Hour)
+	for _, tc := range []struct {
+		body gin.H
+		code int
+	}{
+		{gin.H{"scopes": []string{"students:read"}}, http.StatusBadRequest},
+		{gin.H{"name": "library", "scopes": []string{}}, http.StatusBadRequest},
+		{gin.H{"name": "library", "scopes": []string{"students:everything"}}, http.StatusUnprocessableEntity},
+		{gin.H{"name": "library", "scopes": []string{"students:read"}, "expires_at": past}, http.StatusUnprocessableEntity},
+		{gin.H{"name": "library", "scopes": []string{"students:read"}}, http.StatusConflict},
+	} {
+		body, _ := json.Marshal(tc.body)
+		w := callAs(r, "admin-1", "POST", "/api/v1/admin/api-keys", "application/json", string(body))
+		assert.Equal(t, tc.code, w.Code, "body %v", tc.body)
+	}
+
+	mockRepo.AssertNumberOfCalls(t, "Create", 1)
+}
+
+func TestCreateAPIKeyLimitedToHeldScopes(t *testing.T) {
+	r, mockRepo := setupAPIKeyTestRouter()
+
+	// Assert a key cannot be given permissions its issuer lacks
+	body, _ := json.Marshal(gin.H{"name": "escalate", "scopes": []string{"students:read", "roles:manage", "students:write"}})
+	w := callAs(r, "admin-1", "POST", "/api/v1/admin/api-keys", "application/json", string(body))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	p := decodeProblem(t, w)
+	assert.Equal(t, "api_key.scope_not_held", p["code"])
+	assert.Equal(t, []interface{}{"roles:manage", "students:write"}, p["scopes"])
+
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything)
+}
+
+func TestRotateAndRevokeAPIKey(t *testing.T) {
+	r, mockRepo := setupAPIKeyTestRouter()
+
+	// Set expectations
+	revokedAt := time.Now()
+	mockRepo.On("Rotate", 1).Return(&models.APIKey{ID: 1, Name: "timetabling", Prefix: "ffeeddccbbaa"}, "bu_ffeeddccbbaa_new", nil)
+	mockRepo.On("Rotate", 2).Return(nil, "", nil)
+	mockRepo.On("GetByID", 2).Return(&models.APIKey{ID: 2, Name: "library", RevokedAt: &revokedAt}, nil)
+	mockRepo.On("Rotate", 3).Return(nil, "", nil)
+	mockRepo.On("GetByID", 3).Return(nil, nil)
+	mockRepo.On("Revoke", 1).Return(&models.APIKey{ID: 1, Name: "timetabling", RevokedAt: &revokedAt}, nil)
+	mockRepo.On("Revoke", 3).Return(nil, nil)
+
+	w := callAs(r, "admin-1", "POST", "/api/v1/admin/api-keys/1/rotate", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "bu_ffeeddccbbaa_new")
+
+	// Assert a revoked key cannot be rotated
+	w = callAs(r, "admin-1", "POST", "/api/v1/admin/api-keys/2/rotate", "", "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = callAs(r, "admin-1", "POST", "/api/v1/admin/api-keys/3/rotate", "", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = callAs(r, "admin-1", "DELETE", "/api/v1/admin/api-keys/1", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "revoked_at")
+	w = callAs(r, "admin-1", "DELETE", "/api/v1/admin/api-keys/3", "", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	authenticator, err := middleware.NewJWTAuthenticator(middleware.JWTOptions{HMACSecret: testHMACSecret})
+	require.NoError(t, err)
+	keys := new(MockAPIKeyRepository)
+
+	r := gin.New()
+	r.Use(middleware.Authenticate(authenticator, keys, nil))
+	r.GET("/api/v1/whoami", func(c *gin.Context) {
+		principal, _ := middleware.GetPrincipal(c)
+		c.JSON(http.StatusOK, principal)
+	})
+
+	// Set expectations
+	keys.On("Verify", "bu_0a1b2c3d4e5f_secret").Return(&models.APIKey{ID: 1, Name: "timetabling", Scopes: []string{"students:read"}}, nil)
+	keys.On("Verify", "bu_0a1b2c3d4e5f_old").Return(nil, models.ErrInvalidAPIKey)
+	keys.On("Verify", "bu_expired_secret").Return(nil, models.ErrAPIKeyExpired)
+	keys.On("Verify", "bu_revoked_secret").Return(nil, models.ErrAPIKeyRevoked)
+	keys.On("Verify", "bu_broken_secret").Return(nil, errors.New("connection refused"))
+
+	// Assert a valid key authenticates with its scopes
+	w := callWithToken(r, "ApiKey bu_0a1b2c3d4e5f_secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var principal middleware.Principal
+	err = json.Unmarshal(w.Body.Bytes(), &principal)
+	assert.NoError(t, err)
+	assert.Equal(t, "api-key:timetabling", principal.Subject)
+	assert.Equal(t, []string{"students:read"}, principal.Scopes)
+	assert.Equal(t, middleware.MethodAPIKey, principal.Method)
+
+	// Assert bearer tokens are still accepted
+	w = callWithToken(r, "Bearer "+signToken(t, jwt.SigningMethodHS256, []byte(testHMACSecret), "", validClaims()))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	for authorization, message := range map[string]string{
+		"ApiKey bu_0a1b2c3d4e5f_old": "Invalid API key",
+		"ApiKey bu_expired_secret":   "API key has expired",
+		"ApiKey bu_revoked_secret":   "API key has been revoked",
+		"ApiKey ":                    "Authentication required",
+	} {
+		w := callWithToken(r, authorization)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, authorization)
+		assert.Contains(t, w.Body.String(), message, authorization)
+		assert.Contains(t, w.Header().Values("WWW-Authenticate"), `ApiKey realm="api"`, authorization)
+	}
+
+	w = callWithToken(r, "ApiKey bu_broken_secret")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestAPIKeyScopesGrantPermissions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockAccess := new(MockAccessRepository)
+	var none []string
+	mockAccess.On("Permissions", "api-key:timetabling", none).Return([]string{}, nil)
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set(middleware.PrincipalKey, &middleware.Principal{Subject: "api-key:timetabling", Scopes: []string{"students:read"}, Method: middleware.MethodAPIKey})
+	})
+	r.GET("/read", handlers.RequirePermission(policy.New(mockAccess), policy.StudentsRead), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	r.GET("/write", handlers.RequirePermission(policy.New(mockAccess), policy.StudentsWrite), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	req, _ := http.NewRequest("GET", "/read", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	req, _ = http.NewRequest("GET", "/write", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
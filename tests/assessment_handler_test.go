@@ -0,0 +1,303 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bournemouth-uni-it-api-go/handlers"
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockAssessmentRepository is a mock implementation of AssessmentRepository
+type MockAssessmentRepository struct {
+	mock.Mock
+}
+
+// WithAudit returns the same mock, so that expectations set on the mock apply
+// to the audited repository
+func (m *MockAssessmentRepository) WithAudit(a models.Audit) models.AssessmentRepository {
+	return m
+}
+
+func (m *MockAssessmentRepository) ListAssessments(moduleCode string) ([]models.Assessment, error) {
+	args := m.Called(moduleCode)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Assessment), args.Error(1)
+}
+
+func (m *MockAssessmentRepository) GetAssessment(id int) (*models.Assessment, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Assessment), args.Error(1)
+}
+
+func (m *MockAssessmentRepository) CreateAssessment(assessment *models.Assessment) error {
+	args := m.Called(assessment)
+	return args.Error(0)
+}
+
+func (m *MockAssessmentRepository) SubmitMark(assessmentID, studentID int, academicYear string, mark float64) (*models.Mark, error) {
+	args := m.Called(assessmentID, studentID, academicYear, mark)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Mark), args.Error(1)
+}
+
+func (m *MockAssessmentRepository) ModerateMark(id int, mark *float64, note string) (*models.Mark, error) {
+	args := m.Called(id, mark, note)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Mark), args.Error(1)
+}
+
+func (m *MockAssessmentRepository) PublishMark(id int) (*models.Mark, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Mark), args.Error(1)
+}
+
+func (m *MockAssessmentRepository) PublishAssessment(assessmentID int) (int, error) {
+	args := m.Called(assessmentID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockAssessmentRepository) StudentResults(studentID int, academicYear string) ([]models.ModuleResult, error) {
+	args := m.Called(studentID, academicYear)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.ModuleResult), args.Error(1)
+}
+
+func setupAssessmentTestRouter() (*gin.Engine, *MockAssessmentRepository) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	mockRepo := new(MockAssessmentRepository)
+	students := new(MockStudentRepository)
+
+	// Create a test handler with the mock repositories
+	handler := &handlers.AssessmentHandler{
+		Repo:     mockRepo,
+		Students: students,
+	}
+
+	// Student 1 exists and student 99 does not
+	students.On("GetByID", 1).Return(&models.Student{ID: 1, StudentID: "S12345"}, nil)
+	students.On("GetByID", 99).Return(nil, nil)
+
+	// Set up routes
+	r.GET("/api/v1/modules/:code/assessments", handler.GetModuleAssessments)
+	r.POST("/api/v1/modules/:code/assessments", handler.CreateAssessment)
+	r.POST("/api/v1/assessments/:id/marks", handler.SubmitMark)
+	r.POST("/api/v1/assessments/:id/publish", handler.PublishAssessment)
+	r.POST("/api/v1/marks/:id/moderate", handler.ModerateMark)
+	r.POST("/api/v1/marks/:id/publish", handler.PublishMark)
+	r.GET("/api/v1/students/:id/marks", handler.GetStudentMarks)
+
+	return r, mockRepo
+}
+
+func markPtr(v float64) *float64 {
+	return &v
+}
+
+func TestModuleResultCalculate(t *testing.T) {
+	// Coursework 30% marked 45/60 (75%), exam 70% marked 58/100
+	result := models.ModuleResult{
+		Components: []models.ComponentResult{
+			{Name: "Coursework", Weighting: 30, MaxMark: 60, Mark: markPtr(45)},
+			{Name: "Exam", Weighting: 70, MaxMark: 100},
+		},
+	}
+
+	// Only the coursework is published
+	result.Calculate()
+	assert.Equal(t, 75.0, *result.Components[0].Percentage)
+	assert.Equal(t, 22.5, *result.Components[0].Weighted)
+	assert.Nil(t, result.Components[1].Weighted)
+	assert.Equal(t, 75.0, *result.ProvisionalTotal)
+	assert.Nil(t, result.Total)
+	assert.False(t, result.Complete)
+
+	// Both components published
+	result.Components[1].Mark = markPtr(58)
+	result.Calculate()
+	assert.Equal(t, 40.6, *result.Components[1].Weighted)
+	assert.Equal(t, 63.1, *result.Total)
+	assert.True(t, result.Complete)
+
+	// Weightings that do not add up to 100 never complete
+	result.Components = result.Components[:1]
+	result.Calculate()
+	assert.Nil(t, result.Total)
+	assert.False(t, result.Complete)
+}
+
+func TestCreateAssessment(t *testing.T) {
+	r, mockRepo := setupAssessmentTestRouter()
+
+	// Set expectations
+	mockRepo.On("CreateAssessment", mock.MatchedBy(func(a *models.Assessment) bool {
+		return a.ModuleCode == "CS101" && a.Weighting == 30
+	})).Return(nil)
+
+	// Create request
+	body := `{"name": "Portfolio", "kind": "coursework", "weighting": 30, "max_mark": 100, "due_date": "2025-01-15"}`
+	req, _ := http.NewRequest("POST", "/api/v1/modules/cs101/assessments", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusCreated, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateAssessmentValidation(t *testing.T) {
+	r, mockRepo := setupAssessmentTestRouter()
+
+	// Set expectations
+	mockRepo.On("CreateAssessment", mock.Anything).Return(models.ErrWeightingExceeded)
+
+	// Invalid due date
+	body := `{"name": "Exam", "kind": "exam", "weighting": 70, "max_mark": 100, "due_date": "15/01/2025"}`
+	req, _ := http.NewRequest("POST", "/api/v1/modules/CS101/assessments", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Weightings over 100
+	body = `{"name": "Exam", "kind": "exam", "weighting": 80, "max_mark": 100, "due_date": "2025-05-20"}`
+	req, _ = http.NewRequest("POST", "/api/v1/modules/CS101/assessments", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+func TestSubmitMark(t *testing.T) {
+	r, mockRepo := setupAssessmentTestRouter()
+
+	// Set expectations
+	mockRepo.On("SubmitMark", 4, 1, "2024/25", 62.5).Return(&models.Mark{ID: 9, AssessmentID: 4, Mark: 62.5, Status: models.MarkSubmitted}, nil)
+	mockRepo.On("SubmitMark", 4, 2, "2024/25", 70.0).Return(nil, models.ErrMarkPublished)
+	mockRepo.On("SubmitMark", 4, 3, "2024/25", 0.0).Return(nil, models.ErrNotEnrolled)
+
+	tests := []struct {
+		body     string
+		expected int
+	}{
+		{`{"student_id": 1, "academic_year": "2024/25", "mark": 62.5}`, http.StatusOK},
+		{`{"student_id": 2, "academic_year": "2024/25", "mark": 70}`, http.StatusConflict},
+		{`{"student_id": 3, "academic_year": "2024/25", "mark": 0}`, http.StatusUnprocessableEntity},
+		{`{"student_id": 1, "academic_year": "2024/25"}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("POST", "/api/v1/assessments/4/marks", bytes.NewBufferString(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, tt.expected, w.Code, tt.body)
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+func TestModerateAndPublishMark(t *testing.T) {
+	r, mockRepo := setupAssessmentTestRouter()
+
+	// Set expectations
+	mockRepo.On("ModerateMark", 9, markPtr(65), "Raised after second marking").Return(&models.Mark{ID: 9, Mark: 65, OriginalMark: markPtr(62.5), Status: models.MarkModerated}, nil)
+	mockRepo.On("PublishMark", 9).Return(&models.Mark{ID: 9, Mark: 65, Status: models.MarkPublished}, nil)
+	mockRepo.On("PublishMark", 10).Return(nil, nil)
+
+	// Moderate
+	req, _ := http.NewRequest("POST", "/api/v1/marks/9/moderate", bytes.NewBufferString(`{"mark": 65, "note": "Raised after second marking"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var mark models.Mark
+	err := json.Unmarshal(w.Body.Bytes(), &mark)
+	assert.NoError(t, err)
+	assert.Equal(t, models.MarkModerated, mark.Status)
+	assert.Equal(t, 62.5, *mark.OriginalMark)
+
+	// Publish
+	req, _ = http.NewRequest("POST", "/api/v1/marks/9/publish", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"published"`)
+
+	// Unknown mark
+	req, _ = http.NewRequest("POST", "/api/v1/marks/10/publish", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "StudentResults", 99, "")
+}
+
+func TestGetStudentMarks(t *testing.T) {
+	r, mockRepo := setupAssessmentTestRouter()
+
+	// Mock data
+	result := models.ModuleResult{
+		EnrolmentID:  3,
+		ModuleCode:   "CS101",
+		AcademicYear: "2024/25",
+		Components: []models.ComponentResult{
+			{Name: "Coursework", Weighting: 40, MaxMark: 100, Mark: markPtr(70)},
+			{Name: "Exam", Weighting: 60, MaxMark: 100, Mark: markPtr(55)},
+		},
+	}
+	result.Calculate()
+
+	// Set expectations
+	mockRepo.On("StudentResults", 1, "2024/25").Return([]models.ModuleResult{result}, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/api/v1/students/1/marks?academic_year=2024/25", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response []models.ModuleResult
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Len(t, response, 1)
+	assert.Equal(t, 61.0, *response[0].Total)
+
+	// Unknown student
+	req, _ = http.NewRequest("GET", "/api/v1/students/99/marks", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "StudentResults", 99, "")
+}
@@ -0,0 +1,194 @@
+package tests
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bournemouth-uni-it-api-go/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testHMACSecret = "test-secret-that-is-long-enough"
+
+// setupAuthTestRouter returns a router whose only route echoes the
+// authenticated principal
+func setupAuthTestRouter(t *testing.T, opts middleware.JWTOptions) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	authenticator, err := middleware.NewJWTAuthenticator(opts)
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.Use(middleware.Authenticate(authenticator, nil, nil))
+	r.GET("/api/v1/whoami", func(c *gin.Context) {
+		principal, _ := middleware.GetPrincipal(c)
+		c.JSON(http.StatusOK, principal)
+	})
+	return r
+}
+
+// signToken signs claims with the given method and key, setting kid if given
+func signToken(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+// validClaims returns the claims of a token that expires in an hour
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":   "staff-1001",
+		"name":  "Jane Registry",
+		"roles": []string{"staff"},
+		"scope": "students:read students:write",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+// callWithToken calls the test route with an Authorization header, if given
+func callWithToken(r *gin.Engine, authorization string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", "/api/v1/whoami", nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuthenticateHS256(t *testing.T) {
+	r := setupAuthTestRouter(t, middleware.JWTOptions{HMACSecret: testHMACSecret})
+
+	token := signToken(t, jwt.SigningMethodHS256, []byte(testHMACSecret), "", validClaims())
+	w := callWithToken(r, "Bearer "+token)
+
+	// Assert response
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var principal middleware.Principal
+	err := json.Unmarshal(w.Body.Bytes(), &principal)
+	assert.NoError(t, err)
+	assert.Equal(t, "staff-1001", principal.Subject)
+	assert.Equal(t, []string{"staff"}, principal.Roles)
+	assert.Equal(t, []string{"students:read", "students:write"}, principal.Scopes)
+}
+
+func TestAuthenticateRejectsMissingOrInvalidTokens(t *testing.T) {
+	r := setupAuthTestRouter(t, middleware.JWTOptions{HMACSecret: testHMACSecret})
+
+	expired := validClaims()
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	noExpiry := validClaims()
+	delete(noExpiry, "exp")
+	noSubject := validClaims()
+	delete(noSubject, "sub")
+
+	for name, tc := range map[string]struct {
+		authorization string
+		message       string
+	}{
+		"missing":      {"", "Authentication required"},
+		"wrong scheme": {"Basic dXNlcjpwYXNz", "Authentication required"},
+		"malformed":    {"Bearer not-a-token", "Invalid token"},
+		"expired":      {"Bearer " + signToken(t, jwt.SigningMethodHS256, []byte(testHMACSecret), "", expired), "Token has expired"},
+		"no expiry":    {"Bearer " + signToken(t, jwt.SigningMethodHS256, []byte(testHMACSecret), "", noExpiry), "Invalid token"},
+		"no subject":   {"Bearer " + signToken(t, jwt.SigningMethodHS256, []byte(testHMACSecret), "", noSubject), "Invalid token"},
+		"wrong secret": {"Bearer " + signToken(t, jwt.SigningMethodHS256, []byte("another-secret"), "", validClaims()), "Invalid token"},
+		"unsigned":     {"Bearer " + signToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", validClaims()), "Invalid token"},
+	} {
+		w := callWithToken(r, tc.authorization)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, name)
+		assert.Contains(t, w.Body.String(), tc.message, name)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), "Bearer", name)
+	}
+}
+
+func TestAuthenticateIssuerAndAudience(t *testing.T) {
+	r := setupAuthTestRouter(t, middleware.JWTOptions{HMACSecret: testHMACSecret, Issuer: "https://sso.bournemouth.ac.uk", Audience: "student-api"})
+
+	claims := validClaims()
+	claims["iss"] = "https://sso.bournemouth.ac.uk"
+	claims["aud"] = "student-api"
+	w := callWithToken(r, "Bearer "+signToken(t, jwt.SigningMethodHS256, []byte(testHMACSecret), "", claims))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	claims["aud"] = "library"
+	w = callWithToken(r, "Bearer "+signToken(t, jwt.SigningMethodHS256, []byte(testHMACSecret), "", claims))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestAuthenticateRS256WithJWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	// Write a key set holding both keys
+	jwkFor := func(kid string, k *rsa.PrivateKey) map[string]string {
+		return map[string]string{
+			"kty": "RSA",
+			"kid": kid,
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		}
+	}
+	jwks, _ := json.Marshal(map[string]interface{}{"keys": []interface{}{jwkFor("current", key), jwkFor("previous", other)}})
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, jwks, 0o600))
+
+	r := setupAuthTestRouter(t, middleware.JWTOptions{JWKSFile: path})
+
+	// Assert the key named by kid is used
+	w := callWithToken(r, "Bearer "+signToken(t, jwt.SigningMethodRS256, key, "current", validClaims()))
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = callWithToken(r, "Bearer "+signToken(t, jwt.SigningMethodRS256, other, "current", validClaims()))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = callWithToken(r, "Bearer "+signToken(t, jwt.SigningMethodRS256, key, "unknown", validClaims()))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Assert an HS256 token is not accepted when no secret is configured
+	w = callWithToken(r, "Bearer "+signToken(t, jwt.SigningMethodHS256, []byte(testHMACSecret), "", validClaims()))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestAuthenticateRS256WithPEMKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	r := setupAuthTestRouter(t, middleware.JWTOptions{RSAPublicKey: string(publicPEM)})
+
+	w := callWithToken(r, "Bearer "+signToken(t, jwt.SigningMethodRS256, key, "", validClaims()))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestNewJWTAuthenticatorRequiresAKey(t *testing.T) {
+	_, err := middleware.NewJWTAuthenticator(middleware.JWTOptions{})
+	assert.Error(t, err)
+
+	_, err = middleware.NewJWTAuthenticator(middleware.JWTOptions{RSAPublicKey: "not a key"})
+	assert.Error(t, err)
+
+	_, err = middleware.NewJWTAuthenticator(middleware.JWTOptions{JWKSFile: filepath.Join(t.TempDir(), "missing.json")})
+	assert.Error(t, err)
+}
@@ -0,0 +1,177 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bournemouth-uni-it-api-go/classification"
+	"github.com/bournemouth-uni-it-api-go/handlers"
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// levelModules returns 20-credit modules at a level with the given marks
+func levelModules(level int, marks ...float64) []classification.Module {
+	modules := make([]classification.Module, len(marks))
+	for i, mark := range marks {
+		modules[i] = classification.Module{Code: "M" + string(rune('0'+level)) + string(rune('A'+i)), Level: level, Credits: 20, Mark: mark}
+	}
+	return modules
+}
+
+func TestClassifyWeightsLevels(t *testing.T) {
+	modules := append(levelModules(5, 60, 60, 60, 60, 60, 60), levelModules(6, 70, 70, 70, 70, 70, 70)...)
+
+	result, err := classification.Classify(classification.DefaultRules(), modules)
+	assert.NoError(t, err)
+
+	// 25% of 60 plus 75% of 70
+	assert.Equal(t, 67.5, result.Average)
+	assert.Equal(t, classification.UpperSecond, result.Classification)
+	assert.Nil(t, result.Borderline)
+	assert.Len(t, result.Levels, 2)
+	assert.Equal(t, 15.0, result.Levels[0].Contribution)
+	assert.Equal(t, 52.5, result.Levels[1].Contribution)
+}
+
+func TestClassifyBorderline(t *testing.T) {
+	// Level averages of 66 and 69 give 68.25, within two points of a First
+	promoted := append(levelModules(5, 66, 66, 66, 66, 66, 66), levelModules(6, 72, 72, 72, 72, 63, 63)...)
+	result, err := classification.Classify(classification.DefaultRules(), promoted)
+	assert.NoError(t, err)
+	assert.Equal(t, 68.25, result.Average)
+	assert.Equal(t, classification.UpperSecond, result.AverageClass)
+	assert.Equal(t, classification.First, result.Classification)
+	assert.Equal(t, 80, result.Borderline.CreditsAtOrAbove)
+	assert.True(t, result.Borderline.Promoted)
+
+	// The same average with only a third of final-year credits at 70 or above
+	notPromoted := append(levelModules(5, 66, 66, 66, 66, 66, 66), levelModules(6, 75, 75, 66, 66, 66, 66)...)
+	result, err = classification.Classify(classification.DefaultRules(), notPromoted)
+	assert.NoError(t, err)
+	assert.Equal(t, 68.25, result.Average)
+	assert.Equal(t, classification.UpperSecond, result.Classification)
+	assert.Equal(t, 0.33, result.Borderline.Share)
+	assert.False(t, result.Borderline.Promoted)
+}
+
+func TestClassifyDiscountsLowestCredits(t *testing.T) {
+	rules := classification.DefaultRules()
+	rules.LevelWeights = map[int]float64{6: 1}
+	rules.DiscountCredits = map[int]int{6: 20}
+
+	modules := []classification.Module{
+		{Code: "PROJ", Level: 6, Credits: 90, Mark: 70},
+		{Code: "WEAK", Level: 6, Credits: 30, Mark: 40},
+	}
+
+	result, err := classification.Classify(rules, modules)
+	assert.NoError(t, err)
+
+	// 20 of the 30 weak credits are discounted: (40*10 + 70*90) / 100
+	level := result.Levels[0]
+	assert.Equal(t, 20, level.DiscountedCredits)
+	assert.Equal(t, 100, level.CountedCredits)
+	assert.Equal(t, 67.0, level.Average)
+	assert.Equal(t, "WEAK", level.Modules[0].Code)
+	assert.Equal(t, 10, level.Modules[0].CountedCredits)
+}
+
+func TestClassifyIncomplete(t *testing.T) {
+	_, err := classification.Classify(classification.DefaultRules(), levelModules(6, 70, 70))
+	assert.True(t, errors.Is(err, classification.ErrIncomplete))
+}
+
+func TestParseClassificationRules(t *testing.T) {
+	weights, err := classification.ParseLevelWeights("2:1, 3:2")
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]float64{2: 1, 3: 2}, weights)
+
+	credits, err := classification.ParseDiscountCredits("3:20")
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{3: 20}, credits)
+
+	_, err = classification.ParseLevelWeights("2=25")
+	assert.Error(t, err)
+}
+
+func TestGetStudentClassification(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	mockRepo := new(MockAssessmentRepository)
+	students := new(MockStudentRepository)
+	handler := &handlers.ClassificationHandler{Students: students, Results: mockRepo, Rules: classification.DefaultRules()}
+	r.GET("/api/v1/students/:id/classification", handler.GetStudentClassification)
+
+	// Mock data
+	results := []models.ModuleResult{
+		{ModuleCode: "CS101", Level: 4, Credits: 120, Total: markPtr(40)},
+		{ModuleCode: "CS201", Level: 5, Credits: 60, Total: markPtr(55)},
+		{ModuleCode: "CS202", Level: 5, Credits: 60, Total: markPtr(65)},
+		{ModuleCode: "CS301", Level: 6, Credits: 120, Total: markPtr(62)},
+	}
+
+	// Set expectations
+	students.On("GetByID", 1).Return(&models.Student{ID: 1, StudentID: "S12345"}, nil)
+	mockRepo.On("StudentResults", 1, "").Return(results, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/api/v1/students/1/classification", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		StudentID      int                           `json:"student_id"`
+		Classification string                        `json:"classification"`
+		Average        float64                       `json:"average"`
+		Levels         []classification.LevelWorking `json:"levels"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, response.StudentID)
+	// 25% of 60 plus 75% of 62; level 4 does not count
+	assert.Equal(t, 61.5, response.Average)
+	assert.Equal(t, classification.UpperSecond, response.Classification)
+	assert.Len(t, response.Levels, 2)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetStudentClassificationPending(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	mockRepo := new(MockAssessmentRepository)
+	students := new(MockStudentRepository)
+	handler := &handlers.ClassificationHandler{Students: students, Results: mockRepo, Rules: classification.DefaultRules()}
+	r.GET("/api/v1/students/:id/classification", handler.GetStudentClassification)
+
+	// Mock data, with a completed module at each weighted level but another
+	// still waiting for its exam
+	results := []models.ModuleResult{
+		{ModuleCode: "CS201", Level: 5, Credits: 120, Total: markPtr(75)},
+		{ModuleCode: "CS301", Level: 6, Credits: 60, Total: markPtr(75)},
+		{ModuleCode: "CS302", Level: 6, Credits: 60, ProvisionalTotal: markPtr(20), AcademicYear: "2024/25"},
+	}
+
+	// Set expectations
+	students.On("GetByID", 1).Return(&models.Student{ID: 1, StudentID: "S12345"}, nil)
+	mockRepo.On("StudentResults", 1, "").Return(results, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/api/v1/students/1/classification", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert no award is made until every counted module has a final total
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	p := decodeProblem(t, w)
+	assert.Equal(t, "student.classification_incomplete", p["code"])
+	assert.Equal(t, []interface{}{"CS302"}, p["pending_modules"])
+	assert.NotContains(t, p, "classification")
+}
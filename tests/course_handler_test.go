@@ -0,0 +1,277 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bournemouth-uni-it-api-go/handlers"
+	"github.com/bournemouth-uni-it-api-go/middleware"
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockCourseRepository is a mock implementation of CourseRepository
+type MockCourseRepository struct {
+	mock.Mock
+	audits []models.Audit
+}
+
+// WithAudit records the audit details and returns the same mock, so that
+// expectations set on the mock apply to the audited repository
+func (m *MockCourseRepository) WithAudit(a models.Audit) models.CourseRepository {
+	m.audits = append(m.audits, a)
+	return m
+}
+
+func (m *MockCourseRepository) List() ([]models.Course, error) {
+	args := m.Called()
+	return args.Get(0).([]models.Course), args.Error(1)
+}
+
+func (m *MockCourseRepository) GetByCode(code string) (*models.Course, error) {
+	args := m.Called(code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Course), args.Error(1)
+}
+
+func (m *MockCourseRepository) Create(course *models.Course) error {
+	args := m.Called(course)
+	return args.Error(0)
+}
+
+func (m *MockCourseRepository) Update(course *models.Course) error {
+	args := m.Called(course)
+	return args.Error(0)
+}
+
+func (m *MockCourseRepository) Delete(id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func setupCourseTestRouter() (*gin.Engine, *MockCourseRepository) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	mockRepo := new(MockCourseRepository)
+
+	// Create a test handler with the mock repository
+	handler := &handlers.CourseHandler{
+		Repo: mockRepo,
+	}
+
+	// Set up routes
+	r.GET("/api/v1/courses", handler.GetAllCourses)
+	r.GET("/api/v1/courses/:code", handler.GetCourseByCode)
+	r.POST("/api/v1/courses", handler.CreateCourse)
+	r.PUT("/api/v1/courses/:code", handler.UpdateCourse)
+	r.DELETE("/api/v1/courses/:code", handler.DeleteCourse)
+
+	return r, mockRepo
+}
+
+func TestGetAllCourses(t *testing.T) {
+	r, mockRepo := setupCourseTestRouter()
+
+	// Mock data
+	courses := []models.Course{
+		{ID: 1, Code: "BSC-CS", Title: "Computer Science", Department: "Computing and Informatics", Level: "undergraduate", DurationYears: 3},
+		{ID: 2, Code: "BSC-IT", Title: "Information Technology", Department: "Computing and Informatics", Level: "undergraduate", DurationYears: 3},
+	}
+
+	// Set expectations
+	mockRepo.On("List").Return(courses, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/api/v1/courses", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response []models.Course
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, courses, response)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetCourseByCode(t *testing.T) {
+	r, mockRepo := setupCourseTestRouter()
+
+	// Set expectations
+	mockRepo.On("GetByCode", "BSC-IT").Return(&models.Course{ID: 2, Code: "BSC-IT", Title: "Information Technology"}, nil)
+	mockRepo.On("GetByCode", "MSC-AI").Return(nil, nil)
+
+	// Codes are matched case-insensitively
+	req, _ := http.NewRequest("GET", "/api/v1/courses/bsc-it", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Information Technology")
+
+	// Unknown course
+	req, _ = http.NewRequest("GET", "/api/v1/courses/MSC-AI", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateCourse(t *testing.T) {
+	r, mockRepo := setupCourseTestRouter()
+
+	// Mock data
+	course := models.Course{
+		Code:          "msc-ai",
+		Title:         "Artificial Intelligence",
+		Department:    "Computing and Informatics",
+		Level:         "postgraduate",
+		DurationYears: 1,
+	}
+
+	// Set expectations
+	mockRepo.On("Create", mock.MatchedBy(func(c *models.Course) bool {
+		return c.Code == "MSC-AI"
+	})).Return(nil).Run(func(args mock.Arguments) {
+		c := args.Get(0).(*models.Course)
+		c.ID = 4 // Simulate ID assignment
+	})
+
+	// Create request
+	jsonData, _ := json.Marshal(course)
+	req, _ := http.NewRequest("POST", "/api/v1/courses", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response models.Course
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, response.ID)
+	assert.Equal(t, "MSC-AI", response.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateCourseInvalidLevel(t *testing.T) {
+	r, mockRepo := setupCourseTestRouter()
+
+	// Mock data
+	course := models.Course{Code: "X1", Title: "X", Department: "Y", Level: "doctoral", DurationYears: 3}
+
+	// Create request
+	jsonData, _ := json.Marshal(course)
+	req, _ := http.NewRequest("POST", "/api/v1/courses", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything)
+}
+
+func TestUpdateCourse(t *testing.T) {
+	r, mockRepo := setupCourseTestRouter()
+
+	// Mock data
+	course := models.Course{Code: "BSC-ITM", Title: "IT Management", Department: "Computing and Informatics", Level: "undergraduate", DurationYears: 3}
+
+	// Set expectations
+	mockRepo.On("GetByCode", "BSC-IT").Return(&models.Course{ID: 2, Code: "BSC-IT"}, nil)
+	mockRepo.On("Update", mock.MatchedBy(func(c *models.Course) bool {
+		return c.ID == 2 && c.Code == "BSC-ITM"
+	})).Return(nil)
+
+	// Create request
+	jsonData, _ := json.Marshal(course)
+	req, _ := http.NewRequest("PUT", "/api/v1/courses/BSC-IT", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeleteCourseWithStudents(t *testing.T) {
+	r, mockRepo := setupCourseTestRouter()
+
+	// Set expectations
+	mockRepo.On("GetByCode", "BSC-IT").Return(&models.Course{ID: 2, Code: "BSC-IT"}, nil)
+	mockRepo.On("Delete", 2).Return(&pq.Error{Code: "23503"})
+
+	// Create request
+	req, _ := http.NewRequest("DELETE", "/api/v1/courses/BSC-IT", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "students registered")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateStudentUnknownCourse(t *testing.T) {
+	r, mockRepo := setupTestRouter()
+
+	// Mock data
+	student := models.Student{
+		FirstName:   "New",
+		LastName:    "Student",
+		Email:       "new@example.com",
+		StudentID:   "S99999",
+		Course:      "NOPE",
+		YearOfStudy: 1,
+	}
+
+	// Set expectations
+	mockRepo.On("Create", mock.AnythingOfType("*models.Student")).Return(&pq.Error{Code: "23503"})
+
+	// Create request
+	jsonData, _ := json.Marshal(student)
+	req, _ := http.NewRequest("POST", "/api/v1/students", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "Course does not exist")
+}
+
+func TestCourseRepositoryLogsWithRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.RequestID())
+	mockRepo := new(MockCourseRepository)
+	handler := &handlers.CourseHandler{Repo: mockRepo}
+	r.GET("/api/v1/courses", handler.GetAllCourses)
+
+	// Set expectations
+	mockRepo.On("List").Return([]models.Course{}, nil)
+
+	// Create request with a request ID
+	req, _ := http.NewRequest("GET", "/api/v1/courses", nil)
+	req.Header.Set(middleware.RequestIDHeader, "req-42")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert the repository was bound to the request
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []models.Audit{{Actor: "anonymous", RequestID: "req-42"}}, mockRepo.audits)
+}
@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bournemouth-uni-it-api-go/logging"
+	"github.com/bournemouth-uni-it-api-go/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureLogs makes the default logger write to the returned buffer until
+// the test ends
+func captureLogs(t *testing.T, opts logging.Options) *bytes.Buffer {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(logging.New(&buf, opts))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+// logRecords decodes the JSON log records in buf
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &record), line)
+		records = append(records, record)
+	}
+	return records
+}
+
+// setupLoggingTestRouter returns a router with the logging middleware in the
+// same order as the API
+func setupLoggingTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.RequestID(), middleware.Logger(), middleware.Recovery())
+	r.POST("/api/v1/students", func(c *gin.Context) {
+		err := errors.New(`pq: duplicate key value violates unique constraint "students_email_key": Key (email)=(jane.smith@bournemouth.ac.uk) already exists`)
+		slog.ErrorContext(c.Request.Context(), "Error creating student", "error", err, "email", "jane.smith@bournemouth.ac.uk")
+		c.JSON(http.StatusConflict, gin.H{})
+	})
+	r.GET("/api/v1/students/by-email/:email", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{})
+	})
+	r.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+	return r
+}
+
+func TestRequestLogging(t *testing.T) {
+	logs := captureLogs(t, logging.Options{Format: logging.FormatJSON, Level: slog.LevelInfo})
+	r := setupLoggingTestRouter()
+
+	req, _ := http.NewRequest("POST", "/api/v1/students", nil)
+	req.Header.Set(middleware.RequestIDHeader, "req-123")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, "req-123", w.Header().Get(middleware.RequestIDHeader))
+
+	records := logRecords(t, logs)
+	require.Len(t, records, 2)
+
+	// Assert the handler's record names the request and hides the email
+	assert.Equal(t, "ERROR", records[0]["level"])
+	assert.Equal(t, "Error creating student", records[0]["msg"])
+	assert.Equal(t, "req-123", records[0]["request_id"])
+	assert.Equal(t, logging.Redacted, records[0]["email"])
+	assert.Contains(t, records[0]["error"], "Key (email)=(***@bournemouth.ac.uk)")
+	assert.NotContains(t, logs.String(), "jane.smith")
+
+	// Assert the request itself is logged at warn for a client error
+	assert.Equal(t, "WARN", records[1]["level"])
+	assert.Equal(t, "req-123", records[1]["request_id"])
+	assert.Equal(t, "POST", records[1]["method"])
+	assert.Equal(t, "/api/v1/students", records[1]["route"])
+	assert.Equal(t, float64(http.StatusConflict), records[1]["status"])
+}
+
+func TestRequestLoggingRedactsPaths(t *testing.T) {
+	logs := captureLogs(t, logging.Options{Format: logging.FormatJSON, Level: slog.LevelInfo})
+	r := setupLoggingTestRouter()
+
+	req, _ := http.NewRequest("GET", "/api/v1/students/by-email/john.doe@bournemouth.ac.uk", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	records := logRecords(t, logs)
+	require.Len(t, records, 1)
+	assert.Equal(t, "INFO", records[0]["level"])
+	assert.Equal(t, "/api/v1/students/by-email/***@bournemouth.ac.uk", records[0]["path"])
+
+	// Assert a generated request ID is logged and returned
+	assert.NotEmpty(t, records[0]["request_id"])
+	assert.Equal(t, w.Header().Get(middleware.RequestIDHeader), records[0]["request_id"])
+}
+
+func TestRequestLoggingRecoversPanics(t *testing.T) {
+	logs := captureLogs(t, logging.Options{Format: logging.FormatJSON, Level: slog.LevelInfo})
+	r := setupLoggingTestRouter()
+
+	req, _ := http.NewRequest("GET", "/panic", nil)
+	req.Header.Set(middleware.RequestIDHeader, "req-456")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NotContains(t, w.Body.String(), "boom")
+
+	records := logRecords(t, logs)
+	require.Len(t, records, 2)
+	assert.Equal(t, "boom", records[0]["panic"])
+	assert.NotEmpty(t, records[0]["stack"])
+	assert.Equal(t, "req-456", records[0]["request_id"])
+	assert.Equal(t, "ERROR", records[1]["level"])
+	assert.Equal(t, float64(http.StatusInternalServerError), records[1]["status"])
+}
+
+func TestLogFormatAndLevel(t *testing.T) {
+	logs := captureLogs(t, logging.Options{Format: logging.FormatText, Level: slog.LevelWarn})
+
+	slog.Info("Server starting", "port", "8080")
+	slog.Warn("Failed to insert sample data", "first_name", "Jane")
+	assert.NotContains(t, logs.String(), "Server starting")
+	assert.Contains(t, logs.String(), `level=WARN msg="Failed to insert sample data" first_name=[REDACTED]`)
+
+	level, err := logging.ParseLevel("DEBUG")
+	assert.NoError(t, err)
+	assert.Equal(t, slog.LevelDebug, level)
+	_, err = logging.ParseLevel("verbose")
+	assert.Error(t, err)
+
+	format, err := logging.ParseFormat(" Text ")
+	assert.NoError(t, err)
+	assert.Equal(t, logging.FormatText, format)
+	_, err = logging.ParseFormat("xml")
+	assert.Error(t, err)
+}
@@ -0,0 +1,198 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bournemouth-uni-it-api-go/logging"
+	"github.com/bournemouth-uni-it-api-go/metrics"
+	"github.com/bournemouth-uni-it-api-go/middleware"
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testMetricsToken = "scrape-secret"
+
+// setupMetricsTestRouter returns a router that records requests in m and
+// serves them at /metrics
+func setupMetricsTestRouter(m *metrics.Metrics) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.Metrics(m), middleware.Recovery())
+	r.GET("/metrics", middleware.MetricsAuth(testMetricsToken), gin.WrapH(m.Handler()))
+	r.GET("/api/v1/students/:id", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{})
+	})
+	r.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+	return r
+}
+
+// scrape reads /metrics with the test token
+func scrape(t *testing.T, r *gin.Engine) string {
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer "+testMetricsToken)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	return w.Body.String()
+}
+
+func TestHTTPMetrics(t *testing.T) {
+	m := metrics.New()
+	r := setupMetricsTestRouter(m)
+
+	for _, target := range []string{"/api/v1/students/1", "/api/v1/students/2", "/nothing/here", "/panic"} {
+		req, _ := http.NewRequest("GET", target, nil)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	body := scrape(t, r)
+	// Assert requests are counted by route pattern rather than path
+	assert.Contains(t, body, `studentapi_http_requests_total{method="GET",route="/api/v1/students/:id",status="200"} 2`)
+	assert.Contains(t, body, `studentapi_http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	assert.Contains(t, body, `studentapi_http_requests_total{method="GET",route="/panic",status="500"} 1`)
+	assert.Contains(t, body, `studentapi_http_request_duration_seconds_count{method="GET",route="/api/v1/students/:id"} 2`)
+	// Assert only the scrape itself is in flight
+	assert.Contains(t, body, "studentapi_http_requests_in_flight 1")
+	assert.Contains(t, body, "go_goroutines")
+}
+
+func TestMetricsRequireToken(t *testing.T) {
+	r := setupMetricsTestRouter(metrics.New())
+
+	for _, authorization := range []string{"", "Bearer wrong", "Basic " + testMetricsToken} {
+		req, _ := http.NewRequest("GET", "/metrics", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, authorization)
+		assert.NotContains(t, w.Body.String(), "studentapi_")
+	}
+	scrape(t, r)
+}
+
+// fakeStudentCounter returns fixed counts, or an error
+type fakeStudentCounter struct {
+	counts map[string]int
+	err    error
+}
+
+func (f fakeStudentCounter) CountByStatus(ctx context.Context) (map[string]int, error) {
+	return f.counts, f.err
+}
+
+func TestStudentMetrics(t *testing.T) {
+	m := metrics.New()
+	require.NoError(t, m.RegisterStudents(fakeStudentCounter{counts: map[string]int{
+		models.StatusEnrolled:  120,
+		models.StatusGraduated: 0,
+	}}))
+	body := scrape(t, setupMetricsTestRouter(m))
+	assert.Contains(t, body, `studentapi_students{status="enrolled"} 120`)
+	assert.Contains(t, body, `studentapi_students{status="graduated"} 0`)
+
+	// Assert a failed count leaves out the students but not the rest
+	m = metrics.New()
+	require.NoError(t, m.RegisterStudents(fakeStudentCounter{err: errors.New("connection refused")}))
+	body = scrape(t, setupMetricsTestRouter(m))
+	assert.NotContains(t, body, "studentapi_students{")
+	assert.Contains(t, body, "studentapi_http_requests_in_flight")
+}
+
+func TestStudentMetricsCountWithScrapeRequestID(t *testing.T) {
+	m := metrics.New()
+	var requestID string
+	require.NoError(t, m.RegisterStudents(metrics.StudentCounterFunc(func(ctx context.Context) (map[string]int, error) {
+		requestID = logging.RequestID(ctx)
+		return map[string]int{models.StatusEnrolled: 1}, nil
+	})))
+	assert.Error(t, m.RegisterStudents(fakeStudentCounter{}))
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.RequestID())
+	r.GET("/metrics", gin.WrapH(m.Handler()))
+
+	// Create request with a request ID
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	req.Header.Set(middleware.RequestIDHeader, "scrape-7")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert the students were counted within the scrape's request
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `studentapi_students{status="enrolled"} 1`)
+	assert.Equal(t, "scrape-7", requestID)
+}
+
+// fakeConnector hands out connections that answer every statement at once
+type fakeConnector struct{}
+
+func (fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn{}, nil }
+func (fakeConnector) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	if query == "DELETE FROM courses WHERE code = $1" {
+		return nil, errors.New("course in use")
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (fakeConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return fakeRows{}, nil
+}
+
+type fakeRows struct{}
+
+func (fakeRows) Columns() []string         { return []string{"id"} }
+func (fakeRows) Close() error              { return nil }
+func (fakeRows) Next([]driver.Value) error { return io.EOF }
+
+func TestDatabaseMetrics(t *testing.T) {
+	m := metrics.New()
+	db := sql.OpenDB(m.Connector(fakeConnector{}))
+	defer db.Close()
+	require.NoError(t, m.RegisterDB(db, "student_db"))
+
+	_, err := db.Exec(`INSERT INTO students (first_name) VALUES ($1)`, "Amy")
+	require.NoError(t, err)
+	rows, err := db.Query(`
+		SELECT id FROM "student_history" WHERE student_id = $1`, 1)
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+	_, err = db.Exec(`UPDATE students SET first_name = $1 WHERE id = $2`, "Amy", 1)
+	require.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM courses WHERE code = $1`, "BSC-IT")
+	require.Error(t, err)
+	_, err = db.Exec(`CREATE TABLE scratch (id int)`)
+	require.NoError(t, err)
+
+	body := scrape(t, setupMetricsTestRouter(m))
+	// Assert statements are timed by operation, table and outcome
+	assert.Contains(t, body, `studentapi_db_query_duration_seconds_count{operation="insert",outcome="success",table="students"} 1`)
+	assert.Contains(t, body, `studentapi_db_query_duration_seconds_count{operation="select",outcome="success",table="student_history"} 1`)
+	assert.Contains(t, body, `studentapi_db_query_duration_seconds_count{operation="update",outcome="success",table="students"} 1`)
+	assert.Contains(t, body, `studentapi_db_query_duration_seconds_count{operation="delete",outcome="error",table="courses"} 1`)
+	assert.Contains(t, body, `studentapi_db_query_duration_seconds_count{operation="other",outcome="success",table=""} 1`)
+	// Assert the connection pool is exported
+	assert.Contains(t, body, `go_sql_open_connections{db_name="student_db"} 1`)
+	assert.Contains(t, body, `go_sql_max_open_connections{db_name="student_db"} 0`)
+}
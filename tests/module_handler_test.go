@@ -0,0 +1,329 @@
+package tests
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bournemouth-uni-it-api-go/classification"
+	"github.com/bournemouth-uni-it-api-go/handlers"
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockModuleRepository is a mock implementation of ModuleRepository
+type MockModuleRepository struct {
+	mock.Mock
+}
+
+// WithAudit returns the same mock, so that expectations set on the mock apply
+// to the audited repository
+func (m *MockModuleRepository) WithAudit(a models.Audit) models.ModuleRepository {
+	return m
+}
+
+func (m *MockModuleRepository) List() ([]models.Module, error) {
+	args := m.Called()
+	return args.Get(0).([]models.Module), args.Error(1)
+}
+
+func (m *MockModuleRepository) GetByCode(code string) (*models.Module, error) {
+	args := m.Called(code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Module), args.Error(1)
+}
+
+func (m *MockModuleRepository) Create(module *models.Module) error {
+	args := m.Called(module)
+	return args.Error(0)
+}
+
+func (m *MockModuleRepository) Update(module *models.Module) error {
+	args := m.Called(module)
+	return args.Error(0)
+}
+
+func (m *MockModuleRepository) Delete(id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockModuleRepository) Enrol(studentID int, moduleCode, academicYear string) (*models.Enrolment, error) {
+	args := m.Called(studentID, moduleCode, academicYear)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Enrolment), args.Error(1)
+}
+
+func (m *MockModuleRepository) Unenrol(studentID int, moduleCode, academicYear string) error {
+	args := m.Called(studentID, moduleCode, academicYear)
+	return args.Error(0)
+}
+
+func (m *MockModuleRepository) ListEnrolments(studentID int, academicYear string) ([]models.Enrolment, error) {
+	args := m.Called(studentID, academicYear)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Enrolment), args.Error(1)
+}
+
+func (m *MockModuleRepository) ListStudents(moduleCode, academicYear string) ([]models.Student, error) {
+	args := m.Called(moduleCode, academicYear)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Student), args.Error(1)
+}
+
+func setupModuleTestRouter() (*gin.Engine, *MockModuleRepository) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	mockRepo := new(MockModuleRepository)
+
+	// Create a test handler with the mock repository
+	handler := &handlers.ModuleHandler{
+		Repo: mockRepo,
+	}
+
+	// Set up routes
+	r.GET("/api/v1/modules", handler.GetAllModules)
+	r.GET("/api/v1/modules/:code", handler.GetModuleByCode)
+	r.GET("/api/v1/modules/:code/students", handler.GetModuleStudents)
+	r.POST("/api/v1/modules", handler.CreateModule)
+	r.PUT("/api/v1/modules/:code", handler.UpdateModule)
+	r.DELETE("/api/v1/modules/:code", handler.DeleteModule)
+	r.GET("/api/v1/students/:id/enrolments", handler.GetStudentEnrolments)
+	r.POST("/api/v1/students/:id/enrolments", handler.EnrolStudent)
+	r.DELETE("/api/v1/students/:id/enrolments/:code", handler.DeleteEnrolment)
+
+	return r, mockRepo
+}
+
+func TestCurrentAcademicYear(t *testing.T) {
+	assert.Equal(t, "2024/25", models.CurrentAcademicYear(time.Date(2024, time.September, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "2024/25", models.CurrentAcademicYear(time.Date(2025, time.August, 31, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "2099/00", models.CurrentAcademicYear(time.Date(2099, time.December, 1, 0, 0, 0, 0, time.UTC)))
+
+	assert.True(t, models.IsValidAcademicYear("2024/25"))
+	assert.True(t, models.IsValidAcademicYear("2099/00"))
+	assert.False(t, models.IsValidAcademicYear("2024/26"))
+	assert.False(t, models.IsValidAcademicYear("2024-25"))
+}
+
+func TestFHEQLevel(t *testing.T) {
+	assert.Equal(t, 4, models.FHEQLevel(1))
+	assert.Equal(t, 6, models.FHEQLevel(3))
+
+	// Assert the default rules weight the levels of the second and final years
+	rules := classification.DefaultRules()
+	assert.Contains(t, rules.LevelWeights, models.FHEQLevel(2))
+	assert.Contains(t, rules.LevelWeights, models.FHEQLevel(3))
+	assert.Len(t, rules.LevelWeights, 2)
+}
+
+func TestCreateModule(t *testing.T) {
+	r, mockRepo := setupModuleTestRouter()
+
+	// Mock data
+	module := models.Module{Code: "cs101", Title: "Programming", Level: 4, Credits: 20, Capacity: 120}
+
+	// Set expectations
+	mockRepo.On("Create", mock.MatchedBy(func(m *models.Module) bool {
+		return m.Code == "CS101"
+	})).Return(nil)
+
+	// Create request
+	jsonData, _ := json.Marshal(module)
+	req, _ := http.NewRequest("POST", "/api/v1/modules", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusCreated, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateModuleBelowFHEQLevel4(t *testing.T) {
+	r, mockRepo := setupModuleTestRouter()
+
+	// Create request for a module no year of study maps onto
+	req, _ := http.NewRequest("POST", "/api/v1/modules", bytes.NewBufferString(`{"code": "CS001", "title": "Foundations", "level": 1, "credits": 20, "capacity": 120}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "level")
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything)
+}
+
+func TestEnrolStudent(t *testing.T) {
+	r, mockRepo := setupModuleTestRouter()
+
+	// Mock data
+	enrolment := &models.Enrolment{ID: 7, StudentID: 1, ModuleCode: "CS101", ModuleTitle: "Programming", Credits: 20, AcademicYear: "2024/25"}
+
+	// Set expectations
+	mockRepo.On("Enrol", 1, "CS101", "2024/25").Return(enrolment, nil)
+
+	// Create request
+	req, _ := http.NewRequest("POST", "/api/v1/students/1/enrolments", bytes.NewBufferString(`{"module_code": "cs101", "academic_year": "2024/25"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response models.Enrolment
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, response.ID)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestEnrolStudentDefaultsToCurrentYear(t *testing.T) {
+	r, mockRepo := setupModuleTestRouter()
+
+	// Set expectations
+	mockRepo.On("Enrol", 1, "CS101", models.CurrentAcademicYear(time.Now())).Return(&models.Enrolment{ID: 1}, nil)
+
+	// Create request
+	req, _ := http.NewRequest("POST", "/api/v1/students/1/enrolments", bytes.NewBufferString(`{"module_code": "CS101"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusCreated, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestEnrolStudentRejected(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{"student not found", models.ErrStudentNotFound, http.StatusNotFound},
+		{"module not found", models.ErrModuleNotFound, http.StatusNotFound},
+		{"module full", models.ErrModuleFull, http.StatusConflict},
+		{"already enrolled", models.ErrAlreadyEnrolled, http.StatusConflict},
+		{"level mismatch", models.ErrLevelMismatch, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, mockRepo := setupModuleTestRouter()
+
+			// Set expectations
+			mockRepo.On("Enrol", 1, "CS201", "2024/25").Return(nil, tt.err)
+
+			// Create request
+			req, _ := http.NewRequest("POST", "/api/v1/students/1/enrolments", bytes.NewBufferString(`{"module_code": "CS201", "academic_year": "2024/25"}`))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			// Assert response
+			assert.Equal(t, tt.expected, w.Code)
+		})
+	}
+}
+
+func TestEnrolStudentInvalidAcademicYear(t *testing.T) {
+	r, mockRepo := setupModuleTestRouter()
+
+	// Create request
+	req, _ := http.NewRequest("POST", "/api/v1/students/1/enrolments", bytes.NewBufferString(`{"module_code": "CS101", "academic_year": "2024"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockRepo.AssertNotCalled(t, "Enrol", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetModuleStudents(t *testing.T) {
+	r, mockRepo := setupModuleTestRouter()
+
+	// Mock data
+	students := []models.Student{
+		{ID: 1, FirstName: "John", LastName: "Doe", YearOfStudy: 1},
+	}
+
+	// Set expectations
+	mockRepo.On("ListStudents", "CS101", "2024/25").Return(students, nil)
+	mockRepo.On("ListStudents", "XX999", "2024/25").Return(nil, models.ErrModuleNotFound)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/api/v1/modules/cs101/students?academic_year=2024/25", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		ModuleCode   string           `json:"module_code"`
+		AcademicYear string           `json:"academic_year"`
+		Students     []models.Student `json:"students"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "CS101", response.ModuleCode)
+	assert.Len(t, response.Students, 1)
+
+	// Unknown module
+	req, _ = http.NewRequest("GET", "/api/v1/modules/XX999/students?academic_year=2024/25", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeleteEnrolmentNotFound(t *testing.T) {
+	r, mockRepo := setupModuleTestRouter()
+
+	// Set expectations
+	mockRepo.On("Unenrol", 1, "CS101", "2024/25").Return(sql.ErrNoRows)
+
+	// Create request
+	req, _ := http.NewRequest("DELETE", "/api/v1/students/1/enrolments/CS101?academic_year=2024/25", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeleteEnrolmentWithMarks(t *testing.T) {
+	r, mockRepo := setupModuleTestRouter()
+
+	// Set expectations
+	mockRepo.On("Unenrol", 1, "CS101", "2024/25").Return(models.ErrEnrolmentHasMarks)
+
+	// Create request
+	req, _ := http.NewRequest("DELETE", "/api/v1/students/1/enrolments/CS101?academic_year=2024/25", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "enrolment.has_marks", decodeProblem(t, w)["code"])
+}
@@ -0,0 +1,419 @@
+package tests
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bournemouth-uni-it-api-go/handlers"
+	"github.com/bournemouth-uni-it-api-go/middleware"
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testClientID     = "student-api-ui"
+	testClientSecret = "ui-secret"
+)
+
+// mockIdP is a local OpenID Connect identity provider. The test plays the
+// part of the user at the authorisation endpoint by calling issueCode.
+type mockIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]issuedCode
+}
+
+// issuedCode is an authorisation code waiting to be exchanged
+type issuedCode struct {
+	challenge string
+	nonce     string
+	claims    jwt.MapClaims
+}
+
+// newMockIdP starts an identity provider that is stopped when the test ends
+func newMockIdP(t *testing.T) *mockIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	idp := &mockIdP{key: key, codes: make(map[string]issuedCode)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		base := idp.server.URL
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                base,
+			"authorization_endpoint":                base + "/authorize",
+			"token_endpoint":                        base + "/token",
+			"jwks_uri":                              base + "/jwks",
+			"end_session_endpoint":                  base + "/logout",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "idp-key",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", idp.token)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+// issueCode returns an authorisation code for the login described by an
+// authorisation request URL, as if the user had signed in as subject
+func (idp *mockIdP) issueCode(t *testing.T, authURL *url.URL, subject string) string {
+	query := authURL.Query()
+	require.Equal(t, "S256", query.Get("code_challenge_method"))
+	require.Equal(t, testClientID, query.Get("client_id"))
+
+	code, err := models.RandomToken(16)
+	require.NoError(t, err)
+
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.codes[code] = issuedCode{
+		challenge: query.Get("code_challenge"),
+		nonce:     query.Get("nonce"),
+		claims:    jwt.MapClaims{"sub": subject, "name": "Jane Registry", "email": "jane@bournemouth.ac.uk", "roles": []string{"registry"}},
+	}
+	return code
+}
+
+// token exchanges an authorisation code for an ID token, checking the PKCE
+// code verifier against the challenge of the authorisation request
+func (idp *mockIdP) token(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+	if clientID != testClientID || clientSecret != testClientSecret {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+
+	idp.mu.Lock()
+	issued, ok := idp.codes[r.PostFormValue("code")]
+	delete(idp.codes, r.PostFormValue("code"))
+	idp.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != issued.challenge {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss":   idp.server.URL,
+		"aud":   testClientID,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nonce": issued.nonce,
+	}
+	for k, v := range issued.claims {
+		claims[k] = v
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "idp-key"
+	idToken, _ := token.SignedString(idp.key)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+// memorySessionRepository keeps sessions in memory
+type memorySessionRepository struct {
+	mu       sync.Mutex
+	logins   map[string]models.LoginAttempt
+	sessions map[string]models.Session
+}
+
+func newMemorySessionRepository() *memorySessionRepository {
+	return &memorySessionRepository{logins: make(map[string]models.LoginAttempt), sessions: make(map[string]models.Session)}
+}
+
+func (m *memorySessionRepository) CreateLogin(login *models.LoginAttempt, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.logins[login.State] = *login
+	return nil
+}
+
+func (m *memorySessionRepository) TakeLogin(state string) (*models.LoginAttempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	login, ok := m.logins[state]
+	if !ok {
+		return nil, nil
+	}
+	delete(m.logins, state)
+	return &login, nil
+}
+
+func (m *memorySessionRepository) Create(session *models.Session, ttl time.Duration) (string, error) {
+	id, err := models.RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	session.CSRFToken, _ = models.RandomToken(32)
+	session.CreatedAt = time.Now()
+	session.ExpiresAt = session.CreatedAt.Add(ttl)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[id] = *session
+	return id, nil
+}
+
+func (m *memorySessionRepository) Get(id string) (*models.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session, ok := m.sessions[id]
+	if !ok {
+		return nil, nil
+	}
+	return &session, nil
+}
+
+func (m *memorySessionRepository) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+	return nil
+}
+
+// setupOIDCTestRouter returns a router with the sign-in routes and an API
+// route that accepts sessions
+func setupOIDCTestRouter(t *testing.T, idp *mockIdP) (*gin.Engine, *memorySessionRepository) {
+	gin.SetMode(gin.TestMode)
+	sessions := newMemorySessionRepository()
+	handler, err := handlers.NewAuthHandler(context.Background(), sessions, handlers.OIDCOptions{
+		IssuerURL:             idp.server.URL,
+		ClientID:              testClientID,
+		ClientSecret:          testClientSecret,
+		RedirectURL:           "http://localhost:8080/auth/callback",
+		PostLogoutRedirectURL: "http://localhost:8080/",
+		SessionTTL:            time.Hour,
+		CookieSecure:          true,
+	})
+	require.NoError(t, err)
+	authenticator, err := middleware.NewJWTAuthenticator(middleware.JWTOptions{HMACSecret: testHMACSecret})
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.GET("/auth/login", handler.Login)
+	r.GET("/auth/callback", handler.Callback)
+	r.GET("/auth/session", handler.Session)
+	r.POST("/auth/logout", handler.Logout)
+
+	api := r.Group("/api/v1", middleware.Authenticate(authenticator, nil, sessions))
+	whoami := func(c *gin.Context) {
+		principal, _ := middleware.GetPrincipal(c)
+		c.JSON(http.StatusOK, principal)
+	}
+	api.GET("/whoami", whoami)
+	api.POST("/whoami", whoami)
+
+	return r, sessions
+}
+
+// browserRequest sends a request carrying the given cookies
+func browserRequest(r *gin.Engine, method, target string, cookies []*http.Cookie, header http.Header) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, target, nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v[0])
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// findCookie returns the cookie with the given name set by a response
+func findCookie(w *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
+
+// startLogin calls /auth/login and returns the authorisation request URL and
+// the login cookie
+func startLogin(t *testing.T, r *gin.Engine, returnTo string) (*url.URL, *http.Cookie) {
+	w := browserRequest(r, "GET", "/auth/login?return_to="+url.QueryEscape(returnTo), nil, nil)
+	require.Equal(t, http.StatusFound, w.Code)
+
+	authURL, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	loginCookie := findCookie(w, "bu_login")
+	require.NotNil(t, loginCookie)
+	return authURL, loginCookie
+}
+
+func TestOIDCLoginFlow(t *testing.T) {
+	idp := newMockIdP(t)
+	r, sessions := setupOIDCTestRouter(t, idp)
+
+	// Start signing in
+	authURL, loginCookie := startLogin(t, r, "/index.html")
+	assert.Equal(t, idp.server.URL+"/authorize", authURL.Scheme+"://"+authURL.Host+authURL.Path)
+	assert.NotEmpty(t, authURL.Query().Get("code_challenge"))
+	assert.NotEmpty(t, authURL.Query().Get("nonce"))
+	assert.Equal(t, loginCookie.Value, authURL.Query().Get("state"))
+	assert.True(t, loginCookie.HttpOnly)
+	assert.True(t, loginCookie.Secure)
+
+	// Come back from the identity provider
+	code := idp.issueCode(t, authURL, "staff-1001")
+	w := browserRequest(r, "GET", "/auth/callback?code="+code+"&state="+authURL.Query().Get("state"), []*http.Cookie{loginCookie}, nil)
+	require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+	assert.Equal(t, "/index.html", w.Header().Get("Location"))
+
+	sessionCookie := findCookie(w, middleware.SessionCookie)
+	require.NotNil(t, sessionCookie)
+	assert.True(t, sessionCookie.HttpOnly)
+	assert.Equal(t, http.SameSiteLaxMode, sessionCookie.SameSite)
+	assert.Len(t, sessions.sessions, 1)
+
+	// Assert the session is available to the web interface
+	w = browserRequest(r, "GET", "/auth/session", []*http.Cookie{sessionCookie}, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var session map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &session))
+	assert.Equal(t, "staff-1001", session["subject"])
+	assert.Equal(t, "Jane Registry", session["name"])
+	csrfToken := session["csrf_token"].(string)
+
+	// Assert the session authenticates API requests, and changes need the
+	// CSRF token
+	w = browserRequest(r, "GET", "/api/v1/whoami", []*http.Cookie{sessionCookie}, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var principal middleware.Principal
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &principal))
+	assert.Equal(t, "staff-1001", principal.Subject)
+	assert.Equal(t, []string{"registry"}, principal.Roles)
+	assert.Equal(t, middleware.MethodSession, principal.Method)
+
+	w = browserRequest(r, "POST", "/api/v1/whoami", []*http.Cookie{sessionCookie}, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = browserRequest(r, "POST", "/api/v1/whoami", []*http.Cookie{sessionCookie}, http.Header{middleware.CSRFHeader: {"wrong"}})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = browserRequest(r, "POST", "/api/v1/whoami", []*http.Cookie{sessionCookie}, http.Header{middleware.CSRFHeader: {csrfToken}})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Sign out
+	w = browserRequest(r, "POST", "/auth/logout", []*http.Cookie{sessionCookie}, nil)
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	logoutURL, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "/logout", logoutURL.Path)
+	assert.NotEmpty(t, logoutURL.Query().Get("id_token_hint"))
+	assert.Equal(t, "http://localhost:8080/", logoutURL.Query().Get("post_logout_redirect_uri"))
+	assert.Equal(t, -1, findCookie(w, middleware.SessionCookie).MaxAge)
+	assert.Empty(t, sessions.sessions)
+
+	// Assert the session no longer works
+	w = browserRequest(r, "GET", "/api/v1/whoami", []*http.Cookie{sessionCookie}, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = browserRequest(r, "GET", "/auth/session", []*http.Cookie{sessionCookie}, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestOIDCCallbackRejectsBadState(t *testing.T) {
+	idp := newMockIdP(t)
+	r, _ := setupOIDCTestRouter(t, idp)
+
+	authURL, loginCookie := startLogin(t, r, "/")
+	code := idp.issueCode(t, authURL, "staff-1001")
+	state := authURL.Query().Get("state")
+
+	// Assert the login cookie must match the state
+	w := browserRequest(r, "GET", "/auth/callback?code="+code+"&state="+state, nil, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = browserRequest(r, "GET", "/auth/callback?code="+code+"&state=other", []*http.Cookie{loginCookie}, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Assert a login can only be completed once
+	w = browserRequest(r, "GET", "/auth/callback?code="+code+"&state="+state, []*http.Cookie{loginCookie}, nil)
+	assert.Equal(t, http.StatusFound, w.Code)
+	w = browserRequest(r, "GET", "/auth/callback?code="+code+"&state="+state, []*http.Cookie{loginCookie}, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Assert errors from the identity provider are reported
+	w = browserRequest(r, "GET", "/auth/callback?error=access_denied&state="+state, []*http.Cookie{loginCookie}, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "access_denied")
+}
+
+func TestOIDCCallbackRequiresCodeVerifier(t *testing.T) {
+	idp := newMockIdP(t)
+	r, sessions := setupOIDCTestRouter(t, idp)
+
+	// Issue a code for a different PKCE challenge, as if it had been
+	// intercepted from another login
+	authURL, loginCookie := startLogin(t, r, "/")
+	intercepted := *authURL
+	query := intercepted.Query()
+	query.Set("code_challenge", "another-challenge")
+	intercepted.RawQuery = query.Encode()
+	code := idp.issueCode(t, &intercepted, "staff-1001")
+
+	w := browserRequest(r, "GET", "/auth/callback?code="+code+"&state="+authURL.Query().Get("state"), []*http.Cookie{loginCookie}, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Nil(t, findCookie(w, middleware.SessionCookie))
+	assert.Empty(t, sessions.sessions)
+}
+
+func TestOIDCLoginOnlyReturnsToLocalPaths(t *testing.T) {
+	idp := newMockIdP(t)
+	r, sessions := setupOIDCTestRouter(t, idp)
+
+	for returnTo, expected := range map[string]string{
+		"/index.html?tab=1":    "/index.html?tab=1",
+		"//evil.example":       "/",
+		"https://evil.example": "/",
+		`/\evil.example`:       "/",
+	} {
+		authURL, _ := startLogin(t, r, returnTo)
+		login := sessions.logins[authURL.Query().Get("state")]
+		assert.Equal(t, expected, login.ReturnTo, returnTo)
+	}
+}
+
+func TestNewAuthHandlerRequiresReachableIssuer(t *testing.T) {
+	_, err := handlers.NewAuthHandler(context.Background(), newMemorySessionRepository(), handlers.OIDCOptions{IssuerURL: "http://127.0.0.1:1"})
+	assert.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "identity provider"))
+}
@@ -0,0 +1,165 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bournemouth-uni-it-api-go/config"
+	"github.com/bournemouth-uni-it-api-go/middleware"
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/bournemouth-uni-it-api-go/problem"
+	"github.com/bournemouth-uni-it-api-go/router"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeProblem asserts w is a problem details response and decodes it
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+	var p map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	assert.Equal(t, float64(w.Code), p["status"])
+	assert.Equal(t, problem.TypeBase+p["code"].(string), p["type"])
+	assert.NotEmpty(t, p["title"])
+	return p
+}
+
+func postStudent(r *gin.Engine, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", "/api/v1/students", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestProblemNotFound(t *testing.T) {
+	r, mockRepo := setupTestRouter()
+	mockRepo.On("GetByID", 99).Return(nil, nil)
+
+	req, _ := http.NewRequest("GET", "/api/v1/students/99", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	p := decodeProblem(t, w)
+	assert.Equal(t, "student.not_found", p["code"])
+	assert.Equal(t, "Student not found", p["title"])
+	assert.Equal(t, "/api/v1/students/99", p["instance"])
+}
+
+func TestProblemInvalidStudentBody(t *testing.T) {
+	r, _ := setupTestRouter()
+
+	// Assert each missing field is listed under its JSON name
+	w := postStudent(r, `{"first_name": "New", "year_of_study": 1}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	p := decodeProblem(t, w)
+	assert.Equal(t, "request.validation_failed", p["code"])
+	fields := map[string]string{}
+	for _, e := range p["errors"].([]interface{}) {
+		e := e.(map[string]interface{})
+		fields[e["field"].(string)] = e["code"].(string)
+	}
+	assert.Equal(t, "required", fields["last_name"])
+	assert.Equal(t, "required", fields["email"])
+	assert.NotContains(t, fields, "first_name")
+
+	// Assert a field of the wrong type is named without leaking the decoder error
+	w = postStudent(r, `{"first_name": "New", "year_of_study": "one"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	p = decodeProblem(t, w)
+	assert.Equal(t, "request.validation_failed", p["code"])
+	assert.Equal(t, "year_of_study", p["errors"].([]interface{})[0].(map[string]interface{})["field"])
+	assert.NotContains(t, w.Body.String(), "Go struct")
+
+	// Assert malformed JSON is reported as such
+	w = postStudent(r, `{"first_name": `)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	p = decodeProblem(t, w)
+	assert.Equal(t, "request.invalid_body", p["code"])
+	assert.Equal(t, "Request body must be valid JSON", p["detail"])
+
+	// Assert a malformed email is a field error
+	w = postStudent(r, `{"first_name": "New", "last_name": "Student", "email": "nope", "student_id": "S1", "course": "IT", "year_of_study": 1}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	p = decodeProblem(t, w)
+	assert.Equal(t, []interface{}{map[string]interface{}{"field": "email", "code": "email", "detail": "Invalid email format"}}, p["errors"])
+}
+
+func TestProblemConstraintViolation(t *testing.T) {
+	r, mockRepo := setupTestRouter()
+	mockRepo.On("Create", mock.AnythingOfType("*models.Student")).
+		Return(&pq.Error{Code: "23505", Message: `duplicate key value violates unique constraint "students_email_key"`})
+
+	student := models.Student{FirstName: "New", LastName: "Student", Email: "new@example.com", StudentID: "S99999", Course: "IT", YearOfStudy: 1}
+	body, _ := json.Marshal(student)
+	req, _ := http.NewRequest("POST", "/api/v1/students", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	p := decodeProblem(t, w)
+	assert.Equal(t, "student.email_taken", p["code"])
+	assert.Equal(t, "Email already exists", p["detail"])
+	assert.NotContains(t, w.Body.String(), "students_email_key")
+}
+
+func TestProblemExtensions(t *testing.T) {
+	p := problem.New(http.StatusConflict, problem.TransitionNotAllowed, "Cannot move").
+		With("allowed", []string{"enrolled"}).
+		With("status", "ignored")
+	b, err := json.Marshal(p)
+	require.NoError(t, err)
+
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, []interface{}{"enrolled"}, decoded["allowed"])
+	// Assert extensions cannot replace standard members
+	assert.Equal(t, float64(http.StatusConflict), decoded["status"])
+	assert.Equal(t, "Status transition not allowed", decoded["title"])
+}
+
+func TestProblemCodesAreDescribed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{
+		JWT:      middleware.JWTOptions{HMACSecret: testHMACSecret},
+		Security: middleware.SecurityOptions{HSTSMaxAge: time.Hour},
+	}
+	r, err := router.SetupRouter(nil, cfg, nil)
+	require.NoError(t, err)
+
+	get := func(target string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", target, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Assert every code has a title and a type URI that describes it
+	for _, code := range problem.Codes() {
+		assert.NotEqual(t, http.StatusText(0), code.Title(0), code)
+		w := get(problem.TypeBase + string(code))
+		require.Equal(t, http.StatusOK, w.Code, code)
+		assert.Contains(t, w.Body.String(), `"code":"`+string(code)+`"`)
+	}
+
+	// Assert unknown routes and authentication failures are problems too
+	w := get("/api/v2/nothing")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "request.route_not_found", decodeProblem(t, w)["code"])
+
+	w = get("/api/v1/students")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "auth.required", decodeProblem(t, w)["code"])
+	assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+}
@@ -0,0 +1,231 @@
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bournemouth-uni-it-api-go/middleware"
+	"github.com/bournemouth-uni-it-api-go/ratelimit"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingStore is a rate limit store that is unavailable
+type failingStore struct{}
+
+func (failingStore) Take(key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("connection refused")
+}
+
+// setupRateLimitTestRouter returns a router whose routes are rate limited
+// with opts, using principals from the X-Test-Subject header
+func setupRateLimitTestRouter(store ratelimit.Store, opts middleware.RateLimitOptions) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(withTestPrincipal, middleware.RateLimit(store, opts))
+
+	ok := func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"status": "ok"}) }
+	r.GET("/api/v1/students", ok)
+	r.GET("/api/v1/students/:id", ok)
+	r.GET("/api/v1/courses", ok)
+	r.GET("/healthcheck", ok)
+	return r
+}
+
+// rateLimitedCall makes a GET request from the given IP address, as subject
+// if it is not empty
+func rateLimitedCall(r *gin.Engine, path, ip, subject string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", path, nil)
+	req.RemoteAddr = ip + ":12345"
+	if subject != "" {
+		req.Header.Set("X-Test-Subject", subject)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestParseLimit(t *testing.T) {
+	limit, err := ratelimit.ParseLimit("100/1m")
+	require.NoError(t, err)
+	assert.Equal(t, ratelimit.Limit{Requests: 100, Period: time.Minute}, limit)
+	assert.Equal(t, "100/1m0s", limit.String())
+
+	limit, err = ratelimit.ParseLimit("off")
+	require.NoError(t, err)
+	assert.True(t, limit.Unlimited())
+
+	for _, invalid := range []string{"100", "0/1m", "-1/1m", "x/1m", "10/soon", "10/0s"} {
+		_, err := ratelimit.ParseLimit(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestParseRouteLimits(t *testing.T) {
+	routes, err := ratelimit.ParseRouteLimits("get /api/v1/students=60/1m, POST /api/v1/students/import=10/1h,GET /healthcheck=off")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]ratelimit.Limit{
+		"GET /api/v1/students":         {Requests: 60, Period: time.Minute},
+		"POST /api/v1/students/import": {Requests: 10, Period: time.Hour},
+		"GET /healthcheck":             {},
+	}, routes)
+
+	routes, err = ratelimit.ParseRouteLimits("")
+	require.NoError(t, err)
+	assert.Empty(t, routes)
+
+	for _, invalid := range []string{"GET /api/v1/students", "/api/v1/students=1/1m", "GET api=1/1m", "GET /api=1"} {
+		_, err := ratelimit.ParseRouteLimits(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestMemoryStoreRefills(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	limit := ratelimit.Limit{Requests: 2, Period: 100 * time.Millisecond}
+
+	result, err := store.Take("client", limit)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 1, result.Remaining)
+
+	result, _ = store.Take("client", limit)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+
+	result, _ = store.Take("client", limit)
+	assert.False(t, result.Allowed)
+	assert.True(t, result.RetryAfter > 0 && result.RetryAfter <= 50*time.Millisecond, result.RetryAfter)
+
+	// Assert other clients have their own bucket
+	result, _ = store.Take("other", limit)
+	assert.True(t, result.Allowed)
+
+	// Assert a request is allowed again once a token has refilled
+	time.Sleep(60 * time.Millisecond)
+	result, _ = store.Take("client", limit)
+	assert.True(t, result.Allowed)
+}
+
+func TestRateLimitRejectsWithRetryAfter(t *testing.T) {
+	r := setupRateLimitTestRouter(ratelimit.NewMemoryStore(), middleware.RateLimitOptions{
+		Default: ratelimit.Limit{Requests: 2, Period: time.Minute},
+	})
+
+	w := rateLimitedCall(r, "/api/v1/students", "10.0.0.1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "30", w.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, "2;w=60", w.Header().Get("RateLimit-Policy"))
+	assert.Empty(t, w.Header().Get("Retry-After"))
+
+	// Assert the default limit is shared by routes without their own
+	w = rateLimitedCall(r, "/api/v1/courses", "10.0.0.1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+
+	w = rateLimitedCall(r, "/api/v1/students/1", "10.0.0.1", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "Rate limit of 2 requests per 1m0s exceeded")
+
+	// Assert another IP address is not affected
+	w = rateLimitedCall(r, "/api/v1/students", "10.0.0.2", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRateLimitKeysByPrincipal(t *testing.T) {
+	r := setupRateLimitTestRouter(ratelimit.NewMemoryStore(), middleware.RateLimitOptions{
+		Default: ratelimit.Limit{Requests: 1, Period: time.Minute},
+	})
+
+	// Assert users behind the same IP address have their own limits, and a
+	// user keeps theirs across IP addresses
+	assert.Equal(t, http.StatusOK, rateLimitedCall(r, "/api/v1/students", "10.0.0.1", "staff-1001").Code)
+	assert.Equal(t, http.StatusOK, rateLimitedCall(r, "/api/v1/students", "10.0.0.1", "staff-1002").Code)
+	assert.Equal(t, http.StatusOK, rateLimitedCall(r, "/api/v1/students", "10.0.0.1", "").Code)
+	assert.Equal(t, http.StatusTooManyRequests, rateLimitedCall(r, "/api/v1/students", "10.0.0.9", "staff-1001").Code)
+}
+
+func TestRateLimitPerRoute(t *testing.T) {
+	r := setupRateLimitTestRouter(ratelimit.NewMemoryStore(), middleware.RateLimitOptions{
+		Default: ratelimit.Limit{Requests: 5, Period: time.Minute},
+		Routes: map[string]ratelimit.Limit{
+			ratelimit.Route("GET", "/api/v1/students"): {Requests: 1, Period: time.Minute},
+			ratelimit.Route("GET", "/healthcheck"):     {},
+		},
+	})
+
+	assert.Equal(t, http.StatusOK, rateLimitedCall(r, "/api/v1/students", "10.0.0.1", "").Code)
+	w := rateLimitedCall(r, "/api/v1/students", "10.0.0.1", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	// Assert routes without their own limit use a separate bucket
+	w = rateLimitedCall(r, "/api/v1/students/1", "10.0.0.1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "5", w.Header().Get("RateLimit-Limit"))
+
+	// Assert routes can be exempt
+	for i := 0; i < 10; i++ {
+		w = rateLimitedCall(r, "/healthcheck", "10.0.0.1", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+}
+
+func TestRateLimitAllowsRequestsWhenStoreFails(t *testing.T) {
+	r := setupRateLimitTestRouter(failingStore{}, middleware.RateLimitOptions{
+		Default: ratelimit.Limit{Requests: 1, Period: time.Minute},
+	})
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, rateLimitedCall(r, "/api/v1/students", "10.0.0.1", "").Code)
+	}
+}
+
+func TestRateLimitByIPBeforeAuthentication(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	require.NoError(t, r.SetTrustedProxies([]string{"10.0.0.1"}))
+
+	// Authentication that rejects every request, counting the checks
+	checks := 0
+	rejectAll := func(c *gin.Context) {
+		checks++
+		c.AbortWithStatus(http.StatusUnauthorized)
+	}
+	r.Use(middleware.RateLimitByIP(ratelimit.NewMemoryStore(), middleware.RateLimitOptions{
+		PerIP: ratelimit.Limit{Requests: 2, Period: time.Minute},
+	}), rejectAll)
+	r.GET("/api/v1/students", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	call := func(remoteIP, forwardedFor string) int {
+		req, _ := http.NewRequest("GET", "/api/v1/students", nil)
+		req.RemoteAddr = remoteIP + ":12345"
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Assert bad credentials are throttled, and X-Forwarded-For from an
+	// untrusted address does not give a fresh bucket
+	assert.Equal(t, http.StatusUnauthorized, call("10.0.0.2", "203.0.113.1"))
+	assert.Equal(t, http.StatusUnauthorized, call("10.0.0.2", "203.0.113.2"))
+	assert.Equal(t, http.StatusTooManyRequests, call("10.0.0.2", "203.0.113.3"))
+	assert.Equal(t, 2, checks)
+
+	// Assert a trusted proxy's clients are told apart
+	assert.Equal(t, http.StatusUnauthorized, call("10.0.0.1", "203.0.113.1"))
+	assert.Equal(t, http.StatusUnauthorized, call("10.0.0.1", "203.0.113.1"))
+	assert.Equal(t, http.StatusTooManyRequests, call("10.0.0.1", "203.0.113.1"))
+	assert.Equal(t, http.StatusUnauthorized, call("10.0.0.1", "203.0.113.2"))
+}
@@ -0,0 +1,182 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/bournemouth-uni-it-api-go/config"
+	"github.com/bournemouth-uni-it-api-go/middleware"
+	"github.com/bournemouth-uni-it-api-go/router"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCORSOptions allows one other university web app
+var testCORSOptions = middleware.CORSOptions{
+	AllowedOrigins: []string{"https://timetable.bournemouth.ac.uk"},
+	AllowedMethods: []string{"GET", "POST"},
+	AllowedHeaders: []string{"Authorization", "Content-Type"},
+	MaxAge:         10 * time.Minute,
+}
+
+// setupSecurityTestRouter returns a router with the CORS and security header
+// middleware in front of an authenticated API route
+func setupSecurityTestRouter(cors middleware.CORSOptions) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.SecurityHeaders(middleware.SecurityOptions{HSTSMaxAge: 365 * 24 * time.Hour}))
+	r.Use(middleware.CORS(cors))
+
+	authenticator, _ := middleware.NewJWTAuthenticator(middleware.JWTOptions{HMACSecret: testHMACSecret})
+	r.GET("/api/v1/students", middleware.Authenticate(authenticator, nil, nil), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"data": []string{}})
+	})
+	return r
+}
+
+// corsRequest sends a request from origin with the given headers
+func corsRequest(r *gin.Engine, method, origin string, header map[string]string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, "/api/v1/students", nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORSPreflight(t *testing.T) {
+	r := setupSecurityTestRouter(testCORSOptions)
+
+	w := corsRequest(r, "OPTIONS", "https://timetable.bournemouth.ac.uk", map[string]string{
+		"Access-Control-Request-Method":  "GET",
+		"Access-Control-Request-Headers": "authorization",
+	})
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://timetable.bournemouth.ac.uk", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET, POST", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Authorization, Content-Type", w.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Contains(t, w.Header().Values("Vary"), "Origin")
+
+	// Assert preflights for other origins, methods and headers are refused
+	for name, tc := range map[string]struct {
+		origin string
+		header map[string]string
+	}{
+		"origin": {"https://evil.example", map[string]string{"Access-Control-Request-Method": "GET"}},
+		"method": {"https://timetable.bournemouth.ac.uk", map[string]string{"Access-Control-Request-Method": "DELETE"}},
+		"header": {"https://timetable.bournemouth.ac.uk", map[string]string{"Access-Control-Request-Method": "GET", "Access-Control-Request-Headers": "Authorization, X-Debug"}},
+	} {
+		w := corsRequest(r, "OPTIONS", tc.origin, tc.header)
+		assert.Equal(t, http.StatusForbidden, w.Code, name)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"), name)
+	}
+}
+
+func TestCORSRequests(t *testing.T) {
+	r := setupSecurityTestRouter(testCORSOptions)
+	token := signToken(t, jwt.SigningMethodHS256, []byte(testHMACSecret), "", validClaims())
+	auth := map[string]string{"Authorization": "Bearer " + token}
+
+	w := corsRequest(r, "GET", "https://timetable.bournemouth.ac.uk", auth)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "https://timetable.bournemouth.ac.uk", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, w.Header().Get("Access-Control-Expose-Headers"), "ETag")
+	assert.Contains(t, w.Header().Get("Access-Control-Expose-Headers"), "Retry-After")
+
+	// Assert other origins and same-origin requests get no CORS headers
+	w = corsRequest(r, "GET", "https://evil.example", auth)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	w = corsRequest(r, "GET", "", auth)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Vary"))
+
+	// Assert any origin can be allowed, without credentials
+	r = setupSecurityTestRouter(middleware.CORSOptions{
+		AllowedOrigins:   []string{"*"},
+		AllowedMethods:   []string{"GET"},
+		AllowCredentials: true,
+	})
+	w = corsRequest(r, "GET", "https://anywhere.example", auth)
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+
+	// Assert CORS is off without allowed origins
+	r = setupSecurityTestRouter(middleware.CORSOptions{})
+	w = corsRequest(r, "OPTIONS", "https://timetable.bournemouth.ac.uk", map[string]string{"Access-Control-Request-Method": "GET"})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	r := setupSecurityTestRouter(testCORSOptions)
+
+	// Assert errors carry the headers too
+	w := corsRequest(r, "GET", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "default-src 'none'; frame-ancestors 'none'", w.Header().Get("Content-Security-Policy"))
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+	assert.Equal(t, "no-referrer", w.Header().Get("Referrer-Policy"))
+	assert.Equal(t, "max-age=31536000; includeSubDomains", w.Header().Get("Strict-Transport-Security"))
+
+	// Assert HSTS can be turned off
+	r = gin.New()
+	r.Use(middleware.SecurityHeaders(middleware.SecurityOptions{}))
+	r.GET("/healthcheck", func(c *gin.Context) { c.Status(http.StatusOK) })
+	req, _ := http.NewRequest("GET", "/healthcheck", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Empty(t, w.Header().Get("Strict-Transport-Security"))
+	assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+}
+
+func TestIndexPageContentSecurityPolicy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{
+		JWT:      middleware.JWTOptions{HMACSecret: testHMACSecret},
+		Security: middleware.SecurityOptions{HSTSMaxAge: time.Hour},
+	}
+	r, err := router.SetupRouter(nil, cfg, nil)
+	require.NoError(t, err)
+
+	page := func() (*httptest.ResponseRecorder, string) {
+		req, _ := http.NewRequest("GET", "/", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		match := regexp.MustCompile(`script-src 'nonce-([^']+)'`).FindStringSubmatch(w.Header().Get("Content-Security-Policy"))
+		require.Len(t, match, 2)
+		return w, match[1]
+	}
+
+	w, nonce := page()
+	csp := w.Header().Get("Content-Security-Policy")
+	assert.Contains(t, csp, "style-src 'nonce-"+nonce+"'")
+	assert.Contains(t, csp, "frame-ancestors 'none'")
+	assert.NotContains(t, csp, "unsafe-inline")
+	assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+
+	// Assert the inline script and styles carry the nonce, and nothing
+	// relies on inline handlers or style attributes
+	body := w.Body.String()
+	assert.Contains(t, body, `<script nonce="`+nonce+`">`)
+	assert.Contains(t, body, `<style nonce="`+nonce+`">`)
+	assert.NotRegexp(t, `\son[a-z]+=`, body)
+	assert.NotContains(t, body, "style=")
+
+	// Assert every response gets a new nonce
+	_, other := page()
+	assert.NotEqual(t, nonce, other)
+}
@@ -0,0 +1,151 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// batchResponse mirrors the body returned by POST /api/v1/students:batch
+type batchResponse struct {
+	Committed bool `json:"committed"`
+	Results   []struct {
+		Index  int    `json:"index"`
+		Status int    `json:"status"`
+		Error  string `json:"error"`
+	} `json:"results"`
+}
+
+func newStudentJSON(email, studentID string) string {
+	data, _ := json.Marshal(models.Student{
+		FirstName:   "New",
+		LastName:    "Student",
+		Email:       email,
+		StudentID:   studentID,
+		Course:      "IT",
+		YearOfStudy: 1,
+	})
+	return string(data)
+}
+
+func TestBatchStudentsAtomicSuccess(t *testing.T) {
+	r, mockRepo := setupTestRouter()
+	tx := &MockStudentTx{mockRepo}
+
+	// Mock data
+	existingStudent := &models.Student{ID: 7, FirstName: "John", LastName: "Doe", Version: 1, ETag: `"1"`}
+
+	// Set expectations
+	mockRepo.On("Begin").Return(tx, nil)
+	mockRepo.On("Create", mock.AnythingOfType("*models.Student")).Return(nil)
+	mockRepo.On("GetByID", 7).Return(existingStudent, nil)
+	mockRepo.On("Delete", 7, 1).Return(nil)
+	mockRepo.On("Commit").Return(nil)
+	mockRepo.On("Rollback").Return(nil)
+
+	// Create request
+	body := `{"operations": [
+		{"op": "create", "student": ` + newStudentJSON("a@example.com", "S1") + `},
+		{"op": "delete", "id": 7, "if_match": "\"1\""}
+	]}`
+	req, _ := http.NewRequest("POST", "/api/v1/students:batch", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response batchResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.True(t, response.Committed)
+	assert.Equal(t, http.StatusCreated, response.Results[0].Status)
+	assert.Equal(t, http.StatusOK, response.Results[1].Status)
+	mockRepo.AssertCalled(t, "Commit")
+}
+
+func TestBatchStudentsAtomicFailure(t *testing.T) {
+	r, mockRepo := setupTestRouter()
+	tx := &MockStudentTx{mockRepo}
+
+	// Set expectations
+	mockRepo.On("Begin").Return(tx, nil)
+	mockRepo.On("Create", mock.MatchedBy(func(s *models.Student) bool { return s.Email == "a@example.com" })).Return(nil)
+	mockRepo.On("Create", mock.MatchedBy(func(s *models.Student) bool { return s.Email == "dup@example.com" })).
+		Return(&pq.Error{Code: "23505", Message: `duplicate key value violates unique constraint "students_email_key"`})
+	mockRepo.On("Rollback").Return(nil)
+
+	// Create request
+	body := `{"operations": [
+		{"op": "create", "student": ` + newStudentJSON("a@example.com", "S1") + `},
+		{"op": "create", "student": ` + newStudentJSON("dup@example.com", "S2") + `},
+		{"op": "create", "student": ` + newStudentJSON("c@example.com", "S3") + `}
+	]}`
+	req, _ := http.NewRequest("POST", "/api/v1/students:batch", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	var response batchResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.False(t, response.Committed)
+	assert.Equal(t, http.StatusFailedDependency, response.Results[0].Status)
+	assert.Equal(t, http.StatusConflict, response.Results[1].Status)
+	assert.Equal(t, "Email already exists", response.Results[1].Error)
+	assert.Equal(t, http.StatusFailedDependency, response.Results[2].Status)
+	mockRepo.AssertNotCalled(t, "Commit")
+}
+
+func TestBatchStudentsBestEffort(t *testing.T) {
+	r, mockRepo := setupTestRouter()
+	tx := &MockStudentTx{mockRepo}
+
+	// Mock data
+	existingStudent := &models.Student{ID: 7, FirstName: "John", LastName: "Doe", Version: 2, ETag: `"2"`}
+
+	// Set expectations
+	mockRepo.On("Begin").Return(tx, nil)
+	mockRepo.On("Create", mock.AnythingOfType("*models.Student")).Return(nil)
+	mockRepo.On("GetByID", 7).Return(existingStudent, nil)
+	mockRepo.On("Commit").Return(nil)
+	mockRepo.On("Rollback").Return(nil)
+
+	// Create request
+	body := `{"operations": [
+		{"op": "create", "student": ` + newStudentJSON("a@example.com", "S1") + `},
+		{"op": "create", "student": ` + newStudentJSON("not-an-email", "S2") + `},
+		{"op": "delete", "id": 7},
+		{"op": "update", "id": 7, "if_match": "\"1\"", "student": ` + newStudentJSON("b@example.com", "S3") + `},
+		{"op": "rename"}
+	]}`
+	req, _ := http.NewRequest("POST", "/api/v1/students:batch?atomic=false", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+
+	var response batchResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.True(t, response.Committed)
+	assert.Equal(t, http.StatusCreated, response.Results[0].Status)
+	assert.Equal(t, http.StatusBadRequest, response.Results[1].Status)
+	assert.Equal(t, http.StatusPreconditionRequired, response.Results[2].Status)
+	assert.Equal(t, http.StatusPreconditionFailed, response.Results[3].Status)
+	assert.Equal(t, http.StatusBadRequest, response.Results[4].Status)
+	mockRepo.AssertCalled(t, "Commit")
+}
@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xuri/excelize/v2"
+)
+
+// exportStudents is the data returned by the mock repository for exports
+var exportStudents = []models.Student{
+	{ID: 1, FirstName: "John", LastName: "Doe", Email: "john@example.com", StudentID: "S12345", Course: "IT", YearOfStudy: 2, Status: models.StatusEnrolled,
+		CreatedAt: time.Date(2024, 9, 1, 9, 0, 0, 0, time.UTC), UpdatedAt: time.Date(2025, 1, 6, 12, 30, 0, 0, time.UTC)},
+	{ID: 2, FirstName: "=cmd()", LastName: "Smith", Email: "jane@example.com", StudentID: "S67891", Course: "IT", YearOfStudy: 3, Status: models.StatusInterrupted},
+}
+
+// exportedHeader and exportedJohn are the header and first row expected in
+// CSV and XLSX exports
+var (
+	exportedHeader = []string{"id", "student_id", "first_name", "last_name", "email", "course", "year_of_study", "status", "created_at", "updated_at"}
+	exportedJohn   = []string{"1", "S12345", "John", "Doe", "john@example.com", "IT", "2", "enrolled", "2024-09-01T09:00:00Z", "2025-01-06T12:30:00Z"}
+)
+
+func TestExportStudentsCSV(t *testing.T) {
+	r, mockRepo := setupTestRouter()
+
+	// Set expectations
+	mockRepo.On("Stream", models.StudentFilter{Course: "IT"}, mock.Anything).Return(exportStudents, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/api/v1/students/export?format=csv&course=IT", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "attachment; filename=\"students-")
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, records, 3)
+	assert.Equal(t, exportedHeader, records[0])
+	assert.Equal(t, exportedJohn, records[1])
+	assert.Equal(t, "'=cmd()", records[2][2])
+	assert.Equal(t, "interrupted", records[2][7])
+}
+
+func TestExportStudentsNDJSON(t *testing.T) {
+	r, mockRepo := setupTestRouter()
+
+	// Set expectations
+	mockRepo.On("Stream", models.StudentFilter{}, mock.Anything).Return(exportStudents, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/api/v1/students/export?format=ndjson", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+	scanner := bufio.NewScanner(w.Body)
+	var lines []models.Student
+	for scanner.Scan() {
+		var s models.Student
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &s))
+		lines = append(lines, s)
+	}
+	assert.Len(t, lines, 2)
+	assert.Equal(t, "=cmd()", lines[1].FirstName)
+}
+
+func TestExportStudentsXLSX(t *testing.T) {
+	r, mockRepo := setupTestRouter()
+
+	// Set expectations
+	mockRepo.On("Stream", models.StudentFilter{}, mock.Anything).Return(exportStudents, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/api/v1/students/export?format=xlsx", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	f, err := excelize.OpenReader(bytes.NewReader(w.Body.Bytes()))
+	assert.NoError(t, err)
+	rows, err := f.GetRows("Students")
+	assert.NoError(t, err)
+	assert.Len(t, rows, 3)
+	assert.Equal(t, exportedHeader, rows[0])
+	assert.Equal(t, exportedJohn, rows[1])
+	assert.Equal(t, "interrupted", rows[2][7])
+}
+
+func TestExportStudentsErrors(t *testing.T) {
+	r, mockRepo := setupTestRouter()
+
+	// Set expectations
+	mockRepo.On("Stream", models.StudentFilter{}, mock.Anything).Return([]models.Student{}, errors.New("connection refused"))
+
+	// Test unknown format
+	req, _ := http.NewRequest("GET", "/api/v1/students/export?format=pdf", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Test failure before any row is sent
+	req, _ = http.NewRequest("GET", "/api/v1/students/export", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/bournemouth-uni-it-api-go/handlers"
 	"github.com/bournemouth-uni-it-api-go/models"
@@ -17,11 +18,31 @@ import (
 // MockStudentRepository is a mock implementation of StudentRepository
 type MockStudentRepository struct {
 	mock.Mock
+	audits []models.Audit
 }
 
-func (m *MockStudentRepository) GetAll() ([]models.Student, error) {
-	args := m.Called()
-	return args.Get(0).([]models.Student), args.Error(1)
+func (m *MockStudentRepository) List(opts models.StudentListOptions) (*models.StudentPage, error) {
+	args := m.Called(opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.StudentPage), args.Error(1)
+}
+
+func (m *MockStudentRepository) Stream(filter models.StudentFilter, fn func(*models.Student) error) error {
+	args := m.Called(filter, fn)
+	for _, s := range args.Get(0).([]models.Student) {
+		s := s
+		if err := fn(&s); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockStudentRepository) Search(query string, limit int) ([]models.StudentSearchResult, error) {
+	args := m.Called(query, limit)
+	return args.Get(0).([]models.StudentSearchResult), args.Error(1)
 }
 
 func (m *MockStudentRepository) GetByID(id int) (*models.Student, error) {
@@ -32,18 +53,106 @@ func (m *MockStudentRepository) GetByID(id int) (*models.Student, error) {
 	return args.Get(0).(*models.Student), args.Error(1)
 }
 
+func (m *MockStudentRepository) GetByStudentID(studentID string) (*models.Student, error) {
+	args := m.Called(studentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Student), args.Error(1)
+}
+
+func (m *MockStudentRepository) GetByEmail(email string) (*models.Student, error) {
+	args := m.Called(email)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Student), args.Error(1)
+}
+
 func (m *MockStudentRepository) Create(student *models.Student) error {
 	args := m.Called(student)
 	return args.Error(0)
 }
 
+func (m *MockStudentRepository) Upsert(student *models.Student) (bool, error) {
+	args := m.Called(student)
+	return args.Bool(0), args.Error(1)
+}
+
 func (m *MockStudentRepository) Update(student *models.Student) error {
 	args := m.Called(student)
 	return args.Error(0)
 }
 
-func (m *MockStudentRepository) Delete(id int) error {
+func (m *MockStudentRepository) UpdateFields(id, version int, fields map[string]interface{}) (*models.Student, error) {
+	args := m.Called(id, version, fields)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Student), args.Error(1)
+}
+
+func (m *MockStudentRepository) Delete(id, version int) error {
+	args := m.Called(id, version)
+	return args.Error(0)
+}
+
+func (m *MockStudentRepository) Restore(id int) (*models.Student, error) {
 	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Student), args.Error(1)
+}
+
+func (m *MockStudentRepository) History(id int) ([]models.StudentHistoryEntry, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.StudentHistoryEntry), args.Error(1)
+}
+
+func (m *MockStudentRepository) GetAsOf(id int, at time.Time) (*models.Student, error) {
+	args := m.Called(id, at)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Student), args.Error(1)
+}
+
+// WithAudit records the audit details and returns the same mock, so that
+// expectations set on the mock apply to the audited repository
+func (m *MockStudentRepository) WithAudit(a models.Audit) models.StudentRepository {
+	m.audits = append(m.audits, a)
+	return m
+}
+
+func (m *MockStudentRepository) Begin() (models.StudentTx, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(models.StudentTx), args.Error(1)
+}
+
+// MockStudentTx is a mock transaction that shares the expectations of the
+// repository it was started from
+type MockStudentTx struct {
+	*MockStudentRepository
+}
+
+func (m *MockStudentTx) Try(fn func() error) error {
+	return fn()
+}
+
+func (m *MockStudentTx) Commit() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockStudentTx) Rollback() error {
+	args := m.Called()
 	return args.Error(0)
 }
 
@@ -60,15 +169,34 @@ func setupTestRouter() (*gin.Engine, *MockStudentRepository) {
 
 	// Set up routes
 	r.GET("/api/v1/students", handler.GetAllStudents)
+	r.GET("/api/v1/students/search", handler.SearchStudents)
+	r.GET("/api/v1/students/export", handler.ExportStudents)
+	r.GET("/api/v1/students/by-student-id/:student_id", handler.GetStudentByStudentID)
+	r.GET("/api/v1/students/by-email/:email", handler.GetStudentByEmail)
 	r.GET("/api/v1/students/:id", handler.GetStudentByID)
 	r.POST("/api/v1/students", handler.CreateStudent)
+	r.POST("/api/v1/students/import", handler.ImportStudents)
 	r.PUT("/api/v1/students/:id", handler.UpdateStudent)
+	r.PATCH("/api/v1/students/:id", handler.PatchStudent)
 	r.DELETE("/api/v1/students/:id", handler.DeleteStudent)
+	r.GET("/api/v1/students/:id/history", handler.GetStudentHistory)
+	r.POST("/api/v1/students/:id/restore", handler.RestoreStudent)
+	r.POST("/api/v1/:action", handler.BatchStudents)
 	r.GET("/healthcheck", handler.HealthCheck)
 
 	return r, mockRepo
 }
 
+// studentListResponse mirrors the envelope returned by GET /api/v1/students
+type studentListResponse struct {
+	Data []models.Student `json:"data"`
+	Meta struct {
+		Total      int    `json:"total"`
+		Limit      int    `json:"limit"`
+		NextCursor string `json:"next_cursor"`
+	} `json:"meta"`
+}
+
 func TestGetAllStudents(t *testing.T) {
 	r, mockRepo := setupTestRouter()
 
@@ -79,7 +207,8 @@ func TestGetAllStudents(t *testing.T) {
 	}
 
 	// Set expectations
-	mockRepo.On("GetAll").Return(students, nil)
+	mockRepo.On("List", models.StudentListOptions{Limit: models.DefaultStudentPageSize, Sort: "id"}).
+		Return(&models.StudentPage{Students: students, NextCursor: "abc", Total: 5}, nil)
 
 	// Create request
 	req, _ := http.NewRequest("GET", "/api/v1/students", nil)
@@ -89,12 +218,65 @@ func TestGetAllStudents(t *testing.T) {
 	// Assert response
 	assert.Equal(t, http.StatusOK, w.Code)
 
-	var response []models.Student
+	var response studentListResponse
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
-	assert.Len(t, response, 2)
-	assert.Equal(t, students[0].ID, response[0].ID)
-	assert.Equal(t, students[1].ID, response[1].ID)
+	assert.Len(t, response.Data, 2)
+	assert.Equal(t, students[0].ID, response.Data[0].ID)
+	assert.Equal(t, students[1].ID, response.Data[1].ID)
+	assert.Equal(t, 5, response.Meta.Total)
+	assert.Equal(t, "abc", response.Meta.NextCursor)
+}
+
+func TestGetAllStudentsWithQueryOptions(t *testing.T) {
+	r, mockRepo := setupTestRouter()
+
+	createdAfter := time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)
+	expected := models.StudentListOptions{
+		Filter: models.StudentFilter{
+			Course:       "Computer Science",
+			YearOfStudy:  2,
+			CreatedAfter: &createdAfter,
+		},
+		Limit:  10,
+		Cursor: "abc",
+		Sort:   "last_name",
+		Desc:   true,
+	}
+
+	// Set expectations
+	mockRepo.On("List", expected).Return(&models.StudentPage{Students: []models.Student{}}, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/api/v1/students?limit=10&cursor=abc&sort=last_name&order=desc&course=Computer+Science&year_of_study=2&created_after=2024-09-01", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetAllStudentsInvalidQuery(t *testing.T) {
+	r, mockRepo := setupTestRouter()
+
+	mockRepo.On("List", mock.Anything).Return(nil, models.ErrInvalidCursor)
+
+	for _, query := range []string{
+		"limit=0",
+		"limit=1000",
+		"sort=password",
+		"order=sideways",
+		"year_of_study=first",
+		"created_after=yesterday",
+		"cursor=not-a-cursor",
+	} {
+		req, _ := http.NewRequest("GET", "/api/v1/students?"+query, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
 }
 
 func TestGetStudentByID(t *testing.T) {
@@ -129,6 +311,126 @@ func TestGetStudentByID(t *testing.T) {
 	assert.Equal(t, http.StatusNotFound, w.Code)
 }
 
+func TestGetStudentByStudentID(t *testing.T) {
+	r, mockRepo := setupTestRouter()
+
+	// Mock data
+	student := &models.Student{ID: 1, FirstName: "John", LastName: "Doe", Email: "john@example.com", StudentID: "S12345678", Course: "IT", YearOfStudy: 2}
+
+	// Set expectations
+	mockRepo.On("GetByStudentID", "S12345678").Return(student, nil)
+	mockRepo.On("GetByStudentID", "S00000000").Return(nil, nil)
+
+	// Test existing student
+	req, _ := http.NewRequest("GET", "/api/v1/students/by-student-id/S12345678", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.Student
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, student.ID, response.ID)
+
+	// Test non-existing student
+	req, _ = http.NewRequest("GET", "/api/v1/students/by-student-id/S00000000", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetStudentByEmail(t *testing.T) {
+	r, mockRepo := setupTestRouter()
+
+	// Mock data
+	student := &models.Student{ID: 1, FirstName: "John", LastName: "Doe", Email: "john@example.com", StudentID: "S12345678", Course: "IT", YearOfStudy: 2}
+
+	// Set expectations
+	mockRepo.On("GetByEmail", "john@example.com").Return(student, nil)
+	mockRepo.On("GetByEmail", "nobody@example.com").Return(nil, nil)
+
+	// Test existing student
+	req, _ := http.NewRequest("GET", "/api/v1/students/by-email/john@example.com", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.Student
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, student.Email, response.Email)
+
+	// Test non-existing student
+	req, _ = http.NewRequest("GET", "/api/v1/students/by-email/nobody@example.com", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Test malformed email
+	req, _ = http.NewRequest("GET", "/api/v1/students/by-email/not-an-email", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestSearchStudents(t *testing.T) {
+	r, mockRepo := setupTestRouter()
+
+	// Mock data
+	results := []models.StudentSearchResult{
+		{
+			Student:    models.Student{ID: 2, FirstName: "Jane", LastName: "Smith", Email: "jane@example.com", StudentID: "S67891", Course: "IT", YearOfStudy: 3},
+			Rank:       0.8,
+			Highlights: map[string]string{"last_name": "<mark>Smi</mark>th"},
+		},
+	}
+
+	// Set expectations
+	mockRepo.On("Search", "smi", models.DefaultStudentSearchLimit).Return(results, nil)
+
+	// Test matching query
+	req, _ := http.NewRequest("GET", "/api/v1/students/search?q=smi", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Data []models.StudentSearchResult `json:"data"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Len(t, response.Data, 1)
+	assert.Equal(t, "Smith", response.Data[0].LastName)
+	assert.Equal(t, "<mark>Smi</mark>th", response.Data[0].Highlights["last_name"])
+
+	// Test missing query
+	req, _ = http.NewRequest("GET", "/api/v1/students/search?q=%20", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHighlightMatches(t *testing.T) {
+	assert.Equal(t, "<mark>Smi</mark>th", models.HighlightMatches("Smith", []string{"smi"}))
+	assert.Equal(t, "<mark>jane</mark>.<mark>smi</mark>th@example.com", models.HighlightMatches("jane.smith@example.com", models.SearchTerms("Jane Smi")))
+	assert.Equal(t, "O&#39;<mark>Neil</mark>", models.HighlightMatches("O'Neil", []string{"neil"}))
+	assert.Equal(t, "", models.HighlightMatches("Smith", []string{"smyth"}))
+}
+
 func TestCreateStudent(t *testing.T) {
 	r, mockRepo := setupTestRouter()
 
@@ -169,7 +471,7 @@ func TestUpdateStudent(t *testing.T) {
 	r, mockRepo := setupTestRouter()
 
 	// Mock data
-	existingStudent := &models.Student{ID: 1, FirstName: "John", LastName: "Doe"}
+	existingStudent := &models.Student{ID: 1, FirstName: "John", LastName: "Doe", Version: 1, ETag: `"1"`}
 	updatedStudent := models.Student{
 		FirstName:   "Updated",
 		LastName:    "Student",
@@ -188,6 +490,7 @@ func TestUpdateStudent(t *testing.T) {
 	jsonData, _ := json.Marshal(updatedStudent)
 	req, _ := http.NewRequest("PUT", "/api/v1/students/1", bytes.NewBuffer(jsonData))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
@@ -197,6 +500,7 @@ func TestUpdateStudent(t *testing.T) {
 	// Test updating non-existing student
 	req, _ = http.NewRequest("PUT", "/api/v1/students/999", bytes.NewBuffer(jsonData))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
 	w = httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
@@ -204,19 +508,126 @@ func TestUpdateStudent(t *testing.T) {
 	assert.Equal(t, http.StatusNotFound, w.Code)
 }
 
+func TestPatchStudentMergePatch(t *testing.T) {
+	r, mockRepo := setupTestRouter()
+
+	// Mock data
+	existingStudent := &models.Student{ID: 1, FirstName: "John", LastName: "Doe", Email: "john@example.com", StudentID: "S12345", Course: "IT", YearOfStudy: 2, Version: 1, ETag: `"1"`}
+	patchedStudent := *existingStudent
+	patchedStudent.Course = "Computer Science"
+
+	// Set expectations
+	mockRepo.On("GetByID", 1).Return(existingStudent, nil)
+	mockRepo.On("GetByID", 999).Return(nil, nil)
+	mockRepo.On("UpdateFields", 1, 1, map[string]interface{}{"course": "Computer Science"}).Return(&patchedStudent, nil)
+
+	// Test patching only the course
+	req, _ := http.NewRequest("PATCH", "/api/v1/students/1", bytes.NewBufferString(`{"course": "Computer Science"}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req.Header.Set("If-Match", `"1"`)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.Student
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "Computer Science", response.Course)
+
+	// Test removing a required field
+	req, _ = http.NewRequest("PATCH", "/api/v1/students/1", bytes.NewBufferString(`{"first_name": null}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req.Header.Set("If-Match", `"1"`)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	// Test patching non-existing student
+	req, _ = http.NewRequest("PATCH", "/api/v1/students/999", bytes.NewBufferString(`{"course": "IT"}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req.Header.Set("If-Match", `"1"`)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPatchStudentJSONPatch(t *testing.T) {
+	r, mockRepo := setupTestRouter()
+
+	// Mock data
+	existingStudent := &models.Student{ID: 1, FirstName: "John", LastName: "Doe", Email: "john@example.com", StudentID: "S12345", Course: "IT", YearOfStudy: 2, Version: 1, ETag: `"1"`}
+	patchedStudent := *existingStudent
+	patchedStudent.YearOfStudy = 3
+
+	// Set expectations
+	mockRepo.On("GetByID", 1).Return(existingStudent, nil)
+	mockRepo.On("UpdateFields", 1, 1, map[string]interface{}{"year_of_study": 3}).Return(&patchedStudent, nil)
+
+	// Test a passing test-and-replace patch
+	req, _ := http.NewRequest("PATCH", "/api/v1/students/1", bytes.NewBufferString(
+		`[{"op": "test", "path": "/year_of_study", "value": 2}, {"op": "replace", "path": "/year_of_study", "value": 3}]`))
+	req.Header.Set("Content-Type", "application/json-patch+json")
+	req.Header.Set("If-Match", `"1"`)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockRepo.AssertExpectations(t)
+
+	// Test a failing test operation
+	req, _ = http.NewRequest("PATCH", "/api/v1/students/1", bytes.NewBufferString(
+		`[{"op": "test", "path": "/year_of_study", "value": 1}, {"op": "replace", "path": "/year_of_study", "value": 3}]`))
+	req.Header.Set("Content-Type", "application/json-patch+json")
+	req.Header.Set("If-Match", `"1"`)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	// Test adding a read-only field
+	req, _ = http.NewRequest("PATCH", "/api/v1/students/1", bytes.NewBufferString(`[{"op": "add", "path": "/id", "value": 7}]`))
+	req.Header.Set("Content-Type", "application/json-patch+json")
+	req.Header.Set("If-Match", `"1"`)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	// Test unsupported content type
+	req, _ = http.NewRequest("PATCH", "/api/v1/students/1", bytes.NewBufferString(`{"course": "IT"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
+
 func TestDeleteStudent(t *testing.T) {
 	r, mockRepo := setupTestRouter()
 
 	// Mock data
-	existingStudent := &models.Student{ID: 1, FirstName: "John", LastName: "Doe"}
+	existingStudent := &models.Student{ID: 1, FirstName: "John", LastName: "Doe", Version: 1, ETag: `"1"`}
 
 	// Set expectations
 	mockRepo.On("GetByID", 1).Return(existingStudent, nil)
 	mockRepo.On("GetByID", 999).Return(nil, nil)
-	mockRepo.On("Delete", 1).Return(nil)
+	mockRepo.On("Delete", 1, 1).Return(nil)
 
 	// Test deleting existing student
 	req, _ := http.NewRequest("DELETE", "/api/v1/students/1", nil)
+	req.Header.Set("If-Match", `"1"`)
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
@@ -225,6 +636,7 @@ func TestDeleteStudent(t *testing.T) {
 
 	// Test deleting non-existing student
 	req, _ = http.NewRequest("DELETE", "/api/v1/students/999", nil)
+	req.Header.Set("If-Match", `"1"`)
 	w = httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
@@ -232,6 +644,105 @@ func TestDeleteStudent(t *testing.T) {
 	assert.Equal(t, http.StatusNotFound, w.Code)
 }
 
+func TestGetStudentByIDConditional(t *testing.T) {
+	r, mockRepo := setupTestRouter()
+
+	// Mock data
+	student := &models.Student{ID: 1, FirstName: "John", LastName: "Doe", Version: 4, ETag: `"4"`}
+
+	// Set expectations
+	mockRepo.On("GetByID", 1).Return(student, nil)
+
+	// Test ETag is returned
+	req, _ := http.NewRequest("GET", "/api/v1/students/1", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"4"`, w.Header().Get("ETag"))
+
+	// Test matching If-None-Match
+	req, _ = http.NewRequest("GET", "/api/v1/students/1", nil)
+	req.Header.Set("If-None-Match", `"3", "4"`)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+}
+
+func TestGetAllStudentsConditional(t *testing.T) {
+	r, mockRepo := setupTestRouter()
+
+	// Set expectations
+	mockRepo.On("List", mock.Anything).Return(&models.StudentPage{Students: []models.Student{{ID: 1, Version: 2, ETag: `"2"`}}, Total: 1}, nil)
+
+	// Test ETag is returned
+	req, _ := http.NewRequest("GET", "/api/v1/students", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	// Test matching If-None-Match
+	req, _ = http.NewRequest("GET", "/api/v1/students", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusNotModified, w.Code)
+}
+
+func TestWritePreconditions(t *testing.T) {
+	r, mockRepo := setupTestRouter()
+
+	// Mock data
+	existingStudent := &models.Student{ID: 1, FirstName: "John", LastName: "Doe", Email: "john@example.com", StudentID: "S12345", Course: "IT", YearOfStudy: 2, Version: 2, ETag: `"2"`}
+	jsonData, _ := json.Marshal(existingStudent)
+
+	// Set expectations
+	mockRepo.On("GetByID", 1).Return(existingStudent, nil)
+	mockRepo.On("Update", mock.AnythingOfType("*models.Student")).Return(models.ErrVersionConflict)
+
+	for _, method := range []string{"PUT", "PATCH", "DELETE"} {
+		// Test missing If-Match
+		req, _ := http.NewRequest(method, "/api/v1/students/1", bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		// Assert response
+		assert.Equal(t, http.StatusPreconditionRequired, w.Code, method)
+
+		// Test stale If-Match
+		req, _ = http.NewRequest(method, "/api/v1/students/1", bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		req.Header.Set("If-Match", `"1"`)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		// Assert response
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code, method)
+		assert.Equal(t, `"2"`, w.Header().Get("ETag"), method)
+	}
+
+	// Test concurrent write between read and update
+	req, _ := http.NewRequest("PUT", "/api/v1/students/1", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"2"`)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+}
+
 func TestHealthCheck(t *testing.T) {
 	r, _ := setupTestRouter()
 
@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bournemouth-uni-it-api-go/handlers"
+	"github.com/bournemouth-uni-it-api-go/middleware"
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetStudentHistory(t *testing.T) {
+	r, mockRepo := setupTestRouter()
+
+	// Mock data
+	entries := []models.StudentHistoryEntry{
+		{ID: 1, StudentID: 1, Action: "create", ChangedFields: []string{"email", "first_name"}, After: json.RawMessage(`{"email":"john@example.com"}`), Actor: "registry"},
+		{ID: 2, StudentID: 1, Action: "update", ChangedFields: []string{"email"}, Before: json.RawMessage(`{"email":"john@example.com"}`), After: json.RawMessage(`{"email":"jd@example.com"}`), Actor: "registry", RequestID: "abc"},
+	}
+
+	// Set expectations
+	mockRepo.On("History", 1).Return(entries, nil)
+	mockRepo.On("History", 999).Return(nil, models.ErrStudentNotFound)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/api/v1/students/1/history", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response []map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Len(t, response, 2)
+	assert.Nil(t, response[0]["before"])
+	assert.Equal(t, "jd@example.com", response[1]["after"].(map[string]interface{})["email"])
+
+	// Test a student that does not exist
+	req, _ = http.NewRequest("GET", "/api/v1/students/999/history", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetStudentAsOf(t *testing.T) {
+	r, mockRepo := setupTestRouter()
+
+	// Set expectations
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	mockRepo.On("GetAsOf", 1, mock.MatchedBy(at.Equal)).Return(&models.Student{ID: 1, Email: "john@example.com", Version: 2, ETag: `"2"`}, nil)
+	mockRepo.On("GetAsOf", 1, mock.MatchedBy(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).Equal)).Return(nil, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/api/v1/students/1?as_of=2024-03-01T12:00:00Z", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+	assert.Contains(t, w.Body.String(), "john@example.com")
+
+	// Test a time before the student existed
+	req, _ = http.NewRequest("GET", "/api/v1/students/1?as_of=2020-01-01", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Test an invalid time
+	req, _ = http.NewRequest("GET", "/api/v1/students/1?as_of=last-week", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mockRepo.AssertNotCalled(t, "GetByID", mock.Anything)
+}
+
+func TestStudentChangesAreAudited(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.RequestID())
+	r.Use(func(c *gin.Context) {
+		if subject := c.GetHeader("X-Test-Subject"); subject != "" {
+			c.Set(middleware.PrincipalKey, &middleware.Principal{Subject: subject})
+		}
+	})
+	mockRepo := new(MockStudentRepository)
+	handler := &handlers.StudentHandler{Repo: mockRepo}
+	r.DELETE("/api/v1/students/:id", handler.DeleteStudent)
+
+	// Set expectations
+	mockRepo.On("GetByID", 1).Return(&models.Student{ID: 1, Version: 1, ETag: `"1"`}, nil)
+	mockRepo.On("Delete", 1, 1).Return(nil)
+
+	// Create request from an authenticated principal with a request ID
+	req, _ := http.NewRequest("DELETE", "/api/v1/students/1", nil)
+	req.Header.Set("If-Match", `"1"`)
+	req.Header.Set("X-Test-Subject", "registry")
+	req.Header.Set(middleware.RequestIDHeader, "req-42")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "req-42", w.Header().Get(middleware.RequestIDHeader))
+	assert.Equal(t, []models.Audit{{Actor: "registry", RequestID: "req-42"}}, mockRepo.audits)
+
+	// Create request with neither
+	req, _ = http.NewRequest("DELETE", "/api/v1/students/1", nil)
+	req.Header.Set("If-Match", `"1"`)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert a request ID was generated and the actor is anonymous
+	generated := w.Header().Get(middleware.RequestIDHeader)
+	assert.Len(t, generated, 32)
+	assert.Equal(t, models.Audit{Actor: "anonymous", RequestID: generated}, mockRepo.audits[1])
+}
@@ -0,0 +1,182 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bournemouth-uni-it-api-go/handlers"
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// importResponse mirrors the body returned by POST /api/v1/students/import
+type importResponse struct {
+	Committed   bool `json:"committed"`
+	TotalRows   int  `json:"total_rows"`
+	InvalidRows int  `json:"invalid_rows"`
+	Created     int  `json:"created"`
+	Updated     int  `json:"updated"`
+	Rows        []struct {
+		Row    int      `json:"row"`
+		Action string   `json:"action"`
+		Errors []string `json:"errors"`
+	} `json:"rows"`
+}
+
+// newImportRequest builds a multipart upload of csvData
+func newImportRequest(url, csvData string, mapping string) *http.Request {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "students.csv")
+	_, _ = part.Write([]byte(csvData))
+	if mapping != "" {
+		_ = writer.WriteField("mapping", mapping)
+	}
+	_ = writer.Close()
+
+	req, _ := http.NewRequest("POST", url, body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestImportStudentsDryRun(t *testing.T) {
+	r, mockRepo := setupTestRouter()
+	tx := &MockStudentTx{mockRepo}
+
+	// Set expectations
+	mockRepo.On("Begin").Return(tx, nil)
+	mockRepo.On("Upsert", mock.MatchedBy(func(s *models.Student) bool { return s.StudentID == "S1" })).Return(true, nil)
+	mockRepo.On("Upsert", mock.MatchedBy(func(s *models.Student) bool { return s.StudentID == "S2" })).Return(false, nil)
+	mockRepo.On("Rollback").Return(nil)
+
+	// Create request
+	csvData := "Forename,Surname,Email Address,Student Number,Programme,Year\n" +
+		"Amy,Lee,amy@example.com,S1,IT,1\n" +
+		"Ben,Ray,ben@example.com,S2,IT,2\n" +
+		",Fox,not-an-email,S3,IT,first\n" +
+		"Dup,Row,dup@example.com,S1,IT,1\n"
+	req := newImportRequest("/api/v1/students/import?dry_run=true", csvData, "")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	var response importResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.False(t, response.Committed)
+	assert.Equal(t, 4, response.TotalRows)
+	assert.Equal(t, 2, response.InvalidRows)
+	assert.Equal(t, "create", response.Rows[0].Action)
+	assert.Equal(t, "update", response.Rows[1].Action)
+	assert.Equal(t, 4, response.Rows[2].Row)
+	assert.ElementsMatch(t, []string{"year_of_study must be a whole number", "first_name is required", "Invalid email format"}, response.Rows[2].Errors)
+	assert.Equal(t, []string{"student_id duplicates row 2"}, response.Rows[3].Errors)
+	mockRepo.AssertNumberOfCalls(t, "Upsert", 2)
+	mockRepo.AssertNotCalled(t, "Commit")
+}
+
+func TestImportStudentsDryRunMatchesCommit(t *testing.T) {
+	r, mockRepo := setupTestRouter()
+	tx := &MockStudentTx{mockRepo}
+
+	// Set expectations
+	mockRepo.On("Begin").Return(tx, nil)
+	mockRepo.On("Upsert", mock.MatchedBy(func(s *models.Student) bool { return s.StudentID == "S1" })).Return(true, nil)
+	mockRepo.On("Upsert", mock.MatchedBy(func(s *models.Student) bool { return s.StudentID == "S2" })).Return(false, &pq.Error{Code: "23503"})
+	mockRepo.On("Upsert", mock.MatchedBy(func(s *models.Student) bool { return s.StudentID == "S3" })).Return(false, models.ErrStudentDeleted)
+	mockRepo.On("Rollback").Return(nil)
+
+	// Create request
+	csvData := "first_name,last_name,email,student_id,course,year_of_study\n" +
+		"Amy,Lee,amy@example.com,S1,IT,1\n" +
+		"Ben,Ray,ben@example.com,S2,NOPE,1\n" +
+		"Cat,Fox,cat@example.com,S3,IT,1\n" +
+		"Dan,Orr,amy@example.com,S4,IT,1\n"
+	req := newImportRequest("/api/v1/students/import?dry_run=true", csvData, "")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	var response importResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, response.InvalidRows)
+	assert.Equal(t, 1, response.Created)
+	assert.Equal(t, "create", response.Rows[0].Action)
+	assert.Equal(t, []string{"Course does not exist"}, response.Rows[1].Errors)
+	assert.Equal(t, []string{"Student ID belongs to a deleted student, restore it instead"}, response.Rows[2].Errors)
+	assert.Equal(t, []string{"email duplicates row 2"}, response.Rows[3].Errors)
+	mockRepo.AssertNotCalled(t, "Commit")
+}
+
+func TestImportStudents(t *testing.T) {
+	r, mockRepo := setupTestRouter()
+	tx := &MockStudentTx{mockRepo}
+
+	// Set expectations
+	mockRepo.On("Begin").Return(tx, nil)
+	mockRepo.On("Upsert", mock.MatchedBy(func(s *models.Student) bool { return s.StudentID == "S1" })).Return(true, nil)
+	mockRepo.On("Upsert", mock.MatchedBy(func(s *models.Student) bool { return s.StudentID == "S2" })).Return(false, nil)
+	mockRepo.On("Commit").Return(nil)
+	mockRepo.On("Rollback").Return(nil)
+
+	// Create request with an explicit header mapping
+	csvData := "Given,Family,Mail,ID,Course,Year of Study\n" +
+		"Amy,Lee,amy@example.com,S1,IT,1\n" +
+		"Ben,Ray,ben@example.com,S2,IT,2\n"
+	mapping := `{"Given": "first_name", "Family": "last_name", "Mail": "email", "ID": "student_id"}`
+	req := newImportRequest("/api/v1/students/import", csvData, mapping)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response importResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.True(t, response.Committed)
+	assert.Equal(t, 1, response.Created)
+	assert.Equal(t, 1, response.Updated)
+	mockRepo.AssertCalled(t, "Commit")
+}
+
+func TestImportStudentsMissingColumns(t *testing.T) {
+	r, _ := setupTestRouter()
+
+	// Create request
+	req := newImportRequest("/api/v1/students/import", "first_name,last_name\nAmy,Lee\n", "")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "email, student_id, course, year_of_study")
+}
+
+func TestImportStudentsTooLarge(t *testing.T) {
+	r, mockRepo := setupTestRouter()
+
+	// Create request with a file well over the limit
+	csvData := "first_name,last_name,email,student_id,course,year_of_study\n" +
+		strings.Repeat("Amy,Lee,amy@example.com,S1,IT,1\n", (handlers.MaxImportFileSize*2)/32)
+	req := newImportRequest("/api/v1/students/import?dry_run=true", csvData, "")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Contains(t, w.Body.String(), "request.too_large")
+	mockRepo.AssertNotCalled(t, "Begin")
+}
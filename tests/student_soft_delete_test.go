@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRestoreStudent(t *testing.T) {
+	r, mockRepo := setupTestRouter()
+
+	// Set expectations
+	mockRepo.On("Restore", 1).Return(&models.Student{ID: 1, FirstName: "John", Version: 3, ETag: `"3"`}, nil)
+
+	// Create request
+	req, _ := http.NewRequest("POST", "/api/v1/students/1/restore", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRestoreStudentNotDeleted(t *testing.T) {
+	r, mockRepo := setupTestRouter()
+
+	// Set expectations
+	mockRepo.On("Restore", 1).Return(nil, nil)
+	mockRepo.On("Restore", 999).Return(nil, nil)
+	mockRepo.On("GetByID", 1).Return(&models.Student{ID: 1}, nil)
+	mockRepo.On("GetByID", 999).Return(nil, nil)
+
+	// Test restoring a student that was never deleted
+	req, _ := http.NewRequest("POST", "/api/v1/students/1/restore", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Test restoring a student that does not exist
+	req, _ = http.NewRequest("POST", "/api/v1/students/999/restore", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetAllStudentsIncludeDeleted(t *testing.T) {
+	r, mockRepo := setupTestRouter()
+
+	// Set expectations
+	mockRepo.On("List", models.StudentListOptions{
+		Filter: models.StudentFilter{IncludeDeleted: true},
+		Limit:  models.DefaultStudentPageSize,
+		Sort:   "id",
+	}).Return(&models.StudentPage{Students: []models.Student{}}, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/api/v1/students?include_deleted=true", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockRepo.AssertExpectations(t)
+
+	// Assert an invalid flag is rejected
+	req, _ = http.NewRequest("GET", "/api/v1/students?include_deleted=maybe", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestImportStudentsDeletedStudentID(t *testing.T) {
+	r, mockRepo := setupTestRouter()
+	tx := &MockStudentTx{mockRepo}
+
+	// Set expectations
+	mockRepo.On("Begin").Return(tx, nil)
+	mockRepo.On("Upsert", mock.Anything).Return(false, models.ErrStudentDeleted)
+	mockRepo.On("Rollback").Return(nil)
+
+	// Create request
+	csvData := "first_name,last_name,email,student_id,course,year_of_study\n" +
+		"Amy,Lee,amy@example.com,S1,IT,1\n"
+	req := newImportRequest("/api/v1/students/import", csvData, "")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "deleted student")
+	mockRepo.AssertNotCalled(t, "Commit")
+}
+
+// fakeStudentPurger records the cut-off times it is asked to purge before
+type fakeStudentPurger struct {
+	mu      sync.Mutex
+	cutoffs []time.Time
+}
+
+func (p *fakeStudentPurger) PurgeDeleted(before time.Time) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cutoffs = append(p.cutoffs, before)
+	return 1, nil
+}
+
+func (p *fakeStudentPurger) calls() []time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]time.Time(nil), p.cutoffs...)
+}
+
+func TestRunStudentPurge(t *testing.T) {
+	purger := &fakeStudentPurger{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	start := time.Now()
+	go func() {
+		models.RunStudentPurge(ctx, purger, 48*time.Hour, 10*time.Millisecond)
+		close(done)
+	}()
+
+	// The first purge runs straight away and later ones on every interval
+	assert.Eventually(t, func() bool { return len(purger.calls()) >= 2 }, time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+
+	cutoff := purger.calls()[0]
+	assert.WithinDuration(t, start.Add(-48*time.Hour), cutoff, time.Second)
+}
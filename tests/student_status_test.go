@@ -0,0 +1,266 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bournemouth-uni-it-api-go/handlers"
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockStudentStatusRepository is a mock implementation of StudentStatusRepository
+type MockStudentStatusRepository struct {
+	mock.Mock
+}
+
+func (m *MockStudentStatusRepository) Transition(t *models.StudentTransition) (*models.Student, error) {
+	args := m.Called(t)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Student), args.Error(1)
+}
+
+func (m *MockStudentStatusRepository) ListTransitions(studentID int) ([]models.StudentTransition, error) {
+	args := m.Called(studentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.StudentTransition), args.Error(1)
+}
+
+func (m *MockStudentStatusRepository) CountByStatus() (map[string]int, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]int), args.Error(1)
+}
+
+func (m *MockStudentStatusRepository) WithAudit(a models.Audit) models.StudentStatusRepository {
+	return m
+}
+
+func setupStudentStatusTestRouter() (*gin.Engine, *MockStudentStatusRepository) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	mockRepo := new(MockStudentStatusRepository)
+
+	// Create a test handler with the mock repository
+	handler := &handlers.StudentStatusHandler{
+		Repo: mockRepo,
+	}
+
+	// Set up routes
+	r.GET("/api/v1/students/:id/transitions", handler.GetStudentTransitions)
+	r.POST("/api/v1/students/:id/transitions", handler.TransitionStudent)
+
+	return r, mockRepo
+}
+
+// postTransition sends a transition request body for student 1
+func postTransition(r *gin.Engine, body gin.H) *httptest.ResponseRecorder {
+	jsonData, _ := json.Marshal(body)
+	req, _ := http.NewRequest("POST", "/api/v1/students/1/transitions", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCheckTransition(t *testing.T) {
+	assert.NoError(t, models.CheckTransition(models.StatusApplicant, models.StatusEnrolled, "admitted"))
+	assert.NoError(t, models.CheckTransition(models.StatusEnrolled, models.StatusInterrupted, "medical"))
+	assert.NoError(t, models.CheckTransition(models.StatusInterrupted, models.StatusEnrolled, "returned"))
+
+	assert.ErrorIs(t, models.CheckTransition(models.StatusGraduated, models.StatusEnrolled, "readmitted"), models.ErrIllegalTransition)
+	// Assert deleting is not a lifecycle state
+	assert.False(t, models.IsValidStudentStatus("deleted"))
+	assert.ErrorIs(t, models.CheckTransition(models.StatusWithdrawn, "deleted", "duplicate"), models.ErrIllegalTransition)
+	assert.ErrorIs(t, models.CheckTransition(models.StatusEnrolled, models.StatusEnrolled, "admitted"), models.ErrIllegalTransition)
+	assert.ErrorIs(t, models.CheckTransition(models.StatusEnrolled, models.StatusGraduated, "medical"), models.ErrInvalidReason)
+
+	assert.Equal(t, []string{"graduated", "interrupted", "withdrawn"}, models.AllowedTransitions(models.StatusEnrolled))
+	assert.Empty(t, models.AllowedTransitions(models.StatusGraduated))
+}
+
+func TestTransitionStudent(t *testing.T) {
+	r, mockRepo := setupStudentStatusTestRouter()
+
+	// Set expectations
+	mockRepo.On("Transition", mock.MatchedBy(func(tr *models.StudentTransition) bool {
+		return tr.StudentID == 1 && tr.ToStatus == "interrupted" && tr.Reason == "medical" && tr.EffectiveDate == "2024-11-04"
+	})).Return(&models.Student{ID: 1, Status: "interrupted", Version: 2, ETag: `"2"`}, nil)
+
+	// Create request
+	w := postTransition(r, gin.H{"to": " Interrupted ", "reason": "medical", "effective_date": "2024-11-04"})
+
+	// Assert response
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransitionStudentDefaultsToToday(t *testing.T) {
+	r, mockRepo := setupStudentStatusTestRouter()
+
+	// Set expectations
+	today := time.Now().Format("2006-01-02")
+	mockRepo.On("Transition", mock.MatchedBy(func(tr *models.StudentTransition) bool {
+		return tr.EffectiveDate == today
+	})).Return(&models.Student{ID: 1, Status: "withdrawn"}, nil)
+
+	// Create request
+	w := postTransition(r, gin.H{"to": "withdrawn", "reason": "personal"})
+
+	// Assert response
+	assert.Equal(t, http.StatusCreated, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransitionStudentIllegal(t *testing.T) {
+	r, mockRepo := setupStudentStatusTestRouter()
+
+	// The repository reports the student's current status on the transition
+	mockRepo.On("Transition", mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(0).(*models.StudentTransition).FromStatus = "graduated"
+	}).Return(nil, models.ErrIllegalTransition)
+
+	// Create request
+	w := postTransition(r, gin.H{"to": "enrolled", "reason": "readmitted"})
+
+	// Assert response
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "student.transition_not_allowed", response["code"])
+	assert.Equal(t, "graduated", response["current_status"])
+	assert.Empty(t, response["allowed"])
+}
+
+func TestTransitionStudentInvalidReason(t *testing.T) {
+	r, mockRepo := setupStudentStatusTestRouter()
+
+	mockRepo.On("Transition", mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(0).(*models.StudentTransition).FromStatus = "enrolled"
+	}).Return(nil, models.ErrInvalidReason)
+
+	// Create request
+	w := postTransition(r, gin.H{"to": "graduated", "reason": "medical"})
+
+	// Assert response
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "completed")
+}
+
+func TestTransitionStudentBadRequest(t *testing.T) {
+	r, mockRepo := setupStudentStatusTestRouter()
+
+	tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+	for _, tc := range []struct {
+		body gin.H
+		code int
+	}{
+		{gin.H{"reason": "medical"}, http.StatusBadRequest},
+		{gin.H{"to": "interrupted"}, http.StatusBadRequest},
+		{gin.H{"to": "suspended", "reason": "medical"}, http.StatusBadRequest},
+		{gin.H{"to": "interrupted", "reason": "medical", "effective_date": "04/11/2024"}, http.StatusBadRequest},
+		{gin.H{"to": "interrupted", "reason": "medical", "effective_date": tomorrow}, http.StatusUnprocessableEntity},
+	} {
+		w := postTransition(r, tc.body)
+		assert.Equal(t, tc.code, w.Code, "body %v", tc.body)
+	}
+
+	mockRepo.AssertNotCalled(t, "Transition", mock.Anything)
+}
+
+func TestTransitionStudentNotFound(t *testing.T) {
+	r, mockRepo := setupStudentStatusTestRouter()
+
+	mockRepo.On("Transition", mock.Anything).Return(nil, models.ErrStudentNotFound)
+
+	w := postTransition(r, gin.H{"to": "enrolled", "reason": "admitted"})
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetStudentTransitions(t *testing.T) {
+	r, mockRepo := setupStudentStatusTestRouter()
+
+	// Mock data
+	transitions := []models.StudentTransition{
+		{ID: 1, StudentID: 1, FromStatus: "applicant", ToStatus: "enrolled", Reason: "admitted", EffectiveDate: "2024-09-16"},
+	}
+
+	// Set expectations
+	mockRepo.On("ListTransitions", 1).Return(transitions, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/api/v1/students/1/transitions", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response []models.StudentTransition
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, transitions, response)
+}
+
+func TestGetAllStudentsStatusFilter(t *testing.T) {
+	r, mockRepo := setupTestRouter()
+
+	// Set expectations
+	mockRepo.On("List", models.StudentListOptions{
+		Filter: models.StudentFilter{Statuses: []string{"withdrawn", "graduated"}},
+		Limit:  models.DefaultStudentPageSize,
+		Sort:   "id",
+	}).Return(&models.StudentPage{Students: []models.Student{}}, nil)
+	mockRepo.On("List", models.StudentListOptions{
+		Filter: models.StudentFilter{Statuses: models.StudentStatuses},
+		Limit:  models.DefaultStudentPageSize,
+		Sort:   "id",
+	}).Return(&models.StudentPage{Students: []models.Student{}}, nil)
+
+	for _, query := range []string{"status=withdrawn,Graduated", "status=all"} {
+		req, _ := http.NewRequest("GET", "/api/v1/students?"+query, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, query)
+	}
+
+	// Assert an unknown status is rejected
+	req, _ := http.NewRequest("GET", "/api/v1/students?status=suspended", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateStudentRejectsInitialStatus(t *testing.T) {
+	r, mockRepo := setupTestRouter()
+
+	// Create request
+	student := models.Student{FirstName: "John", LastName: "Doe", Email: "john@example.com", StudentID: "S12345", Course: "IT", YearOfStudy: 1, Status: "graduated"}
+	jsonData, _ := json.Marshal(student)
+	req, _ := http.NewRequest("POST", "/api/v1/students", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything)
+}
@@ -0,0 +1,231 @@
+package tests
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/bournemouth-uni-it-api-go/classification"
+	"github.com/bournemouth-uni-it-api-go/handlers"
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/bournemouth-uni-it-api-go/transcript"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockTranscriptRepository is a mock implementation of TranscriptRepository
+type MockTranscriptRepository struct {
+	mock.Mock
+}
+
+func (m *MockTranscriptRepository) Create(t *models.Transcript) error {
+	args := m.Called(t)
+	return args.Error(0)
+}
+
+func (m *MockTranscriptRepository) GetByCode(code string) (*models.Transcript, error) {
+	args := m.Called(code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Transcript), args.Error(1)
+}
+
+func setupTranscriptTestRouter() (*gin.Engine, *MockStudentRepository, *MockAssessmentRepository, *MockTranscriptRepository) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	students := new(MockStudentRepository)
+	results := new(MockAssessmentRepository)
+	transcripts := new(MockTranscriptRepository)
+
+	// Create a test handler with the mock repositories
+	handler := &handlers.TranscriptHandler{
+		Students:    students,
+		Results:     results,
+		Transcripts: transcripts,
+		Rules:       classification.DefaultRules(),
+	}
+
+	// Set up routes
+	r.GET("/api/v1/students/:id/transcript.pdf", handler.GetStudentTranscript)
+	r.GET("/api/v1/transcripts/verify/:code", handler.VerifyTranscript)
+
+	return r, students, results, transcripts
+}
+
+func TestTranscriptCodes(t *testing.T) {
+	code, err := transcript.NewCode()
+	assert.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^[0-9A-HJKMNP-TV-Z]{5}-[0-9A-HJKMNP-TV-Z]{5}$`), code)
+
+	other, err := transcript.NewCode()
+	assert.NoError(t, err)
+	assert.NotEqual(t, code, other)
+
+	assert.Equal(t, "7K2QF-9XMBD-P3", transcript.PageCode("7K2QF-9XMBD", 3))
+
+	base, page := transcript.ParsePageCode("7k2qf-9xmbd-p3")
+	assert.Equal(t, "7K2QF-9XMBD", base)
+	assert.Equal(t, 3, page)
+
+	base, page = transcript.ParsePageCode("7K2QF-9XMBD")
+	assert.Equal(t, "7K2QF-9XMBD", base)
+	assert.Equal(t, 0, page)
+}
+
+func TestGetStudentTranscript(t *testing.T) {
+	r, students, results, transcripts := setupTranscriptTestRouter()
+
+	// Mock data, with enough modules to run over several pages
+	student := &models.Student{ID: 1, FirstName: "Zoë", LastName: "Doe", Email: "zoe@example.com", StudentID: "S12345678", Course: "BSC-IT", YearOfStudy: 3}
+	var moduleResults []models.ModuleResult
+	for i := 0; i < 60; i++ {
+		result := models.ModuleResult{
+			ModuleCode:   fmt.Sprintf("CS%03d", i),
+			ModuleTitle:  "Module",
+			Level:        5 + i%2,
+			Credits:      20,
+			AcademicYear: fmt.Sprintf("20%02d/%02d", 20+i%2, 21+i%2),
+			Components: []models.ComponentResult{
+				{Name: "Coursework", Kind: "coursework", Weighting: 50, MaxMark: 100, Mark: markPtr(60)},
+				{Name: "Exam", Kind: "exam", Weighting: 50, MaxMark: 100, Mark: markPtr(70)},
+			},
+		}
+		result.Calculate()
+		moduleResults = append(moduleResults, result)
+	}
+
+	// Set expectations
+	students.On("GetByID", 1).Return(student, nil)
+	results.On("StudentResults", 1, "").Return(moduleResults, nil)
+	var recorded *models.Transcript
+	transcripts.On("Create", mock.AnythingOfType("*models.Transcript")).Return(nil).Run(func(args mock.Arguments) {
+		recorded = args.Get(0).(*models.Transcript)
+	})
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/api/v1/students/1/transcript.pdf", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/pdf", w.Header().Get("Content-Type"))
+	assert.Equal(t, "inline; filename=transcript-S12345678.pdf", w.Header().Get("Content-Disposition"))
+	assert.True(t, bytes.HasPrefix(w.Body.Bytes(), []byte("%PDF-")))
+	transcripts.AssertExpectations(t)
+
+	// The issued transcript is recorded under the code sent with it
+	assert.Equal(t, w.Header().Get("X-Transcript-Code"), recorded.Code)
+	assert.Equal(t, "S12345678", recorded.StudentNumber)
+	assert.Equal(t, classification.UpperSecond, recorded.Classification)
+	assert.Greater(t, recorded.Pages, 1)
+	sum := sha256.Sum256(w.Body.Bytes())
+	assert.Equal(t, hex.EncodeToString(sum[:]), recorded.SHA256)
+}
+
+func TestGetStudentTranscriptPendingModules(t *testing.T) {
+	r, students, results, transcripts := setupTranscriptTestRouter()
+
+	// Mock data, with one weighted module still waiting for its exam
+	moduleResults := []models.ModuleResult{
+		{ModuleCode: "CS201", Level: 5, Credits: 120, Total: markPtr(72)},
+		{ModuleCode: "CS301", Level: 6, Credits: 60, Total: markPtr(75)},
+		{ModuleCode: "CS302", Level: 6, Credits: 60, ProvisionalTotal: markPtr(30)},
+	}
+
+	// Set expectations
+	students.On("GetByID", 1).Return(&models.Student{ID: 1, FirstName: "John", LastName: "Doe", StudentID: "S12345", Course: "BSC-IT", YearOfStudy: 3}, nil)
+	results.On("StudentResults", 1, "").Return(moduleResults, nil)
+	var recorded *models.Transcript
+	transcripts.On("Create", mock.AnythingOfType("*models.Transcript")).Return(nil).Run(func(args mock.Arguments) {
+		recorded = args.Get(0).(*models.Transcript)
+	})
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/api/v1/students/1/transcript.pdf", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert the transcript is issued without a classification
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, recorded.Classification)
+}
+
+func TestGetStudentTranscriptFilenameEscaped(t *testing.T) {
+	r, students, results, transcripts := setupTranscriptTestRouter()
+
+	// Set expectations, with a student number that would break a quoted header
+	students.On("GetByID", 1).Return(&models.Student{ID: 1, FirstName: "John", LastName: "Doe", StudentID: "S1\"; x=\r\ny", Course: "BSC-IT", YearOfStudy: 3}, nil)
+	results.On("StudentResults", 1, "").Return([]models.ModuleResult{}, nil)
+	transcripts.On("Create", mock.AnythingOfType("*models.Transcript")).Return(nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/api/v1/students/1/transcript.pdf", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert the filename is encoded and parses back to the student number
+	assert.Equal(t, http.StatusOK, w.Code)
+	disposition, params, err := mime.ParseMediaType(w.Header().Get("Content-Disposition"))
+	require.NoError(t, err)
+	assert.Equal(t, "inline", disposition)
+	assert.Equal(t, "transcript-S1\"; x=\r\ny.pdf", params["filename"])
+}
+
+func TestGetStudentTranscriptNotFound(t *testing.T) {
+	r, students, _, transcripts := setupTranscriptTestRouter()
+
+	// Set expectations
+	students.On("GetByID", 99).Return(nil, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/api/v1/students/99/transcript.pdf", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	transcripts.AssertNotCalled(t, "Create", mock.Anything)
+}
+
+func TestVerifyTranscript(t *testing.T) {
+	r, _, _, transcripts := setupTranscriptTestRouter()
+
+	// Mock data
+	record := &models.Transcript{Code: "7K2QF-9XMBD", StudentID: 1, StudentNumber: "S12345678", StudentName: "John Doe", Pages: 2}
+
+	// Set expectations
+	transcripts.On("GetByCode", "7K2QF-9XMBD").Return(record, nil)
+	transcripts.On("GetByCode", "AAAAA-AAAAA").Return(nil, nil)
+
+	tests := []struct {
+		code     string
+		expected int
+	}{
+		{"7K2QF-9XMBD", http.StatusOK},
+		{"7k2qf-9xmbd-p2", http.StatusOK},
+		{"7K2QF-9XMBD-P3", http.StatusNotFound},
+		{"AAAAA-AAAAA", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", "/api/v1/transcripts/verify/"+tt.code, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, tt.expected, w.Code, tt.code)
+		if tt.expected == http.StatusOK {
+			assert.Contains(t, w.Body.String(), `"valid":true`)
+			assert.Contains(t, w.Body.String(), "S12345678")
+		}
+	}
+}
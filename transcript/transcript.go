@@ -0,0 +1,225 @@
+// Package transcript renders academic transcripts as PDF documents. Every
+// page carries a verification code that identifies the issued transcript.
+package transcript
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bournemouth-uni-it-api-go/classification"
+	"github.com/bournemouth-uni-it-api-go/models"
+	"github.com/go-pdf/fpdf"
+)
+
+// codeAlphabet is Crockford's base32, which avoids easily confused letters
+const codeAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewCode returns a random transcript verification code such as 7K2QF-9XMBD
+func NewCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := make([]byte, 0, 11)
+	for i, v := range b {
+		if i == 5 {
+			code = append(code, '-')
+		}
+		code = append(code, codeAlphabet[int(v)%len(codeAlphabet)])
+	}
+	return string(code), nil
+}
+
+// PageCode returns the verification code printed on one page of a transcript
+func PageCode(code string, page int) string {
+	return code + "-P" + strconv.Itoa(page)
+}
+
+// ParsePageCode splits a verification code into the transcript code and, if
+// it is a page code, the page number. Codes are case-insensitive.
+func ParsePageCode(s string) (string, int) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if i := strings.LastIndex(s, "-P"); i > 0 {
+		if page, err := strconv.Atoi(s[i+2:]); err == nil && page > 0 {
+			return s[:i], page
+		}
+	}
+	return s, 0
+}
+
+// Data is the content of a transcript
+type Data struct {
+	Code     string
+	IssuedAt time.Time
+	Student  *models.Student
+	Results  []models.ModuleResult
+	// Classification is nil if the student cannot be classified yet
+	Classification *classification.Result
+	// PendingModules are the codes of modules that count towards the
+	// classification but have no final total yet
+	PendingModules []string
+}
+
+// Render writes the transcript as a PDF to w and returns its page count
+func Render(w io.Writer, d Data) (int, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle("Academic Transcript", false)
+	pdf.SetCreator("Bournemouth University IT Student API", false)
+	pdf.SetCreationDate(d.IssuedAt)
+	pdf.SetModificationDate(d.IssuedAt)
+	pdf.SetAutoPageBreak(true, 25)
+	pdf.AliasNbPages("")
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	pdf.SetHeaderFunc(func() {
+		pdf.SetFont("Helvetica", "B", 14)
+		pdf.CellFormat(0, 8, "Bournemouth University", "", 1, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 11)
+		pdf.CellFormat(0, 6, "Academic Transcript", "B", 1, "L", false, 0, "")
+		pdf.Ln(4)
+	})
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-18)
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.CellFormat(0, 4, "Verification code: "+PageCode(d.Code, pdf.PageNo())+
+			"    Verify at /api/v1/transcripts/verify/"+d.Code, "T", 1, "L", false, 0, "")
+		pdf.CellFormat(0, 4, fmt.Sprintf("Issued %s    Page %d of {nb}", d.IssuedAt.UTC().Format("2 January 2006"), pdf.PageNo()), "", 0, "L", false, 0, "")
+	})
+
+	pdf.AddPage()
+	renderStudent(pdf, tr, d.Student)
+	renderResults(pdf, tr, d.Results)
+	renderClassification(pdf, tr, d.Classification, d.PendingModules)
+
+	if err := pdf.Output(w); err != nil {
+		return 0, err
+	}
+	return pdf.PageNo(), nil
+}
+
+// heading writes a section heading
+func heading(pdf *fpdf.Fpdf, text string) {
+	pdf.Ln(3)
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.CellFormat(0, 7, text, "", 1, "L", false, 0, "")
+}
+
+// renderStudent writes the student's personal details
+func renderStudent(pdf *fpdf.Fpdf, tr func(string) string, s *models.Student) {
+	heading(pdf, "Student")
+	rows := [][2]string{
+		{"Name", s.FirstName + " " + s.LastName},
+		{"Student ID", s.StudentID},
+		{"Email", s.Email},
+		{"Course", s.Course},
+		{"Year of study", strconv.Itoa(s.YearOfStudy)},
+	}
+	for _, row := range rows {
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(40, 6, row[0], "", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.CellFormat(0, 6, tr(row[1]), "", 1, "L", false, 0, "")
+	}
+}
+
+// resultColumns are the widths and titles of the module results table
+var resultColumns = []struct {
+	width float64
+	title string
+	align string
+}{
+	{25, "Code", "L"},
+	{80, "Module", "L"},
+	{15, "Level", "C"},
+	{20, "Credits", "C"},
+	{20, "Mark", "R"},
+	{30, "Status", "L"},
+}
+
+// renderResults writes the modules taken in each academic year with their
+// published marks
+func renderResults(pdf *fpdf.Fpdf, tr func(string) string, results []models.ModuleResult) {
+	heading(pdf, "Modules and marks")
+	if len(results) == 0 {
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.CellFormat(0, 6, "No modules taken.", "", 1, "L", false, 0, "")
+		return
+	}
+
+	year := ""
+	for _, r := range results {
+		if r.AcademicYear != year {
+			year = r.AcademicYear
+			pdf.Ln(2)
+			pdf.SetFont("Helvetica", "B", 10)
+			pdf.CellFormat(0, 6, "Academic year "+year, "", 1, "L", false, 0, "")
+			pdf.SetFont("Helvetica", "B", 9)
+			for _, col := range resultColumns {
+				pdf.CellFormat(col.width, 6, col.title, "B", 0, col.align, false, 0, "")
+			}
+			pdf.Ln(-1)
+		}
+
+		mark, status := "-", "Pending"
+		switch {
+		case r.Total != nil:
+			mark, status = fmt.Sprintf("%.2f", *r.Total), "Complete"
+		case r.ProvisionalTotal != nil:
+			mark, status = fmt.Sprintf("%.2f", *r.ProvisionalTotal), "Provisional"
+		}
+
+		pdf.SetFont("Helvetica", "", 9)
+		values := []string{r.ModuleCode, tr(r.ModuleTitle), strconv.Itoa(r.Level), strconv.Itoa(r.Credits), mark, status}
+		for i, col := range resultColumns {
+			pdf.CellFormat(col.width, 6, values[i], "", 0, col.align, false, 0, "")
+		}
+		pdf.Ln(-1)
+
+		pdf.SetFont("Helvetica", "", 8)
+		for _, c := range r.Components {
+			line := fmt.Sprintf("%s (%s, %d%%)", c.Name, c.Kind, c.Weighting)
+			result := "not published"
+			if c.Percentage != nil {
+				result = fmt.Sprintf("%.2f%%", *c.Percentage)
+			}
+			pdf.CellFormat(resultColumns[0].width, 5, "", "", 0, "L", false, 0, "")
+			pdf.CellFormat(resultColumns[1].width+resultColumns[2].width+resultColumns[3].width, 5, tr(line), "", 0, "L", false, 0, "")
+			pdf.CellFormat(resultColumns[4].width, 5, result, "", 1, "R", false, 0, "")
+		}
+	}
+}
+
+// renderClassification writes the degree classification and how it was
+// reached, or the modules it is waiting for
+func renderClassification(pdf *fpdf.Fpdf, tr func(string) string, result *classification.Result, pending []string) {
+	heading(pdf, "Classification")
+	pdf.SetFont("Helvetica", "", 10)
+	if result == nil {
+		pdf.CellFormat(0, 6, "Not yet classified.", "", 1, "L", false, 0, "")
+		if len(pending) > 0 {
+			pdf.MultiCell(0, 5, tr("Modules awaiting final marks: "+strings.Join(pending, ", ")), "", "L", false)
+		}
+		return
+	}
+
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(0, 6, tr(result.Classification), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	for _, level := range result.Levels {
+		pdf.CellFormat(0, 5, fmt.Sprintf("Level %d: average %.2f over %d credits (%d discounted), weight %g, contributing %.2f",
+			level.Level, level.Average, level.CountedCredits, level.DiscountedCredits, level.Weight, level.Contribution), "", 1, "L", false, 0, "")
+	}
+	pdf.CellFormat(0, 5, fmt.Sprintf("Weighted average: %.2f", result.Average), "", 1, "L", false, 0, "")
+	if b := result.Borderline; b != nil {
+		outcome := "not promoted"
+		if b.Promoted {
+			outcome = "promoted"
+		}
+		pdf.CellFormat(0, 5, tr(fmt.Sprintf("Borderline for %s: %d of %d level %d credits at %.0f or above, %s",
+			b.NextClass, b.CreditsAtOrAbove, b.CreditsConsidered, b.Level, b.Boundary, outcome)), "", 1, "L", false, 0, "")
+	}
+}